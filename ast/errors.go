@@ -0,0 +1,7 @@
+package ast
+
+import "errors"
+
+// ErrUnknownNode is returned by FromJSON if the "type" field
+// doesn't name a known node type.
+var ErrUnknownNode = errors.New("unknown node type")
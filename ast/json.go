@@ -0,0 +1,445 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Nodes are encoded to JSON as objects with the "type" field
+// naming the node type, such as "Declaration" or "InstructionCall",
+// so FromJSON can decode them back, including nodes stored in Node fields.
+//
+// The type names are the names of the Go types without the package.
+
+// tagged encodes v, which is a node converted to the type without methods,
+// adding the "type" field first.
+func tagged(typ string, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf(`{"type":%q`, typ)
+	if len(data) == 2 {
+		return []byte(header + "}"), nil
+	}
+	return append([]byte(header+","), data[1:]...), nil
+}
+
+func (d Declaration) MarshalJSON() ([]byte, error) {
+	type plain Declaration
+	return tagged("Declaration", plain(d))
+}
+
+func (p FunctionParameter) MarshalJSON() ([]byte, error) {
+	type plain FunctionParameter
+	return tagged("FunctionParameter", plain(p))
+}
+
+func (f FunctionValue) MarshalJSON() ([]byte, error) {
+	type plain FunctionValue
+	return tagged("FunctionValue", plain(f))
+}
+
+func (v Value) MarshalJSON() ([]byte, error) {
+	type plain Value
+	return tagged("Value", plain(v))
+}
+
+func (i InstructionCall) MarshalJSON() ([]byte, error) {
+	type plain InstructionCall
+	return tagged("InstructionCall", plain(i))
+}
+
+func (a InstructionCallArgument) MarshalJSON() ([]byte, error) {
+	type plain InstructionCallArgument
+	return tagged("InstructionCallArgument", plain(a))
+}
+
+func (b BinaryExpression) MarshalJSON() ([]byte, error) {
+	type plain BinaryExpression
+	return tagged("BinaryExpression", plain(b))
+}
+
+func (c CallExpression) MarshalJSON() ([]byte, error) {
+	type plain CallExpression
+	return tagged("CallExpression", plain(c))
+}
+
+func (m ModuleDeclaration) MarshalJSON() ([]byte, error) {
+	type plain ModuleDeclaration
+	return tagged("ModuleDeclaration", plain(m))
+}
+
+func (i ImportDeclaration) MarshalJSON() ([]byte, error) {
+	type plain ImportDeclaration
+	return tagged("ImportDeclaration", plain(i))
+}
+
+func (a ArrayValue) MarshalJSON() ([]byte, error) {
+	type plain ArrayValue
+	return tagged("ArrayValue", plain(a))
+}
+
+func (e ArrayElement) MarshalJSON() ([]byte, error) {
+	type plain ArrayElement
+	return tagged("ArrayElement", plain(e))
+}
+
+func (l Label) MarshalJSON() ([]byte, error) {
+	type plain Label
+	return tagged("Label", plain(l))
+}
+
+func (j Jump) MarshalJSON() ([]byte, error) {
+	type plain Jump
+	return tagged("Jump", plain(j))
+}
+
+func (l LocalDeclaration) MarshalJSON() ([]byte, error) {
+	type plain LocalDeclaration
+	return tagged("LocalDeclaration", plain(l))
+}
+
+func (i IfStatement) MarshalJSON() ([]byte, error) {
+	type plain IfStatement
+	return tagged("IfStatement", plain(i))
+}
+
+func (l LoopStatement) MarshalJSON() ([]byte, error) {
+	type plain LoopStatement
+	return tagged("LoopStatement", plain(l))
+}
+
+func (b BreakStatement) MarshalJSON() ([]byte, error) {
+	type plain BreakStatement
+	return tagged("BreakStatement", plain(b))
+}
+
+func (c ContinueStatement) MarshalJSON() ([]byte, error) {
+	type plain ContinueStatement
+	return tagged("ContinueStatement", plain(c))
+}
+
+// FromJSON decodes the node encoded by json.Marshal or ToString.
+//
+// *Declaration is returned for declarations, and values for other nodes,
+// like the parser does. JSON null is decoded as the nil node.
+//
+// Returns ErrUnknownNode if the "type" field is missing or unknown.
+func FromJSON(data []byte) (Node, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	var (
+		n   Node
+		err error
+	)
+	switch header.Type {
+	case "Declaration":
+		d := &Declaration{}
+		err = json.Unmarshal(data, d)
+		n = d
+	case "FunctionParameter":
+		n, err = decode[FunctionParameter](data)
+	case "FunctionValue":
+		n, err = decode[FunctionValue](data)
+	case "Value":
+		n, err = decode[Value](data)
+	case "InstructionCall":
+		n, err = decode[InstructionCall](data)
+	case "InstructionCallArgument":
+		n, err = decode[InstructionCallArgument](data)
+	case "BinaryExpression":
+		n, err = decode[BinaryExpression](data)
+	case "CallExpression":
+		n, err = decode[CallExpression](data)
+	case "ModuleDeclaration":
+		n, err = decode[ModuleDeclaration](data)
+	case "ImportDeclaration":
+		n, err = decode[ImportDeclaration](data)
+	case "ArrayValue":
+		n, err = decode[ArrayValue](data)
+	case "ArrayElement":
+		n, err = decode[ArrayElement](data)
+	case "Label":
+		n, err = decode[Label](data)
+	case "Jump":
+		n, err = decode[Jump](data)
+	case "LocalDeclaration":
+		n, err = decode[LocalDeclaration](data)
+	case "IfStatement":
+		n, err = decode[IfStatement](data)
+	case "LoopStatement":
+		n, err = decode[LoopStatement](data)
+	case "BreakStatement":
+		n, err = decode[BreakStatement](data)
+	case "ContinueStatement":
+		n, err = decode[ContinueStatement](data)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownNode, header.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", header.Type, err)
+	}
+	return n, nil
+}
+
+// FromJSONList decodes the JSON array of nodes,
+// such as the one produced by encoding the result of parser.Parser.Parse.
+func FromJSONList(data []byte) ([]Node, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return fromRawList(raw)
+}
+
+func decode[T Node](data []byte) (Node, error) {
+	var n T
+	err := json.Unmarshal(data, &n)
+	return n, err
+}
+
+func fromRawList(raw []json.RawMessage) ([]Node, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	nodes := make([]Node, len(raw))
+	for i, r := range raw {
+		n, err := FromJSON(r)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+func isNull(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) == 0 || bytes.Equal(data, []byte("null"))
+}
+
+// The nodes below have Node fields,
+// which are decoded by FromJSON using the "type" field.
+
+func (d *Declaration) UnmarshalJSON(data []byte) error {
+	type plain Declaration
+	var raw struct {
+		plain
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	value, err := FromJSON(raw.Value)
+	if err != nil {
+		return err
+	}
+	*d = Declaration(raw.plain)
+	d.Value = value
+	return nil
+}
+
+func (f *FunctionValue) UnmarshalJSON(data []byte) error {
+	type plain FunctionValue
+	var raw struct {
+		plain
+		Body []json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	body, err := fromRawList(raw.Body)
+	if err != nil {
+		return err
+	}
+	*f = FunctionValue(raw.plain)
+	f.Body = body
+	return nil
+}
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	type plain Value
+	var raw struct {
+		plain
+		ValueNode json.RawMessage `json:"value_node"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	node, err := FromJSON(raw.ValueNode)
+	if err != nil {
+		return err
+	}
+	*v = Value(raw.plain)
+	v.ValueNode = node
+	return nil
+}
+
+func (a *InstructionCallArgument) UnmarshalJSON(data []byte) error {
+	type plain InstructionCallArgument
+	var raw struct {
+		plain
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	value, err := FromJSON(raw.Value)
+	if err != nil {
+		return err
+	}
+	*a = InstructionCallArgument(raw.plain)
+	a.Value = value
+	return nil
+}
+
+func (b *BinaryExpression) UnmarshalJSON(data []byte) error {
+	type plain BinaryExpression
+	var raw struct {
+		plain
+		Children []json.RawMessage `json:"children"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	children, err := fromRawList(raw.Children)
+	if err != nil {
+		return err
+	}
+	*b = BinaryExpression(raw.plain)
+	b.Children = children
+	return nil
+}
+
+func (c *CallExpression) UnmarshalJSON(data []byte) error {
+	type plain CallExpression
+	var raw struct {
+		plain
+		Arguments []json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	args, err := fromRawList(raw.Arguments)
+	if err != nil {
+		return err
+	}
+	*c = CallExpression(raw.plain)
+	c.Arguments = args
+	return nil
+}
+
+func (m *ModuleDeclaration) UnmarshalJSON(data []byte) error {
+	type plain ModuleDeclaration
+	var raw struct {
+		plain
+		Body []json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	body, err := fromRawList(raw.Body)
+	if err != nil {
+		return err
+	}
+	*m = ModuleDeclaration(raw.plain)
+	m.Body = body
+	return nil
+}
+
+func (e *ArrayElement) UnmarshalJSON(data []byte) error {
+	type plain ArrayElement
+	var raw struct {
+		plain
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	value, err := FromJSON(raw.Value)
+	if err != nil {
+		return err
+	}
+	*e = ArrayElement(raw.plain)
+	e.Value = value
+	return nil
+}
+
+func (l *LocalDeclaration) UnmarshalJSON(data []byte) error {
+	type plain LocalDeclaration
+	var raw struct {
+		plain
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	value, err := FromJSON(raw.Value)
+	if err != nil {
+		return err
+	}
+	*l = LocalDeclaration(raw.plain)
+	l.Value = value
+	return nil
+}
+
+func (i *IfStatement) UnmarshalJSON(data []byte) error {
+	type plain IfStatement
+	var raw struct {
+		plain
+		Condition json.RawMessage   `json:"condition"`
+		Body      []json.RawMessage `json:"body"`
+		Else      []json.RawMessage `json:"else"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	condition, err := FromJSON(raw.Condition)
+	if err != nil {
+		return err
+	}
+	body, err := fromRawList(raw.Body)
+	if err != nil {
+		return err
+	}
+	els, err := fromRawList(raw.Else)
+	if err != nil {
+		return err
+	}
+	*i = IfStatement(raw.plain)
+	i.Condition, i.Body, i.Else = condition, body, els
+	return nil
+}
+
+func (l *LoopStatement) UnmarshalJSON(data []byte) error {
+	type plain LoopStatement
+	var raw struct {
+		plain
+		Condition json.RawMessage   `json:"condition"`
+		Body      []json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	condition, err := FromJSON(raw.Condition)
+	if err != nil {
+		return err
+	}
+	body, err := fromRawList(raw.Body)
+	if err != nil {
+		return err
+	}
+	*l = LoopStatement(raw.plain)
+	l.Condition, l.Body = condition, body
+	return nil
+}
@@ -0,0 +1,96 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/internal/parsetest"
+)
+
+const source = `import "lib"
+# Module doc.
+"main": {
+	"inner": {
+		export link(false) n i32 1
+	}
+	declare sq i32 (x i32)
+	link("lib") twice i32 (x i32 copy(false))
+	k i32 consteval([sq](5))
+	a i32 array(1, -2, 3)
+
+	main void () {
+		x i32 (1 + 2) * -k;
+		if x < 0 {
+			stdout copy(x), "s";
+		} else if x == 0 {
+			jmp end;
+		} else {
+			loop {
+				break;
+			}
+		}
+		while x > 0 {
+			sub x, x, 1;
+			continue;
+		}
+	end:
+		[twice] x;
+	}
+}
+`
+
+func TestJSONRoundTrip(t *testing.T) {
+	sources := map[string]string{"source": source}
+	paths, err := filepath.Glob(filepath.Join("..", "vm", "testdata", "*.dl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[filepath.Base(path)] = string(src)
+	}
+
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			nodes := parsetest.Parse(t, src)
+			data, err := json.Marshal(nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := ast.FromJSONList(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, nodes) {
+				again, _ := json.Marshal(decoded)
+				t.Errorf("decoded\n%s\nwant\n%s", again, data)
+			}
+		})
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"missing type", `{"Name": "main"}`},
+		{"unknown type", `{"type": "Unknown"}`},
+		{"unknown nested type", `{"type": "ModuleDeclaration", "Body": [{"type": "Unknown"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ast.FromJSON([]byte(tt.data)); !errors.Is(err, ast.ErrUnknownNode) {
+				t.Errorf("got error %v, want %v", err, ast.ErrUnknownNode)
+			}
+		})
+	}
+}
@@ -8,6 +8,31 @@ import (
 
 type Node interface {
 	Node()
+
+	// Pos returns the position of the first character of the node.
+	Pos() token.Position
+
+	// End returns the position right after the last character of the node.
+	End() token.Position
+}
+
+// Span is the source range of a node, embedded into every node.
+//
+// Nodes that aren't parsed from the source, such as folded constants,
+// have the zero span.
+type Span struct {
+	StartPos token.Position `json:"start"`
+	EndPos   token.Position `json:"end"`
+}
+
+// Pos returns the start position of the span.
+func (s Span) Pos() token.Position {
+	return s.StartPos
+}
+
+// End returns the end position of the span, which is exclusive.
+func (s Span) End() token.Position {
+	return s.EndPos
 }
 
 type Documentable interface {
@@ -15,6 +40,7 @@ type Documentable interface {
 }
 
 type Declaration struct {
+	Span
 	Name          string `json:"name"`
 	Kind          string `json:"kind"`
 	Documentation string `json:"documentation,omitempty"`
@@ -28,17 +54,20 @@ type Declaration struct {
 }
 
 type FunctionParameter struct {
+	Span
 	Identifier  string `json:"identifier"`
 	CopyAllowed bool   `json:"copy_allowed"`
 	Kind        string `json:"kind"`
 }
 
 type FunctionValue struct {
+	Span
 	Parameters []FunctionParameter `json:"parameters"`
 	Body       []Node              `json:"body"`
 }
 
 type Value struct {
+	Span
 	Consteval bool       `json:"consteval"`
 	Copied    bool       `json:"copied"`
 	Kind      token.Kind `json:"kind"`
@@ -47,36 +76,92 @@ type Value struct {
 }
 
 type InstructionCall struct {
+	Span
 	Name      string                    `json:"name"`
 	IsUser    bool                      `json:"is_user"`
 	Arguments []InstructionCallArgument `json:"arguments"`
 }
 
 type InstructionCallArgument struct {
+	Span
 	Value     Node       `json:"value"`
 	Consteval bool       `json:"consteval"`
 	Kind      token.Kind `json:"kind"`
 }
 
 type BinaryExpression struct {
+	Span
 	Operator string `json:"operator"`
 	Children []Node `json:"children"`
 }
 
+type CallExpression struct {
+	Span
+	Name      string `json:"name"`
+	Arguments []Node `json:"arguments"`
+}
+
 type ModuleDeclaration struct {
-	Name          string `json:"name"`
-	Body          []Node `json:"body"`
+	Span
+	Name string `json:"name"`
+	Body []Node `json:"body"`
+}
+
+type ImportDeclaration struct {
+	Span
+	Path string `json:"path"`
 }
 
 type ArrayValue struct {
+	Span
 	MaxSize  int            `json:"max_size"`
 	Elements []ArrayElement `json:"elements"`
 }
 
 type ArrayElement struct {
+	Span
 	Value Node `json:"value"`
 }
 
+type Label struct {
+	Span
+	Name string `json:"name"`
+}
+
+type Jump struct {
+	Span
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
+
+type LocalDeclaration struct {
+	Span
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Value Node   `json:"value"`
+}
+
+type IfStatement struct {
+	Span
+	Condition Node   `json:"condition"`
+	Body      []Node `json:"body"`
+	Else      []Node `json:"else,omitempty"`
+}
+
+type LoopStatement struct {
+	Span
+	Condition Node   `json:"condition,omitempty"`
+	Body      []Node `json:"body"`
+}
+
+type BreakStatement struct {
+	Span
+}
+
+type ContinueStatement struct {
+	Span
+}
+
 func ToString(n Node) string {
 	if n == nil {
 		return "<nil>"
@@ -100,6 +185,15 @@ func (Value) Node()                   {}
 func (InstructionCall) Node()         {}
 func (InstructionCallArgument) Node() {}
 func (BinaryExpression) Node()        {}
+func (CallExpression) Node()          {}
 func (ModuleDeclaration) Node()       {}
+func (ImportDeclaration) Node()       {}
 func (ArrayValue) Node()              {}
 func (ArrayElement) Node()            {}
+func (Label) Node()                   {}
+func (Jump) Node()                    {}
+func (LocalDeclaration) Node()        {}
+func (IfStatement) Node()             {}
+func (LoopStatement) Node()           {}
+func (BreakStatement) Node()          {}
+func (ContinueStatement) Node()       {}
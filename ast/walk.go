@@ -0,0 +1,102 @@
+package ast
+
+// Visitor visits nodes traversed by Walk.
+//
+// Visit is called for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the AST in depth-first order.
+//
+// It starts by calling v.Visit(node); node must not be nil.
+// The children are visited in the order of the source:
+//   - the value of *Declaration and LocalDeclaration;
+//   - parameters and the body of FunctionValue;
+//   - ValueNode of Value, if it's set;
+//   - arguments of InstructionCall and CallExpression, and the value of InstructionCallArgument;
+//   - Children of BinaryExpression;
+//   - Elements of ArrayValue and the value of ArrayElement;
+//   - the body of ModuleDeclaration;
+//   - the condition, the body and the else branch of IfStatement;
+//   - the condition, if it's set, and the body of LoopStatement.
+//
+// Nodes of other types have no children.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Declaration:
+		walk(v, n.Value)
+	case LocalDeclaration:
+		walk(v, n.Value)
+	case FunctionValue:
+		for _, p := range n.Parameters {
+			Walk(v, p)
+		}
+		walkList(v, n.Body)
+	case Value:
+		walk(v, n.ValueNode)
+	case InstructionCall:
+		for _, a := range n.Arguments {
+			Walk(v, a)
+		}
+	case InstructionCallArgument:
+		walk(v, n.Value)
+	case BinaryExpression:
+		walkList(v, n.Children)
+	case CallExpression:
+		walkList(v, n.Arguments)
+	case ArrayValue:
+		for _, e := range n.Elements {
+			Walk(v, e)
+		}
+	case ArrayElement:
+		walk(v, n.Value)
+	case ModuleDeclaration:
+		walkList(v, n.Body)
+	case IfStatement:
+		walk(v, n.Condition)
+		walkList(v, n.Body)
+		walkList(v, n.Else)
+	case LoopStatement:
+		walk(v, n.Condition)
+		walkList(v, n.Body)
+	}
+
+	v.Visit(nil)
+}
+
+// walk walks node, unless it's nil.
+func walk(v Visitor, node Node) {
+	if node != nil {
+		Walk(v, node)
+	}
+}
+
+func walkList(v Visitor, nodes []Node) {
+	for _, n := range nodes {
+		walk(v, n)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the AST in depth-first order:
+// It starts by calling f(node); node must not be nil.
+// If f returns true, Inspect invokes f recursively for each of the children of node,
+// followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,191 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/internal/parsetest"
+)
+
+// label returns the type of n without the package name,
+// followed by its name or value if it has one.
+func label(n ast.Node) string {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", n), "ast.")
+	kind = strings.TrimPrefix(kind, "*ast.")
+	switch n := n.(type) {
+	case ast.ModuleDeclaration:
+		return kind + " " + n.Name
+	case *ast.Declaration:
+		return kind + " " + n.Name
+	case ast.LocalDeclaration:
+		return kind + " " + n.Name
+	case ast.InstructionCall:
+		return kind + " " + n.Name
+	case ast.CallExpression:
+		return kind + " " + n.Name
+	case ast.BinaryExpression:
+		return kind + " " + n.Operator
+	case ast.Value:
+		if n.ValueNode == nil {
+			return kind + " " + n.Value
+		}
+	}
+	return kind
+}
+
+// recorder records the visited nodes, indented by their depth,
+// and prunes nodes for which prune returns true.
+type recorder struct {
+	trace *[]string
+	depth int
+	prune func(ast.Node) bool
+}
+
+func (r recorder) Visit(n ast.Node) ast.Visitor {
+	indent := strings.Repeat("  ", r.depth)
+	if n == nil {
+		*r.trace = append(*r.trace, indent+"end")
+		return nil
+	}
+	*r.trace = append(*r.trace, indent+label(n))
+	if r.prune != nil && r.prune(n) {
+		return nil
+	}
+	return recorder{trace: r.trace, depth: r.depth + 1, prune: r.prune}
+}
+
+const walkSource = `"main": {
+	x i32 1 + [f](2)
+	main void (a i32) {
+		if a < 1 {
+			stdout a;
+		}
+	}
+}`
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		name  string
+		prune func(ast.Node) bool
+		want  []string
+	}{
+		{
+			name: "all",
+			want: []string{
+				"ModuleDeclaration main",
+				"  Declaration x",
+				"    BinaryExpression +",
+				"      Value 1",
+				"        end",
+				"      CallExpression f",
+				"        Value 2",
+				"          end",
+				"        end",
+				"      end",
+				"    end",
+				"  Declaration main",
+				"    FunctionValue",
+				"      FunctionParameter",
+				"        end",
+				"      IfStatement",
+				"        BinaryExpression <",
+				"          Value a",
+				"            end",
+				"          Value 1",
+				"            end",
+				"          end",
+				"        InstructionCall stdout",
+				"          InstructionCallArgument",
+				"            Value a",
+				"              end",
+				"            end",
+				"          end",
+				"        end",
+				"      end",
+				"    end",
+				"  end",
+			},
+		},
+		{
+			name: "pruned",
+			prune: func(n ast.Node) bool {
+				switch n.(type) {
+				case ast.BinaryExpression, ast.IfStatement:
+					return true
+				}
+				return false
+			},
+			want: []string{
+				"ModuleDeclaration main",
+				"  Declaration x",
+				"    BinaryExpression +",
+				"    end",
+				"  Declaration main",
+				"    FunctionValue",
+				"      FunctionParameter",
+				"        end",
+				"      IfStatement",
+				"      end",
+				"    end",
+				"  end",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace []string
+			ast.Walk(recorder{trace: &trace, prune: tt.prune}, parsetest.Parse(t, walkSource)[0])
+			if got, want := strings.Join(trace, "\n"), strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("visited\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name string
+		stop string
+		want []string
+	}{
+		{
+			name: "all",
+			want: []string{
+				"ModuleDeclaration main", "Declaration x", "BinaryExpression +",
+				"Value 1", "nil", "CallExpression f", "Value 2", "nil", "nil", "nil", "nil",
+				"Declaration main", "FunctionValue", "FunctionParameter", "nil", "IfStatement",
+				"BinaryExpression <", "Value a", "nil", "Value 1", "nil", "nil",
+				"InstructionCall stdout", "InstructionCallArgument", "Value a", "nil", "nil", "nil", "nil", "nil", "nil",
+				"nil",
+			},
+		},
+		{
+			name: "pruned declarations",
+			stop: "Declaration",
+			want: []string{"ModuleDeclaration main", "Declaration x", "Declaration main", "nil"},
+		},
+		{
+			name: "pruned module",
+			stop: "ModuleDeclaration",
+			want: []string{"ModuleDeclaration main"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			ast.Inspect(parsetest.Parse(t, walkSource)[0], func(n ast.Node) bool {
+				if n == nil {
+					got = append(got, "nil")
+					return false
+				}
+				got = append(got, label(n))
+				return tt.stop == "" || !strings.HasPrefix(label(n), tt.stop+" ")
+			})
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("visited\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
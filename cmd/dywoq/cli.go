@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/consteval"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/linker"
+	"github.com/dywoq/dywoqlang/parser"
+	"github.com/dywoq/dywoqlang/scanner"
+	"github.com/dywoq/dywoqlang/sema"
+	"github.com/dywoq/dywoqlang/token"
+	"github.com/dywoq/dywoqlang/typecheck"
+)
+
+// cli holds the state shared by commands:
+// common flags, loaded sources and collected diagnostics.
+type cli struct {
+	name   string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	debug  bool
+	format string
+	color  bool
+
+	sources     map[string]string
+	modules     map[string]string
+	diagnostics diag.Diagnostics
+}
+
+// file is a scanned and parsed source file.
+type file struct {
+	path   string
+	tokens []*token.Token
+	nodes  []ast.Node
+}
+
+// parseFlags parses common flags along with the ones registered by extra,
+// returning the positional arguments.
+// If it returns false, the command must exit with status 2.
+func (c *cli) parseFlags(args []string, extra func(fs *flag.FlagSet)) ([]string, bool) {
+	fs := flag.NewFlagSet("dywoq "+c.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.BoolVar(&c.debug, "debug", false, "trace the toolchain stages to the standard error")
+	fs.StringVar(&c.format, "format", "text", "output format: text or json")
+	fs.BoolVar(&c.color, "color", false, "colorize diagnostics with ANSI escape codes")
+	if extra != nil {
+		extra(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, false
+	}
+	if c.format != "text" && c.format != "json" {
+		fmt.Fprintf(c.stderr, "dywoq %s: unknown format %q\n", c.name, c.format)
+		return nil, false
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintf(c.stderr, "dywoq %s: no paths given\n", c.name)
+		return nil, false
+	}
+	return fs.Args(), true
+}
+
+// files expands paths into .dl files,
+// searching directories recursively.
+func (c *cli) files(paths []string) ([]string, error) {
+	var result []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			result = append(result, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".dl") {
+				result = append(result, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no .dl files found")
+	}
+	return result, nil
+}
+
+// scan reads and scans the file at path.
+func (c *cli) scan(path string) (*file, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if c.sources == nil {
+		c.sources = map[string]string{}
+	}
+	c.sources[path] = string(bytes)
+
+	tokens, err := scanner.New(c.debug).Scan(string(bytes))
+	c.add(path, err)
+	return &file{path: path, tokens: tokens}, nil
+}
+
+// parse reads, scans and parses the file at path.
+func (c *cli) parse(path string) (*file, error) {
+	f, err := c.scan(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.tokens) == 0 {
+		return f, nil
+	}
+	f.nodes, err = parser.New(c.debug).Parse(f.tokens)
+	c.add(path, err)
+
+	if c.modules == nil {
+		c.modules = map[string]string{}
+	}
+	for _, n := range f.nodes {
+		ast.Inspect(n, func(n ast.Node) bool {
+			md, ok := n.(ast.ModuleDeclaration)
+			if ok {
+				c.modules[md.Name] = path
+			}
+			return ok
+		})
+	}
+	return f, nil
+}
+
+// analyze runs the semantic analysis, linking, constant evaluation and type checking
+// of nodes, returning the links of declarations.
+// Errors are added to the diagnostics.
+func (c *cli) analyze(nodes []ast.Node) linker.Links {
+	info, err := sema.New(c.debug).Analyze(nodes)
+	if info != nil && len(info.Warnings) != 0 {
+		c.addAnalysis(info.Warnings)
+	}
+	if err != nil {
+		c.addAnalysis(err)
+		return nil
+	}
+	links, err := linker.New(c.debug).Link(nodes)
+	c.addAnalysis(err)
+	c.addAnalysis(consteval.New(c.debug).Evaluate(nodes, links))
+	c.addAnalysis(typecheck.New(c.debug).Check(nodes))
+	return links
+}
+
+// addAnalysis adds err of the analysis stages to the diagnostics,
+// attributing each diagnostic and its labels to the files declaring their modules.
+func (c *cli) addAnalysis(err error) {
+	var list diag.Diagnostics
+	list.Add(err)
+	for _, d := range list {
+		if d.File == "" {
+			d.File = c.modules[d.Module]
+		}
+		for i, l := range d.Labels {
+			if l.File == "" && l.Module != "" {
+				d.Labels[i].File = c.modules[l.Module]
+			}
+		}
+	}
+	c.diagnostics = append(c.diagnostics, list...)
+}
+
+// add adds err to the diagnostics of the file at path.
+func (c *cli) add(path string, err error) {
+	if err == nil {
+		return
+	}
+	var list diag.Diagnostics
+	list.Add(err)
+	if path != "" {
+		list.SetFile(path)
+	}
+	c.diagnostics = append(c.diagnostics, list...)
+}
+
+// report prints collected diagnostics to the standard error,
+// or to the standard output as JSON,
+// and returns the exit status: 1 if there are errors, otherwise 0.
+func (c *cli) report() int {
+	if c.format == "json" {
+		list := c.diagnostics
+		if list == nil {
+			list = diag.Diagnostics{}
+		}
+		if err := c.printJSON(list); err != nil {
+			return c.fail(err)
+		}
+	} else {
+		r := diag.NewRenderer(c.color)
+		for i, d := range c.diagnostics {
+			if i > 0 {
+				fmt.Fprintln(c.stderr)
+			}
+			if err := r.Render(c.stderr, d, c.sources[d.File]); err != nil {
+				return c.fail(err)
+			}
+		}
+	}
+	if c.diagnostics.HasErrors() {
+		return 1
+	}
+	return 0
+}
+
+func (c *cli) printJSON(v any) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.stdout, string(bytes))
+	return err
+}
+
+// fail prints err and returns the exit status 1.
+func (c *cli) fail(err error) int {
+	fmt.Fprintf(c.stderr, "dywoq %s: %v\n", c.name, err)
+	return 1
+}
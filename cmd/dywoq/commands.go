@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/compiler"
+	"github.com/dywoq/dywoqlang/format"
+	"github.com/dywoq/dywoqlang/interp"
+	"github.com/dywoq/dywoqlang/linker"
+	"github.com/dywoq/dywoqlang/loader"
+	"github.com/dywoq/dywoqlang/lsp"
+	"github.com/dywoq/dywoqlang/token"
+	"github.com/dywoq/dywoqlang/value"
+	"github.com/dywoq/dywoqlang/vm"
+)
+
+func runTokens(c *cli, args []string) int {
+	paths, ok := c.parseFlags(args, nil)
+	if !ok {
+		return 2
+	}
+	files, err := c.files(paths)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	type output struct {
+		File   string         `json:"file"`
+		Tokens []*token.Token `json:"tokens"`
+	}
+	var outputs []output
+	for _, path := range files {
+		f, err := c.scan(path)
+		if err != nil {
+			return c.fail(err)
+		}
+		if c.format == "json" {
+			outputs = append(outputs, output{File: path, Tokens: f.tokens})
+			continue
+		}
+		for _, t := range f.tokens {
+			fmt.Fprintf(c.stdout, "%s:%d:%d\t%s\t%q\n", path, t.Position.Line, t.Position.Column, t.Kind, t.Literal)
+		}
+	}
+	if c.format == "json" {
+		if err := c.printJSON(outputs); err != nil {
+			return c.fail(err)
+		}
+	}
+	return c.report()
+}
+
+func runAst(c *cli, args []string) int {
+	paths, ok := c.parseFlags(args, nil)
+	if !ok {
+		return 2
+	}
+	files, err := c.files(paths)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	type output struct {
+		File  string     `json:"file"`
+		Nodes []ast.Node `json:"nodes"`
+	}
+	var outputs []output
+	for _, path := range files {
+		f, err := c.parse(path)
+		if err != nil {
+			return c.fail(err)
+		}
+		if c.format == "json" {
+			outputs = append(outputs, output{File: path, Nodes: f.nodes})
+			continue
+		}
+		for _, n := range f.nodes {
+			fmt.Fprintln(c.stdout, ast.ToString(n))
+		}
+	}
+	if c.format == "json" {
+		if err := c.printJSON(outputs); err != nil {
+			return c.fail(err)
+		}
+	}
+	return c.report()
+}
+
+func runCheck(c *cli, args []string) int {
+	paths, ok := c.parseFlags(args, nil)
+	if !ok {
+		return 2
+	}
+	nodes, err := c.load(paths)
+	if err != nil {
+		return c.fail(err)
+	}
+	if !c.diagnostics.HasErrors() {
+		c.analyze(nodes)
+	}
+	return c.report()
+}
+
+func runRun(c *cli, args []string) int {
+	var useInterp bool
+	paths, ok := c.parseFlags(args, func(fs *flag.FlagSet) {
+		fs.BoolVar(&useInterp, "interp", false, "run with the tree-walking interpreter instead of the virtual machine")
+	})
+	if !ok {
+		return 2
+	}
+
+	var result value.Value
+	if len(paths) == 1 && strings.HasSuffix(paths[0], ".dlc") {
+		p, err := readProgram(paths[0])
+		if err != nil {
+			return c.fail(err)
+		}
+		result, err = c.vm().Run(p)
+		if err != nil {
+			return c.fail(err)
+		}
+		return exitStatus(result)
+	}
+
+	nodes, links, status := c.prepare(paths)
+	if status != 0 {
+		return status
+	}
+	var err error
+	if useInterp {
+		i := interp.New(c.debug)
+		i.SetOutput(c.stdout, c.stderr)
+		result, err = i.Run(nodes, links)
+	} else {
+		var p *compiler.Program
+		p, err = compiler.New(c.debug).Compile(nodes, links)
+		if err == nil {
+			result, err = c.vm().Run(p)
+		}
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+	return exitStatus(result)
+}
+
+// vm returns a new virtual machine writing to the output of c.
+func (c *cli) vm() *vm.VM {
+	m := vm.New(c.debug)
+	m.SetOutput(c.stdout, c.stderr)
+	return m
+}
+
+func runBuild(c *cli, args []string) int {
+	var (
+		output      string
+		disassemble bool
+	)
+	paths, ok := c.parseFlags(args, func(fs *flag.FlagSet) {
+		fs.StringVar(&output, "o", "", "output file; required unless a single file is built, which defaults to its name with the .dlc extension")
+		fs.BoolVar(&disassemble, "S", false, "print the disassembled bytecode instead of writing the output file")
+	})
+	if !ok {
+		return 2
+	}
+	if output == "" && !disassemble {
+		info, err := os.Stat(paths[0])
+		if len(paths) != 1 || (err == nil && info.IsDir()) {
+			fmt.Fprintf(c.stderr, "dywoq %s: -o is required to build a directory or multiple files\n", c.name)
+			return 2
+		}
+		output = strings.TrimSuffix(paths[0], ".dl") + ".dlc"
+	}
+	nodes, links, status := c.prepare(paths)
+	if status != 0 {
+		return status
+	}
+	p, err := compiler.New(c.debug).Compile(nodes, links)
+	if err != nil {
+		return c.fail(err)
+	}
+	if disassemble {
+		if err := compiler.Disassemble(c.stdout, p); err != nil {
+			return c.fail(err)
+		}
+		return 0
+	}
+
+	var buf bytes.Buffer
+	if err := compiler.Encode(&buf, p); err != nil {
+		return c.fail(err)
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0o644); err != nil {
+		return c.fail(err)
+	}
+	return 0
+}
+
+func runFmt(c *cli, args []string) int {
+	var write, list bool
+	paths, ok := c.parseFlags(args, func(fs *flag.FlagSet) {
+		fs.BoolVar(&write, "w", false, "write the result to the files instead of the standard output")
+		fs.BoolVar(&list, "l", false, "list the files whose formatting differs")
+	})
+	if !ok {
+		return 2
+	}
+	files, err := c.files(paths)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	f := format.New(c.debug)
+	for _, path := range files {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return c.fail(err)
+		}
+		if c.sources == nil {
+			c.sources = map[string]string{}
+		}
+		c.sources[path] = string(bytes)
+
+		output, err := f.Format(string(bytes))
+		if err != nil {
+			c.add(path, err)
+			continue
+		}
+		changed := output != string(bytes)
+		if list && changed {
+			fmt.Fprintln(c.stdout, path)
+		}
+		if write && changed {
+			if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+				return c.fail(err)
+			}
+		}
+		if !write && !list {
+			fmt.Fprint(c.stdout, output)
+		}
+	}
+	return c.report()
+}
+
+func runLsp(c *cli, args []string) int {
+	fs := flag.NewFlagSet("dywoq "+c.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.BoolVar(&c.debug, "debug", false, "trace the server to the standard error")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := lsp.New(c.debug).Serve(c.stdin, c.stdout); err != nil {
+		return c.fail(err)
+	}
+	return 0
+}
+
+// load loads every .dl file found in paths along with the files they import,
+// returning the module declarations of all of them.
+func (c *cli) load(paths []string) ([]ast.Node, error) {
+	files, err := c.files(paths)
+	if err != nil {
+		return nil, err
+	}
+	program, err := loader.New(c.debug).Load(files...)
+	if program == nil {
+		return nil, err
+	}
+	c.add("", err)
+
+	if c.sources == nil {
+		c.sources = map[string]string{}
+	}
+	if c.modules == nil {
+		c.modules = map[string]string{}
+	}
+	for _, f := range program.Files {
+		c.sources[f.Path] = f.Source
+	}
+	for name := range program.Modules {
+		c.modules[name] = program.File(name).Path
+	}
+	return program.Nodes(), nil
+}
+
+// prepare loads and analyzes the files found in paths, reporting the diagnostics.
+// If the returned exit status isn't 0, the command must exit with it.
+func (c *cli) prepare(paths []string) ([]ast.Node, linker.Links, int) {
+	nodes, err := c.load(paths)
+	if err != nil {
+		return nil, nil, c.fail(err)
+	}
+	if c.diagnostics.HasErrors() {
+		return nil, nil, c.report()
+	}
+	links := c.analyze(nodes)
+	if status := c.report(); status != 0 {
+		return nil, nil, status
+	}
+	return nodes, links, 0
+}
+
+func readProgram(path string) (*compiler.Program, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := compiler.Decode(f)
+	if errors.Is(err, compiler.ErrNotProgram) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, err
+}
+
+// exitStatus returns the integer returned by the main function as the exit status,
+// or 0 if it returned something else.
+//
+// Since the exit status is a byte, the integer is clamped to the range from 0 to 255:
+// negative integers become 0, and integers above 255 become 255.
+func exitStatus(v value.Value) int {
+	if v.Kind != value.Integer {
+		return 0
+	}
+	return int(min(max(v.Int, 0), 255))
+}
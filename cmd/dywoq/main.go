@@ -0,0 +1,67 @@
+// Command dywoq is the driver of the dywoqlang toolchain.
+//
+// Usage:
+//
+//	dywoq <command> [flags] <paths...>
+//
+// Paths can be .dl files or directories, which are searched for .dl files recursively.
+// The command exits with status 1 if there are error diagnostics,
+// and with status 2 on usage errors.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(cli *cli, args []string) int
+}
+
+var commands = []*command{
+	{"tokens", "print tokens of the files", runTokens},
+	{"ast", "print the AST of the files as JSON", runAst},
+	{"check", "report diagnostics of the files", runCheck},
+	{"run", "compile and run the program", runRun},
+	{"build", "compile the program into the bytecode file", runBuild},
+	{"fmt", "format the files in the canonical style", runFmt},
+	{"lsp", "run the language server over the standard input and output", runLsp},
+}
+
+func main() {
+	os.Exit(execute(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func execute(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage(stdout)
+		return 0
+	}
+	for _, c := range commands {
+		if c.name == name {
+			return c.run(&cli{name: name, stdin: stdin, stdout: stdout, stderr: stderr}, args[1:])
+		}
+	}
+	fmt.Fprintf(stderr, "dywoq: unknown command %q\n", name)
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: dywoq <command> [flags] <paths...>")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run 'dywoq <command> -h' for the flags of the command.")
+}
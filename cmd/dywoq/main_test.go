@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// files are the sources written into the directory of each test.
+var files = map[string]string{
+	"ok.dl":        "\"main\": {\n\tmain i32 () {\n\t\tstdout \"hi\";\n\t\tret 7;\n\t}\n}\n",
+	"undefined.dl": "\"main\": {\n\tmain void () {\n\t\tstdout x;\n\t}\n}\n",
+	"shadowed.dl":  "\"main\": {\n\tx i32 1\n\tmain void (x i32) {\n\t}\n}\n",
+	"big.dl":       "\"main\": {\n\tmain i32 () {\n\t\tret 300;\n\t}\n}\n",
+	"negative.dl":  "\"main\": {\n\tmain i32 () {\n\t\tret -1;\n\t}\n}\n",
+	"ugly.dl":      "\"main\": {   x   i32   1 }",
+	"lib/a.dl":     "\"a\": {\n\tx i32 1\n}\n",
+	"lib/b.dl":     "\"b\": {\n\ty i32 2\n}\n",
+}
+
+func TestExecute(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{name: "no command", args: nil, status: 2, stderr: "usage: dywoq"},
+		{name: "help", args: []string{"help"}, status: 0, stdout: "usage: dywoq"},
+		{name: "unknown command", args: []string{"compile"}, status: 2, stderr: `unknown command "compile"`},
+		{name: "unknown flag", args: []string{"check", "-nope", path("ok.dl")}, status: 2, stderr: "-nope"},
+		{name: "unknown format", args: []string{"check", "-format", "xml", path("ok.dl")}, status: 2, stderr: `unknown format "xml"`},
+		{name: "no paths", args: []string{"check"}, status: 2, stderr: "no paths given"},
+		{name: "missing file", args: []string{"check", path("missing.dl")}, status: 1, stderr: "missing.dl"},
+
+		{name: "check", args: []string{"check", path("ok.dl")}, status: 0},
+		{name: "check errors", args: []string{"check", path("undefined.dl")}, status: 1, stderr: "error[E0200]: undefined identifier x"},
+		{name: "check warnings", args: []string{"check", path("shadowed.dl")}, status: 0, stderr: "warning[W0200]"},
+		{name: "check directory", args: []string{"check", path("lib")}, status: 0},
+		{name: "check json", args: []string{"check", "-format", "json", path("undefined.dl")}, status: 1, stdout: `"code": "E0200"`},
+
+		{name: "run", args: []string{"run", path("ok.dl")}, status: 7, stdout: "hi\n"},
+		{name: "run interp", args: []string{"run", "-interp", path("ok.dl")}, status: 7, stdout: "hi\n"},
+		{name: "run errors", args: []string{"run", path("undefined.dl")}, status: 1, stderr: "E0200"},
+		{name: "run clamps large status", args: []string{"run", path("big.dl")}, status: 255},
+		{name: "run clamps negative status", args: []string{"run", path("negative.dl")}, status: 0},
+		{name: "run without main", args: []string{"run", path("lib")}, status: 1, stderr: "main"},
+
+		{name: "build directory without -o", args: []string{"build", path("lib")}, status: 2, stderr: "-o is required"},
+		{name: "build disassembled", args: []string{"build", "-S", path("ok.dl")}, status: 0, stdout: "main"},
+
+		{name: "fmt", args: []string{"fmt", path("ugly.dl")}, status: 0, stdout: "\"main\": {\n\tx i32 1\n}\n"},
+		{name: "fmt list", args: []string{"fmt", "-l", path("ok.dl"), path("ugly.dl")}, status: 0, stdout: path("ugly.dl") + "\n"},
+
+		{name: "tokens", args: []string{"tokens", path("ok.dl")}, status: 0, stdout: "1:1\tstring\t\"main\""},
+		{name: "ast", args: []string{"ast", path("ok.dl")}, status: 0, stdout: `"name": "main"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := execute(tt.args, strings.NewReader(""), &stdout, &stderr)
+			if status != tt.status {
+				t.Errorf("exited with %d, want %d\nstderr:\n%s", status, tt.status, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.stdout) {
+				t.Errorf("stdout\n%s\ndoesn't contain\n%s", stdout.String(), tt.stdout)
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("stderr\n%s\ndoesn't contain\n%s", stderr.String(), tt.stderr)
+			}
+		})
+	}
+}
+
+func TestBuildAndRun(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "ok.dl")
+	if err := os.WriteFile(src, []byte(files["ok.dl"]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := execute([]string{"build", src}, nil, &stdout, &stderr); status != 0 {
+		t.Fatalf("build exited with %d: %s", status, stderr.String())
+	}
+	if status := execute([]string{"run", filepath.Join(dir, "ok.dlc")}, nil, &stdout, &stderr); status != 7 {
+		t.Errorf("run exited with %d, want 7: %s", status, stderr.String())
+	}
+	if stdout.String() != "hi\n" {
+		t.Errorf("run printed %q, want %q", stdout.String(), "hi\n")
+	}
+}
+
+func TestTokensJSON(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "ok.dl")
+	if err := os.WriteFile(src, []byte(files["ok.dl"]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := execute([]string{"tokens", "-format", "json", src}, nil, &stdout, &stderr); status != 0 {
+		t.Fatalf("exited with %d: %s", status, stderr.String())
+	}
+	// The tokens are followed by the diagnostics.
+	d := json.NewDecoder(&stdout)
+	var output []struct {
+		File   string `json:"file"`
+		Tokens []any  `json:"tokens"`
+	}
+	if err := d.Decode(&output); err != nil {
+		t.Fatal(err)
+	}
+	if len(output) != 1 || output[0].File != src || len(output[0].Tokens) == 0 {
+		t.Errorf("got %+v, want tokens of %s", output, src)
+	}
+	var diagnostics []any
+	if err := d.Decode(&diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	if diagnostics == nil || len(diagnostics) != 0 {
+		t.Errorf("got diagnostics %v, want an empty list", diagnostics)
+	}
+}
+
+// TestSample makes sure the sample program of the repository keeps compiling and running.
+func TestSample(t *testing.T) {
+	sample := filepath.Join("..", "..", "main.dl")
+	for _, args := range [][]string{{"check", sample}, {"run", sample}, {"run", "-interp", sample}} {
+		var stdout, stderr bytes.Buffer
+		if status := execute(args, nil, &stdout, &stderr); status != 0 {
+			t.Errorf("%v exited with %d: %s", args, status, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,652 @@
+package compiler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/linker"
+	"github.com/dywoq/dywoqlang/token"
+	"github.com/dywoq/dywoqlang/value"
+)
+
+// Compiler lowers parsed modules into the bytecode Program.
+type Compiler struct {
+	program *Program
+	links   linker.Links
+
+	modules   map[string]*module
+	order     []*module
+	functions map[*ast.Declaration]int
+	globals   map[*ast.Declaration]int
+
+	debug bool
+}
+
+type module struct {
+	name  string
+	decls map[string]*ast.Declaration
+	order []*ast.Declaration
+}
+
+type scope struct {
+	module   *module
+	function *Function
+	locals   map[string]int
+
+	// blocks holds the current block and enclosing ones,
+	// and loops holds the enclosing loops.
+	blocks []*block
+	loops  []*loop
+}
+
+// block holds code offsets of labels declared in the block,
+// and jumps to patch once all labels of the block are known.
+type block struct {
+	labels map[string]int
+	jumps  []jump
+}
+
+// jump is the jump instruction at offset in the code,
+// which operand must be patched with the offset of label.
+type jump struct {
+	offset int
+	label  string
+}
+
+// loop holds the offset continue jumps to,
+// and offsets of break jumps to patch once the end of the loop is known.
+type loop struct {
+	start  int
+	breaks []int
+}
+
+// New returns a new pointer to Compiler.
+func New(debug bool) *Compiler {
+	return &Compiler{debug: debug}
+}
+
+// Compile compiles nodes returned by parser.Parser.Parse into the program.
+// Functions and variables marked with declare or link("module")
+// refer to the definitions given by links, returned by linker.Linker.Link.
+//
+// The entry point of the program is the main function of the "main" module.
+// Returns ErrNoMainModule or ErrNoMainFunction if there's no entry point.
+func (c *Compiler) Compile(nodes []ast.Node, links linker.Links) (*Program, error) {
+	c.reset()
+	c.links = links
+	for _, n := range nodes {
+		if err := c.addModule(n); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range c.order {
+		for _, d := range m.order {
+			if fn, ok := d.Value.(ast.FunctionValue); ok {
+				if fn.Body == nil && (d.Declared || d.Linked) {
+					continue
+				}
+				c.functions[d] = c.addFunction(d.Name, m.name, len(fn.Parameters))
+				continue
+			}
+			if d.Declared || d.Linked {
+				continue
+			}
+			c.globals[d] = len(c.program.Globals)
+			c.program.Globals = append(c.program.Globals, &Global{
+				Name:   d.Name,
+				Module: m.name,
+				Init:   c.addFunction(d.Name+"$init", m.name, 0),
+			})
+		}
+	}
+
+	for _, m := range c.order {
+		for _, d := range m.order {
+			if err := c.declaration(m, d); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	m, ok := c.modules["main"]
+	if !ok {
+		return nil, ErrNoMainModule
+	}
+	d, ok := m.decls["main"]
+	if !ok {
+		return nil, ErrNoMainFunction
+	}
+	entry, ok := c.functions[d]
+	if !ok {
+		return nil, ErrNoMainFunction
+	}
+	c.program.Entry = entry
+	return c.program, nil
+}
+
+func (c *Compiler) addModule(n ast.Node) error {
+	if _, ok := n.(ast.ImportDeclaration); ok {
+		return nil
+	}
+	md, ok := n.(ast.ModuleDeclaration)
+	if !ok {
+		return fmt.Errorf("unexpected top-level node %T", n)
+	}
+	if _, ok := c.modules[md.Name]; ok {
+		return fmt.Errorf("module %q is declared more than once", md.Name)
+	}
+	m := &module{name: md.Name, decls: map[string]*ast.Declaration{}}
+	c.modules[md.Name] = m
+	c.order = append(c.order, m)
+	for _, child := range md.Body {
+		switch child := child.(type) {
+		case *ast.Declaration:
+			m.decls[child.Name] = child
+			m.order = append(m.order, child)
+		case ast.ModuleDeclaration:
+			if err := c.addModule(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) addFunction(name, module string, arity int) int {
+	c.program.Functions = append(c.program.Functions, &Function{
+		Name:      name,
+		Module:    module,
+		Arity:     arity,
+		NumLocals: arity,
+	})
+	return len(c.program.Functions) - 1
+}
+
+func (c *Compiler) declaration(m *module, d *ast.Declaration) error {
+	if idx, ok := c.globals[d]; ok {
+		g := c.program.Globals[idx]
+		s := &scope{module: m, function: c.program.Functions[g.Init], locals: map[string]int{}}
+		if err := c.value(s, d.Value); err != nil {
+			return fmt.Errorf("in declaration %s: %w", d.Name, err)
+		}
+		return c.emit(s, OpReturn)
+	}
+
+	idx, ok := c.functions[d]
+	if !ok {
+		return nil
+	}
+	fn := d.Value.(ast.FunctionValue)
+	s := &scope{module: m, function: c.program.Functions[idx], locals: map[string]int{}}
+	for i, p := range fn.Parameters {
+		s.locals[p.Identifier] = i
+	}
+	c.outputf("compiling %s.%s\n", m.name, d.Name)
+	if err := c.body(s, fn.Body); err != nil {
+		return fmt.Errorf("in function %s: %w", d.Name, err)
+	}
+	return c.emit(s, OpReturnNil)
+}
+
+// body compiles statements of the function body or a nested block.
+// Locals declared in the block get new slots and aren't visible after it.
+//
+// Jumps to labels declared in the block are patched at its end,
+// while other jumps are passed to the enclosing block.
+func (c *Compiler) body(s *scope, body []ast.Node) error {
+	b := &block{labels: map[string]int{}}
+	s.blocks = append(s.blocks, b)
+	outer := s.locals
+	s.locals = maps.Clone(outer)
+	defer func() {
+		s.blocks = s.blocks[:len(s.blocks)-1]
+		s.locals = outer
+	}()
+
+	for _, stmt := range body {
+		if err := c.statement(s, stmt); err != nil {
+			return err
+		}
+	}
+
+	for _, j := range b.jumps {
+		target, ok := b.labels[j.label]
+		if !ok {
+			if len(s.blocks) == 1 {
+				return fmt.Errorf("undefined label %s", j.label)
+			}
+			parent := s.blocks[len(s.blocks)-2]
+			parent.jumps = append(parent.jumps, j)
+			continue
+		}
+		if err := c.patch(s, j.offset, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) statement(s *scope, stmt ast.Node) error {
+	b := s.blocks[len(s.blocks)-1]
+	switch stmt := stmt.(type) {
+	case ast.InstructionCall:
+		if err := c.instruction(s, stmt); err != nil {
+			return fmt.Errorf("%s: %w", stmt.Name, err)
+		}
+		return nil
+
+	case ast.LocalDeclaration:
+		if err := c.value(s, stmt.Value); err != nil {
+			return fmt.Errorf("local %s: %w", stmt.Name, err)
+		}
+		slot := s.function.NumLocals
+		s.function.NumLocals++
+		s.locals[stmt.Name] = slot
+		return c.emit(s, OpStoreLocal, slot)
+
+	case ast.Label:
+		if _, ok := b.labels[stmt.Name]; ok {
+			return fmt.Errorf("label %s is declared more than once", stmt.Name)
+		}
+		b.labels[stmt.Name] = len(s.function.Code)
+		return nil
+
+	case ast.Jump:
+		op, ok := jumps[stmt.Name]
+		if !ok {
+			return fmt.Errorf("unknown jump instruction %s", stmt.Name)
+		}
+		b.jumps = append(b.jumps, jump{offset: len(s.function.Code), label: stmt.Label})
+		return c.emit(s, op, 0)
+
+	case ast.IfStatement:
+		if err := c.value(s, stmt.Condition); err != nil {
+			return fmt.Errorf("condition: %w", err)
+		}
+		skip := len(s.function.Code)
+		if err := c.emit(s, OpJumpFalse, 0); err != nil {
+			return err
+		}
+		if err := c.body(s, stmt.Body); err != nil {
+			return err
+		}
+		if len(stmt.Else) == 0 {
+			return c.patch(s, skip, len(s.function.Code))
+		}
+		end := len(s.function.Code)
+		if err := c.emit(s, OpJump, 0); err != nil {
+			return err
+		}
+		if err := c.patch(s, skip, len(s.function.Code)); err != nil {
+			return err
+		}
+		if err := c.body(s, stmt.Else); err != nil {
+			return err
+		}
+		return c.patch(s, end, len(s.function.Code))
+
+	case ast.LoopStatement:
+		l := &loop{start: len(s.function.Code)}
+		if stmt.Condition != nil {
+			if err := c.value(s, stmt.Condition); err != nil {
+				return fmt.Errorf("condition: %w", err)
+			}
+			l.breaks = append(l.breaks, len(s.function.Code))
+			if err := c.emit(s, OpJumpFalse, 0); err != nil {
+				return err
+			}
+		}
+		s.loops = append(s.loops, l)
+		err := c.body(s, stmt.Body)
+		s.loops = s.loops[:len(s.loops)-1]
+		if err != nil {
+			return err
+		}
+		if err := c.emit(s, OpJump, l.start); err != nil {
+			return err
+		}
+		for _, offset := range l.breaks {
+			if err := c.patch(s, offset, len(s.function.Code)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ast.BreakStatement:
+		if len(s.loops) == 0 {
+			return errors.New("break is not in a loop")
+		}
+		l := s.loops[len(s.loops)-1]
+		l.breaks = append(l.breaks, len(s.function.Code))
+		return c.emit(s, OpJump, 0)
+
+	case ast.ContinueStatement:
+		if len(s.loops) == 0 {
+			return errors.New("continue is not in a loop")
+		}
+		return c.emit(s, OpJump, s.loops[len(s.loops)-1].start)
+	}
+	return fmt.Errorf("unexpected statement %T", stmt)
+}
+
+// patch sets the operand of the jump instruction at offset to target.
+func (c *Compiler) patch(s *scope, offset, target int) error {
+	if target >= 1<<16 {
+		return ErrTooManyOperands
+	}
+	binary.BigEndian.PutUint16(s.function.Code[offset+1:], uint16(target))
+	return nil
+}
+
+func (c *Compiler) instruction(s *scope, ic ast.InstructionCall) error {
+	if ic.IsUser {
+		args := make([]ast.Node, len(ic.Arguments))
+		for i, a := range ic.Arguments {
+			args[i] = a.Value
+		}
+		if err := c.call(s, ic.Name, args); err != nil {
+			return err
+		}
+		return c.emit(s, OpPop)
+	}
+
+	switch ic.Name {
+	case "mov":
+		if len(ic.Arguments) != 2 {
+			return fmt.Errorf("expected 2 arguments, got %d", len(ic.Arguments))
+		}
+		if err := c.value(s, ic.Arguments[1].Value); err != nil {
+			return err
+		}
+		return c.store(s, ic.Arguments[0].Value)
+
+	case "add", "sub", "mul", "div":
+		var operands []ast.InstructionCallArgument
+		switch len(ic.Arguments) {
+		case 2:
+			operands = ic.Arguments
+		case 3:
+			operands = ic.Arguments[1:]
+		default:
+			return fmt.Errorf("expected 2 or 3 arguments, got %d", len(ic.Arguments))
+		}
+		for _, a := range operands {
+			if err := c.value(s, a.Value); err != nil {
+				return err
+			}
+		}
+		if err := c.emit(s, arithmetic[ic.Name]); err != nil {
+			return err
+		}
+		return c.store(s, ic.Arguments[0].Value)
+
+	case "cmp":
+		if len(ic.Arguments) != 2 {
+			return fmt.Errorf("expected 2 arguments, got %d", len(ic.Arguments))
+		}
+		for _, a := range ic.Arguments {
+			if err := c.value(s, a.Value); err != nil {
+				return err
+			}
+		}
+		return c.emit(s, OpCmp)
+
+	case "ret":
+		switch len(ic.Arguments) {
+		case 0:
+			return c.emit(s, OpReturnNil)
+		case 1:
+			if err := c.value(s, ic.Arguments[0].Value); err != nil {
+				return err
+			}
+			return c.emit(s, OpReturn)
+		}
+		return fmt.Errorf("expected at most 1 argument, got %d", len(ic.Arguments))
+
+	case "stdout", "stderr":
+		if len(ic.Arguments) > 255 {
+			return ErrTooManyOperands
+		}
+		for _, a := range ic.Arguments {
+			if err := c.value(s, a.Value); err != nil {
+				return err
+			}
+		}
+		op := OpStdout
+		if ic.Name == "stderr" {
+			op = OpStderr
+		}
+		return c.emit(s, op, len(ic.Arguments))
+	}
+	return errors.New("unknown instruction")
+}
+
+// call compiles the call of the function name with args,
+// leaving the returned value on the stack.
+func (c *Compiler) call(s *scope, name string, args []ast.Node) error {
+	d, ok := s.module.decls[name]
+	if !ok {
+		return fmt.Errorf("undefined function %s", name)
+	}
+	target, err := c.resolve(d)
+	if err != nil {
+		return err
+	}
+	idx, ok := c.functions[target]
+	if !ok {
+		return fmt.Errorf("%s is not a function", name)
+	}
+	if arity := c.program.Functions[idx].Arity; arity != len(args) {
+		return fmt.Errorf("function %s expects %d arguments, got %d", name, arity, len(args))
+	}
+	for _, a := range args {
+		if err := c.value(s, a); err != nil {
+			return err
+		}
+	}
+	return c.emit(s, OpCall, idx, len(args))
+}
+
+var arithmetic = map[string]Opcode{
+	"add": OpAdd,
+	"sub": OpSub,
+	"mul": OpMul,
+	"div": OpDiv,
+}
+
+var comparisons = map[string]Opcode{
+	"==": OpEqual,
+	"!=": OpNotEqual,
+	"<":  OpLess,
+	">":  OpGreater,
+	"<=": OpLessEqual,
+	">=": OpGreaterEqual,
+}
+
+var jumps = map[string]Opcode{
+	"jmp": OpJump,
+	"je":  OpJumpEqual,
+	"jne": OpJumpNotEqual,
+	"jl":  OpJumpLess,
+	"jg":  OpJumpGreater,
+	"jle": OpJumpLessEqual,
+	"jge": OpJumpGreaterEqual,
+}
+
+func (c *Compiler) value(s *scope, n ast.Node) error {
+	if v, ok := constant(n); ok {
+		return c.emit(s, OpConst, c.addConstant(v))
+	}
+
+	switch n := n.(type) {
+	case ast.Value:
+		switch {
+		case n.Copied:
+			if err := c.value(s, n.ValueNode); err != nil {
+				return err
+			}
+			return c.emit(s, OpCopy)
+		case n.ValueNode != nil:
+			return c.value(s, n.ValueNode)
+		case n.Kind == token.Identifier:
+			return c.load(s, n.Value)
+		}
+		v, err := value.FromLiteral(n.Kind, n.Value)
+		if err != nil {
+			return err
+		}
+		return c.emit(s, OpConst, c.addConstant(v))
+
+	case ast.CallExpression:
+		return c.call(s, n.Name, n.Arguments)
+
+	case ast.BinaryExpression:
+		op, ok := arithmetic[value.Instruction(n.Operator)]
+		if !ok {
+			op, ok = comparisons[n.Operator]
+		}
+		if !ok || len(n.Children) != 2 {
+			return fmt.Errorf("can't compile binary expression %s with %d operands", n.Operator, len(n.Children))
+		}
+		for _, child := range n.Children {
+			if err := c.value(s, child); err != nil {
+				return err
+			}
+		}
+		return c.emit(s, op)
+
+	case ast.ArrayValue:
+		for _, e := range n.Elements {
+			if err := c.value(s, e.Value); err != nil {
+				return err
+			}
+		}
+		return c.emit(s, OpArray, len(n.Elements))
+	}
+	return fmt.Errorf("can't compile value %T", n)
+}
+
+// constant reports whether n is a literal or an array of literals,
+// and returns its value.
+func constant(n ast.Node) (value.Value, bool) {
+	switch n := n.(type) {
+	case ast.Value:
+		if n.Copied || n.Kind == token.Identifier {
+			return value.Value{}, false
+		}
+		if n.ValueNode != nil {
+			return constant(n.ValueNode)
+		}
+		v, err := value.FromLiteral(n.Kind, n.Value)
+		return v, err == nil
+	case ast.ArrayValue:
+		elements := make([]value.Value, len(n.Elements))
+		for i, e := range n.Elements {
+			v, ok := constant(e.Value)
+			if !ok {
+				return value.Value{}, false
+			}
+			elements[i] = v
+		}
+		return value.NewArray(elements), true
+	}
+	return value.Value{}, false
+}
+
+func (c *Compiler) load(s *scope, name string) error {
+	if slot, ok := s.locals[name]; ok {
+		return c.emit(s, OpLoadLocal, slot)
+	}
+	if d, ok := s.module.decls[name]; ok {
+		target, err := c.resolve(d)
+		if err != nil {
+			return err
+		}
+		idx, ok := c.globals[target]
+		if !ok {
+			return fmt.Errorf("function %s can't be used as a value", name)
+		}
+		return c.emit(s, OpLoadGlobal, idx)
+	}
+	return fmt.Errorf("undefined identifier %s", name)
+}
+
+func (c *Compiler) store(s *scope, dst ast.Node) error {
+	ident, ok := dst.(ast.Value)
+	if !ok || ident.Kind != token.Identifier {
+		return errors.New("destination must be an identifier")
+	}
+	if slot, ok := s.locals[ident.Value]; ok {
+		return c.emit(s, OpStoreLocal, slot)
+	}
+	if d, ok := s.module.decls[ident.Value]; ok {
+		target, err := c.resolve(d)
+		if err != nil {
+			return err
+		}
+		idx, ok := c.globals[target]
+		if !ok {
+			return fmt.Errorf("can't assign to function %s", ident.Value)
+		}
+		return c.emit(s, OpStoreGlobal, idx)
+	}
+	return fmt.Errorf("undefined identifier %s", ident.Value)
+}
+
+// resolve returns the definition d is linked to, if d is marked with declare or link("module").
+// Otherwise, d is returned as is.
+func (c *Compiler) resolve(d *ast.Declaration) (*ast.Declaration, error) {
+	if !d.Declared && !d.Linked {
+		return d, nil
+	}
+	def, ok := c.links[d]
+	if !ok {
+		return nil, fmt.Errorf("%s isn't linked to its definition", d.Name)
+	}
+	return def.Declaration, nil
+}
+
+func (c *Compiler) addConstant(v value.Value) int {
+	if v.Kind != value.Array {
+		for i, existing := range c.program.Constants {
+			if existing.Kind == v.Kind && existing.String() == v.String() {
+				return i
+			}
+		}
+	}
+	c.program.Constants = append(c.program.Constants, v)
+	return len(c.program.Constants) - 1
+}
+
+func (c *Compiler) emit(s *scope, op Opcode, operands ...int) error {
+	d, err := Lookup(op)
+	if err != nil {
+		return err
+	}
+	for i, o := range operands {
+		if o < 0 || o >= 1<<(8*d.Operands[i]) {
+			return ErrTooManyOperands
+		}
+	}
+	s.function.Code = append(s.function.Code, Make(op, operands...)...)
+	return nil
+}
+
+func (c *Compiler) outputf(format string, v ...any) {
+	if c.debug {
+		log.Printf(format, v...)
+	}
+}
+
+func (c *Compiler) reset() {
+	c.program = &Program{}
+	c.modules = map[string]*module{}
+	c.order = nil
+	c.functions = map[*ast.Declaration]int{}
+	c.globals = map[*ast.Declaration]int{}
+}
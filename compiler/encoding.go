@@ -0,0 +1,42 @@
+package compiler
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+)
+
+// Magic is written at the start of encoded programs.
+const Magic = "DLBC\x01"
+
+// ErrNotProgram is returned by Decode if the input doesn't start with Magic.
+var ErrNotProgram = errors.New("input is not a compiled program")
+
+// Encode writes p into w in the binary format read by Decode.
+func Encode(w io.Writer, p *Program) error {
+	if _, err := io.WriteString(w, Magic); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(p)
+}
+
+// Decode reads the program written by Encode from r,
+// validating it with Program.Validate.
+//
+// Returns ErrNotProgram if r doesn't contain the encoded program.
+func Decode(r io.Reader) (*Program, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(Magic))
+	if _, err := io.ReadFull(br, header); err != nil || string(header) != Magic {
+		return nil, ErrNotProgram
+	}
+	p := &Program{}
+	if err := gob.NewDecoder(br).Decode(p); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,116 @@
+package compiler_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/compiler"
+	"github.com/dywoq/dywoqlang/parser"
+	"github.com/dywoq/dywoqlang/scanner"
+)
+
+const source = `"main": {
+	base i32 40
+
+	main i32 () {
+		x i32 base + 2;
+		if x > 41 {
+			stdout "big", x;
+		}
+		ret x;
+	}
+}
+`
+
+func compile(t *testing.T, src string) *compiler.Program {
+	t.Helper()
+	tokens, err := scanner.New(false).Scan(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parser.New(false).Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := compiler.New(false).Compile(nodes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestEncodeDecode(t *testing.T) {
+	p := compile(t, source)
+	var buf bytes.Buffer
+	if err := compiler.Encode(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := compiler.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got strings.Builder
+	if err := compiler.Disassemble(&want, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := compiler.Disassemble(&got, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("decoded program\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(p *compiler.Program)
+		err    error
+	}{
+		{
+			name: "truncated code without constants",
+			change: func(p *compiler.Program) {
+				for _, f := range p.Functions {
+					f.Code = f.Code[:len(f.Code)-2]
+				}
+				p.Constants = nil
+			},
+			err: compiler.ErrInvalidProgram,
+		},
+		{
+			name:   "entry out of range",
+			change: func(p *compiler.Program) { p.Entry = len(p.Functions) },
+			err:    compiler.ErrInvalidProgram,
+		},
+		{
+			name:   "initializer out of range",
+			change: func(p *compiler.Program) { p.Globals[0].Init = -1 },
+			err:    compiler.ErrInvalidProgram,
+		},
+		{
+			name:   "too few locals",
+			change: func(p *compiler.Program) { p.Functions[p.Entry].NumLocals = 0 },
+			err:    compiler.ErrInvalidProgram,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := compile(t, source)
+			tt.change(p)
+			var buf bytes.Buffer
+			if err := compiler.Encode(&buf, p); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := compiler.Decode(&buf); !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := compiler.Decode(strings.NewReader("#!not bytecode")); !errors.Is(err, compiler.ErrNotProgram) {
+		t.Errorf("got error %v, want %v", err, compiler.ErrNotProgram)
+	}
+}
@@ -0,0 +1,19 @@
+package compiler
+
+import "errors"
+
+var (
+	// ErrNoMainModule is returned by the compiler if there's no "main" module.
+	ErrNoMainModule = errors.New("no \"main\" module")
+
+	// ErrNoMainFunction is returned by the compiler if the "main" module has no main function.
+	ErrNoMainFunction = errors.New("no main function in \"main\" module")
+
+	// ErrTooManyOperands is returned by the compiler if an operand doesn't fit its width,
+	// such as more than 65535 constants.
+	ErrTooManyOperands = errors.New("operand doesn't fit into the instruction")
+
+	// ErrInvalidProgram is returned by Program.Validate if the program refers to functions,
+	// constants, globals, locals or code offsets it doesn't have.
+	ErrInvalidProgram = errors.New("invalid program")
+)
@@ -0,0 +1,227 @@
+package compiler
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Opcode is a single bytecode instruction.
+type Opcode byte
+
+const (
+	// OpConst pushes the constant from the constant pool.
+	// Operand: u16 constant index.
+	OpConst Opcode = iota
+
+	// OpLoadLocal pushes the local variable of the current frame.
+	// Operand: u16 local slot.
+	OpLoadLocal
+
+	// OpStoreLocal pops the value into the local variable of the current frame.
+	// Operand: u16 local slot.
+	OpStoreLocal
+
+	// OpLoadGlobal pushes the global variable, initializing it on first use.
+	// Operand: u16 global index.
+	OpLoadGlobal
+
+	// OpStoreGlobal pops the value into the global variable.
+	// Operand: u16 global index.
+	OpStoreGlobal
+
+	// OpAdd pops y and x, then pushes x+y.
+	OpAdd
+
+	// OpSub pops y and x, then pushes x-y.
+	OpSub
+
+	// OpMul pops y and x, then pushes x*y.
+	OpMul
+
+	// OpDiv pops y and x, then pushes x/y.
+	OpDiv
+
+	// OpArray pops n elements and pushes the array holding them.
+	// Operand: u16 number of elements.
+	OpArray
+
+	// OpCopy replaces the top of the stack with its deep copy.
+	OpCopy
+
+	// OpCall calls the function with the arguments on top of the stack,
+	// then pushes the returned value.
+	// Operands: u16 function index, u8 number of arguments.
+	OpCall
+
+	// OpReturn pops the value and returns it from the current function.
+	OpReturn
+
+	// OpReturnNil returns nil from the current function.
+	OpReturnNil
+
+	// OpPop discards the top of the stack.
+	OpPop
+
+	// OpStdout pops n values and prints them to the standard output.
+	// Operand: u8 number of values.
+	OpStdout
+
+	// OpStderr pops n values and prints them to the standard error.
+	// Operand: u8 number of values.
+	OpStderr
+
+	// OpCmp pops y and x, then stores the result of comparing x with y in the current frame.
+	OpCmp
+
+	// OpJump jumps to the offset in the code of the current function.
+	// Operand: u16 offset.
+	OpJump
+
+	// OpJumpEqual jumps if the last comparison found x equal to y.
+	// Operand: u16 offset.
+	OpJumpEqual
+
+	// OpJumpNotEqual jumps if the last comparison found x not equal to y.
+	// Operand: u16 offset.
+	OpJumpNotEqual
+
+	// OpJumpLess jumps if the last comparison found x less than y.
+	// Operand: u16 offset.
+	OpJumpLess
+
+	// OpJumpGreater jumps if the last comparison found x greater than y.
+	// Operand: u16 offset.
+	OpJumpGreater
+
+	// OpJumpLessEqual jumps if the last comparison found x less than or equal to y.
+	// Operand: u16 offset.
+	OpJumpLessEqual
+
+	// OpJumpGreaterEqual jumps if the last comparison found x greater than or equal to y.
+	// Operand: u16 offset.
+	OpJumpGreaterEqual
+
+	// OpJumpFalse pops the bool and jumps if it's false.
+	// Operand: u16 offset.
+	OpJumpFalse
+
+	// OpEqual pops y and x, then pushes x==y.
+	OpEqual
+
+	// OpNotEqual pops y and x, then pushes x!=y.
+	OpNotEqual
+
+	// OpLess pops y and x, then pushes x<y.
+	OpLess
+
+	// OpGreater pops y and x, then pushes x>y.
+	OpGreater
+
+	// OpLessEqual pops y and x, then pushes x<=y.
+	OpLessEqual
+
+	// OpGreaterEqual pops y and x, then pushes x>=y.
+	OpGreaterEqual
+)
+
+// Definition describes the opcode name and widths of its operands in bytes.
+type Definition struct {
+	Name     string
+	Operands []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConst:       {"const", []int{2}},
+	OpLoadLocal:   {"load_local", []int{2}},
+	OpStoreLocal:  {"store_local", []int{2}},
+	OpLoadGlobal:  {"load_global", []int{2}},
+	OpStoreGlobal: {"store_global", []int{2}},
+	OpAdd:         {"add", nil},
+	OpSub:         {"sub", nil},
+	OpMul:         {"mul", nil},
+	OpDiv:         {"div", nil},
+	OpArray:       {"array", []int{2}},
+	OpCopy:        {"copy", nil},
+	OpCall:        {"call", []int{2, 1}},
+	OpReturn:      {"return", nil},
+	OpReturnNil:   {"return_nil", nil},
+	OpPop:         {"pop", nil},
+	OpStdout:      {"stdout", []int{1}},
+	OpStderr:      {"stderr", []int{1}},
+
+	OpCmp:              {"cmp", nil},
+	OpJump:             {"jump", []int{2}},
+	OpJumpEqual:        {"jump_eq", []int{2}},
+	OpJumpNotEqual:     {"jump_ne", []int{2}},
+	OpJumpLess:         {"jump_lt", []int{2}},
+	OpJumpGreater:      {"jump_gt", []int{2}},
+	OpJumpLessEqual:    {"jump_le", []int{2}},
+	OpJumpGreaterEqual: {"jump_ge", []int{2}},
+	OpJumpFalse:        {"jump_false", []int{2}},
+	OpEqual:            {"eq", nil},
+	OpNotEqual:         {"ne", nil},
+	OpLess:             {"lt", nil},
+	OpGreater:          {"gt", nil},
+	OpLessEqual:        {"le", nil},
+	OpGreaterEqual:     {"ge", nil},
+}
+
+// Lookup returns the definition of op.
+//
+// Returns an error if op is unknown.
+func Lookup(op Opcode) (*Definition, error) {
+	d, ok := definitions[op]
+	if !ok {
+		return nil, fmt.Errorf("unknown opcode %d", op)
+	}
+	return d, nil
+}
+
+// Make encodes op and its operands into the bytecode.
+//
+// Returns nil if op is unknown.
+func Make(op Opcode, operands ...int) []byte {
+	d, ok := definitions[op]
+	if !ok {
+		return nil
+	}
+	length := 1
+	for _, w := range d.Operands {
+		length += w
+	}
+	code := make([]byte, length)
+	code[0] = byte(op)
+	offset := 1
+	for i, o := range operands {
+		switch d.Operands[i] {
+		case 1:
+			code[offset] = byte(o)
+		case 2:
+			binary.BigEndian.PutUint16(code[offset:], uint16(o))
+		}
+		offset += d.Operands[i]
+	}
+	return code
+}
+
+// ReadOperands decodes operands of d from code,
+// returning them and the number of bytes read.
+func ReadOperands(d *Definition, code []byte) ([]int, int) {
+	operands := make([]int, len(d.Operands))
+	offset := 0
+	for i, w := range d.Operands {
+		switch w {
+		case 1:
+			operands[i] = int(code[offset])
+		case 2:
+			operands[i] = int(binary.BigEndian.Uint16(code[offset:]))
+		}
+		offset += w
+	}
+	return operands, offset
+}
+
+// ReadUint16 decodes the big-endian u16 operand from code.
+func ReadUint16(code []byte) int {
+	return int(binary.BigEndian.Uint16(code))
+}
@@ -0,0 +1,166 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dywoq/dywoqlang/value"
+)
+
+// Program is a compiled program ready to be executed by the virtual machine.
+type Program struct {
+	Constants []value.Value `json:"constants"`
+	Functions []*Function   `json:"functions"`
+	Globals   []*Global     `json:"globals"`
+	Entry     int           `json:"entry"`
+}
+
+// Function is a compiled function.
+//
+// The first Arity locals are parameters of the function.
+type Function struct {
+	Name      string `json:"name"`
+	Module    string `json:"module"`
+	Arity     int    `json:"arity"`
+	NumLocals int    `json:"num_locals"`
+	Code      []byte `json:"code"`
+}
+
+// Global is a module-level variable.
+//
+// Init is an index of the function computing the initial value.
+type Global struct {
+	Name   string `json:"name"`
+	Module string `json:"module"`
+	Init   int    `json:"init"`
+}
+
+// Validate checks that p can be executed by the virtual machine:
+// the entry point and initializers of globals are functions without parameters,
+// and the code of each function consists of known instructions
+// whose operands refer to existing constants, globals, locals, functions and instructions.
+//
+// Returns ErrInvalidProgram describing the first problem found.
+func (p *Program) Validate() error {
+	if p.Entry < 0 || p.Entry >= len(p.Functions) {
+		return fmt.Errorf("%w: entry %d is not a function", ErrInvalidProgram, p.Entry)
+	}
+	for i, g := range p.Globals {
+		if g == nil {
+			return fmt.Errorf("%w: global %d is missing", ErrInvalidProgram, i)
+		}
+		if g.Init < 0 || g.Init >= len(p.Functions) {
+			return fmt.Errorf("%w: initializer %d of global %s.%s is not a function", ErrInvalidProgram, g.Init, g.Module, g.Name)
+		}
+	}
+	for i, f := range p.Functions {
+		if f == nil {
+			return fmt.Errorf("%w: function %d is missing", ErrInvalidProgram, i)
+		}
+		if err := p.validateFunction(f); err != nil {
+			return fmt.Errorf("%w: function %s.%s: %w", ErrInvalidProgram, f.Module, f.Name, err)
+		}
+	}
+	if p.Functions[p.Entry].Arity != 0 {
+		return fmt.Errorf("%w: entry function can't have parameters", ErrInvalidProgram)
+	}
+	for _, g := range p.Globals {
+		if p.Functions[g.Init].Arity != 0 {
+			return fmt.Errorf("%w: initializer of global %s.%s can't have parameters", ErrInvalidProgram, g.Module, g.Name)
+		}
+	}
+	return nil
+}
+
+func (p *Program) validateFunction(f *Function) error {
+	if f.Arity < 0 || f.NumLocals < f.Arity {
+		return fmt.Errorf("%d locals can't hold %d parameters", f.NumLocals, f.Arity)
+	}
+
+	// starts holds offsets of instructions, which are the only valid jump targets.
+	starts := map[int]bool{}
+	var jumps []int
+	for ip := 0; ip < len(f.Code); {
+		starts[ip] = true
+		op := Opcode(f.Code[ip])
+		d, err := Lookup(op)
+		if err != nil {
+			return fmt.Errorf("at %d: %w", ip, err)
+		}
+		width := 0
+		for _, w := range d.Operands {
+			width += w
+		}
+		if ip+1+width > len(f.Code) {
+			return fmt.Errorf("at %d: %s is truncated", ip, d.Name)
+		}
+		operands, _ := ReadOperands(d, f.Code[ip+1:])
+
+		// limit is the number of entries the first operand indexes, if any.
+		limit := -1
+		switch op {
+		case OpConst:
+			limit = len(p.Constants)
+		case OpLoadLocal, OpStoreLocal:
+			limit = f.NumLocals
+		case OpLoadGlobal, OpStoreGlobal:
+			limit = len(p.Globals)
+		case OpCall:
+			limit = len(p.Functions)
+		case OpJump, OpJumpEqual, OpJumpNotEqual, OpJumpLess, OpJumpGreater,
+			OpJumpLessEqual, OpJumpGreaterEqual, OpJumpFalse:
+			jumps = append(jumps, ip)
+		}
+		if limit >= 0 && operands[0] >= limit {
+			return fmt.Errorf("at %d: %s operand %d is out of range", ip, d.Name, operands[0])
+		}
+		ip += 1 + width
+	}
+
+	for _, ip := range jumps {
+		target := ReadUint16(f.Code[ip+1:])
+		if !starts[target] {
+			return fmt.Errorf("at %d: jump target %d is not an instruction", ip, target)
+		}
+	}
+	return nil
+}
+
+// Disassemble writes the human-readable presentation of p into w.
+func Disassemble(w io.Writer, p *Program) error {
+	for i, c := range p.Constants {
+		if _, err := fmt.Fprintf(w, "const %04d %s %s\n", i, c.Kind, c.String()); err != nil {
+			return err
+		}
+	}
+	for i, g := range p.Globals {
+		if _, err := fmt.Fprintf(w, "global %04d %s.%s (init %d)\n", i, g.Module, g.Name, g.Init); err != nil {
+			return err
+		}
+	}
+	for i, f := range p.Functions {
+		if _, err := fmt.Fprintf(w, "\nfunction %04d %s.%s (arity %d, locals %d)\n", i, f.Module, f.Name, f.Arity, f.NumLocals); err != nil {
+			return err
+		}
+		for ip := 0; ip < len(f.Code); {
+			d, err := Lookup(Opcode(f.Code[ip]))
+			if err != nil {
+				return err
+			}
+			operands, read := ReadOperands(d, f.Code[ip+1:])
+			if _, err := fmt.Fprintf(w, "  %04d %s", ip, d.Name); err != nil {
+				return err
+			}
+			for _, o := range operands {
+				if _, err := fmt.Fprintf(w, " %d", o); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+			ip += 1 + read
+		}
+	}
+	return nil
+}
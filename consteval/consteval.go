@@ -0,0 +1,359 @@
+package consteval
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/interp"
+	"github.com/dywoq/dywoqlang/linker"
+	"github.com/dywoq/dywoqlang/token"
+	"github.com/dywoq/dywoqlang/value"
+)
+
+// Evaluator folds consteval expressions at compile time.
+//
+// consteval expressions may contain literals, arrays, arithmetic,
+// module declarations that are never assigned at runtime,
+// and calls to pure functions.
+// Pure functions are executed by the interpreter in the pure mode,
+// and see module declarations with their initial values.
+type Evaluator struct {
+	interp  *interp.Interpreter
+	modules map[string]*module
+	errors  diag.Diagnostics
+
+	debug bool
+}
+
+type module struct {
+	name       string
+	decls      map[string]*ast.Declaration
+	mutated    map[string]bool
+	values     map[string]value.Value
+	evaluating map[string]bool
+}
+
+type env struct {
+	module   *module
+	function string
+	runtime  map[string]bool
+}
+
+// New returns a new pointer to Evaluator.
+func New(debug bool) *Evaluator {
+	return &Evaluator{debug: debug}
+}
+
+// Evaluate evaluates every consteval expression in nodes returned by parser.Parser.Parse,
+// replacing it with the resulting ast.Value or ast.ArrayValue in place.
+// Pure functions calling declared or linked functions use the definitions given by links,
+// returned by linker.Linker.Link.
+//
+// Returns diag.Diagnostics if some expressions can't be evaluated at compile time;
+// such expressions are left untouched.
+func (c *Evaluator) Evaluate(nodes []ast.Node, links linker.Links) error {
+	c.reset()
+	var modules []ast.ModuleDeclaration
+	for _, n := range nodes {
+		if md, ok := n.(ast.ModuleDeclaration); ok {
+			modules = append(modules, c.collect(md)...)
+		}
+	}
+	if err := c.interp.Load(nodes, links); err != nil {
+		return err
+	}
+
+	for _, md := range modules {
+		m := c.modules[md.Name]
+		for _, n := range md.Body {
+			d, ok := n.(*ast.Declaration)
+			if !ok {
+				continue
+			}
+			fn, ok := d.Value.(ast.FunctionValue)
+			if !ok {
+				d.Value = c.fold(env{module: m}, d.Value)
+				continue
+			}
+			c.body(env{module: m, function: d.Name, runtime: runtimeNames(fn)}, fn.Body)
+		}
+	}
+
+	if len(c.errors) != 0 {
+		return c.errors
+	}
+	return nil
+}
+
+func (c *Evaluator) collect(md ast.ModuleDeclaration) []ast.ModuleDeclaration {
+	m := &module{
+		name:       md.Name,
+		decls:      map[string]*ast.Declaration{},
+		mutated:    map[string]bool{},
+		values:     map[string]value.Value{},
+		evaluating: map[string]bool{},
+	}
+	c.modules[md.Name] = m
+	modules := []ast.ModuleDeclaration{md}
+	for _, n := range md.Body {
+		switch n := n.(type) {
+		case *ast.Declaration:
+			m.decls[n.Name] = n
+			if fn, ok := n.Value.(ast.FunctionValue); ok {
+				locals := runtimeNames(fn)
+				for _, name := range destinations(fn) {
+					if !locals[name] {
+						m.mutated[name] = true
+					}
+				}
+			}
+		case ast.ModuleDeclaration:
+			modules = append(modules, c.collect(n)...)
+		}
+	}
+	return modules
+}
+
+// body folds consteval expressions in statements of body,
+// including conditions and bodies of nested blocks.
+func (c *Evaluator) body(e env, body []ast.Node) {
+	for idx, stmt := range body {
+		switch stmt := stmt.(type) {
+		case ast.InstructionCall:
+			for i, a := range stmt.Arguments {
+				folded := c.fold(e, a.Value)
+				stmt.Arguments[i].Value = folded
+				if v, ok := a.Value.(ast.Value); ok && v.Consteval {
+					stmt.Arguments[i].Kind = kindOf(folded)
+				}
+			}
+		case ast.LocalDeclaration:
+			stmt.Value = c.fold(e, stmt.Value)
+			body[idx] = stmt
+		case ast.IfStatement:
+			stmt.Condition = c.fold(e, stmt.Condition)
+			c.body(e, stmt.Body)
+			c.body(e, stmt.Else)
+			body[idx] = stmt
+		case ast.LoopStatement:
+			stmt.Condition = c.fold(e, stmt.Condition)
+			c.body(e, stmt.Body)
+			body[idx] = stmt
+		}
+	}
+}
+
+// fold replaces consteval expressions inside n with their values.
+func (c *Evaluator) fold(e env, n ast.Node) ast.Node {
+	switch n := n.(type) {
+	case ast.Value:
+		if n.Consteval {
+			v, err := c.eval(e, n.ValueNode)
+			if err != nil {
+				c.errorf(e, n, err)
+				return n
+			}
+			c.outputf("folded consteval expression into %s\n", v)
+			return toNode(v, n.Span)
+		}
+		if n.ValueNode != nil {
+			n.ValueNode = c.fold(e, n.ValueNode)
+		}
+		return n
+	case ast.ArrayValue:
+		for i, el := range n.Elements {
+			n.Elements[i].Value = c.fold(e, el.Value)
+		}
+		return n
+	case ast.BinaryExpression:
+		for i, child := range n.Children {
+			n.Children[i] = c.fold(e, child)
+		}
+		return n
+	case ast.CallExpression:
+		for i, arg := range n.Arguments {
+			n.Arguments[i] = c.fold(e, arg)
+		}
+		return n
+	}
+	return n
+}
+
+func (c *Evaluator) eval(e env, n ast.Node) (value.Value, error) {
+	switch n := n.(type) {
+	case ast.Value:
+		switch {
+		case n.Copied:
+			v, err := c.eval(e, n.ValueNode)
+			return v.Copy(), err
+		case n.ValueNode != nil:
+			return c.eval(e, n.ValueNode)
+		case n.Kind == token.Identifier:
+			return c.identifier(e, n.Value)
+		}
+		return value.FromLiteral(n.Kind, n.Value)
+
+	case ast.ArrayValue:
+		elements := make([]value.Value, len(n.Elements))
+		for i, el := range n.Elements {
+			v, err := c.eval(e, el.Value)
+			if err != nil {
+				return value.Value{}, err
+			}
+			elements[i] = v
+		}
+		return value.NewArray(elements), nil
+
+	case ast.BinaryExpression:
+		if len(n.Children) != 2 {
+			return value.Value{}, fmt.Errorf("binary expression %s expects 2 operands, got %d", n.Operator, len(n.Children))
+		}
+		x, err := c.eval(e, n.Children[0])
+		if err != nil {
+			return value.Value{}, err
+		}
+		y, err := c.eval(e, n.Children[1])
+		if err != nil {
+			return value.Value{}, err
+		}
+		return value.Binary(n.Operator, x, y)
+
+	case ast.CallExpression:
+		args := make([]value.Value, len(n.Arguments))
+		for i, arg := range n.Arguments {
+			v, err := c.eval(e, arg)
+			if err != nil {
+				return value.Value{}, err
+			}
+			args[i] = v
+		}
+		v, err := c.interp.Call(e.module.name, n.Name, args)
+		if err != nil {
+			if errors.Is(err, interp.ErrImpure) || errors.Is(err, interp.ErrStepLimit) {
+				return value.Value{}, fmt.Errorf("can't call %s at compile time: %w", n.Name, err)
+			}
+			return value.Value{}, err
+		}
+		return v, nil
+	}
+	return value.Value{}, fmt.Errorf("can't evaluate %T at compile time", n)
+}
+
+func (c *Evaluator) identifier(e env, name string) (value.Value, error) {
+	if e.runtime[name] {
+		return value.Value{}, fmt.Errorf("%w %s", ErrRuntimeValue, name)
+	}
+	m := e.module
+	if v, ok := m.values[name]; ok {
+		return v, nil
+	}
+	d, ok := m.decls[name]
+	switch {
+	case !ok:
+		return value.Value{}, fmt.Errorf("undefined identifier %s", name)
+	case m.mutated[name]:
+		return value.Value{}, fmt.Errorf("%w %s, since it's assigned at runtime", ErrRuntimeValue, name)
+	case m.evaluating[name]:
+		return value.Value{}, fmt.Errorf("initialization cycle of %s", name)
+	}
+	if _, ok := d.Value.(ast.FunctionValue); ok {
+		return value.Value{}, fmt.Errorf("function %s can't be used as a value", name)
+	}
+
+	m.evaluating[name] = true
+	defer delete(m.evaluating, name)
+	v, err := c.eval(env{module: m}, d.Value)
+	if err != nil {
+		return value.Value{}, err
+	}
+	m.values[name] = v
+	return v, nil
+}
+
+// runtimeNames returns parameters and local variables of fn,
+// which values are only known at runtime.
+func runtimeNames(fn ast.FunctionValue) map[string]bool {
+	names := map[string]bool{}
+	for _, p := range fn.Parameters {
+		names[p.Identifier] = true
+	}
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if l, ok := n.(ast.LocalDeclaration); ok {
+			names[l.Name] = true
+		}
+		return true
+	})
+	return names
+}
+
+// destinations returns names written by instructions of fn.
+func destinations(fn ast.FunctionValue) []string {
+	var names []string
+	ast.Inspect(fn, func(n ast.Node) bool {
+		ic, ok := n.(ast.InstructionCall)
+		if !ok || ic.IsUser || len(ic.Arguments) == 0 {
+			return true
+		}
+		switch ic.Name {
+		case "mov", "add", "sub", "mul", "div":
+			if v, ok := ic.Arguments[0].Value.(ast.Value); ok && v.Kind == token.Identifier {
+				names = append(names, v.Value)
+			}
+		}
+		return false
+	})
+	return names
+}
+
+// toNode converts v into the literal node with the span of the folded expression,
+// so later errors about the value point to the expression.
+func toNode(v value.Value, span ast.Span) ast.Node {
+	switch v.Kind {
+	case value.Integer:
+		return ast.Value{Span: span, Kind: token.Integer, Value: strconv.FormatInt(v.Int, 10)}
+	case value.Float:
+		return ast.Value{Span: span, Kind: token.Float, Value: strconv.FormatFloat(v.Float, 'f', -1, 64)}
+	case value.String:
+		return ast.Value{Span: span, Kind: token.String, Value: v.Str}
+	case value.Bool:
+		return ast.Value{Span: span, Kind: token.BoolConstant, Value: strconv.FormatBool(v.Bool)}
+	case value.Array:
+		elements := make([]ast.ArrayElement, len(v.Elements))
+		for i, el := range v.Elements {
+			elements[i] = ast.ArrayElement{Span: span, Value: toNode(el, span)}
+		}
+		return ast.ArrayValue{Span: span, MaxSize: len(elements), Elements: elements}
+	}
+	return ast.Value{Span: span, Kind: token.Special, Value: "nil"}
+}
+
+func kindOf(n ast.Node) token.Kind {
+	if v, ok := n.(ast.Value); ok {
+		return v.Kind
+	}
+	return token.Keyword
+}
+
+func (c *Evaluator) errorf(e env, n ast.Node, err error) {
+	d := diag.New(diag.Error, diag.CodeConsteval, err.Error())
+	c.errors = append(c.errors, d.Span(n).In(e.module.name, e.function))
+}
+
+func (c *Evaluator) outputf(format string, v ...any) {
+	if c.debug {
+		log.Printf(format, v...)
+	}
+}
+
+func (c *Evaluator) reset() {
+	c.interp = interp.New(c.debug)
+	c.interp.SetPure(true)
+	c.interp.SetOutput(io.Discard, io.Discard)
+	c.modules = map[string]*module{}
+	c.errors = nil
+}
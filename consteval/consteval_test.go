@@ -0,0 +1,123 @@
+package consteval_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/consteval"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/internal/parsetest"
+)
+
+// declaration returns the value of the module declaration name of main.
+func declaration(t *testing.T, nodes []ast.Node, name string) ast.Node {
+	t.Helper()
+	for _, n := range nodes[0].(ast.ModuleDeclaration).Body {
+		if d, ok := n.(*ast.Declaration); ok && d.Name == name {
+			return d.Value
+		}
+	}
+	t.Fatalf("no declaration %s", name)
+	return nil
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"arithmetic", `"main": { x i32 consteval(1 + 2 * 3) }`, "7"},
+		{"float", `"main": { x f64 consteval(1.5 * 2.0) }`, "3"},
+		{"string", `"main": { x str consteval("a" + "b") }`, "ab"},
+		{"module declaration", `"main": { n i32 20 x i32 consteval(n + 1) }`, "21"},
+		{"pure function", `"main": { sq i32 (x i32) { ret x * x; } x i32 consteval([sq](7)) }`, "49"},
+		{"copy", `"main": { n i32 5 x i32 consteval(copy(n)) }`, "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := parsetest.Parse(t, tt.src)
+			if err := consteval.New(false).Evaluate(nodes, nil); err != nil {
+				t.Fatal(err)
+			}
+			v, ok := declaration(t, nodes, "x").(ast.Value)
+			if !ok || v.Consteval || v.Value != tt.want {
+				t.Errorf("folded into %+v, want %s", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateArray(t *testing.T) {
+	nodes := parsetest.Parse(t, `"main": { x i32 consteval(array(1, 2) + array(3)) }`)
+	if err := consteval.New(false).Evaluate(nodes, nil); err != nil {
+		t.Fatal(err)
+	}
+	a, ok := declaration(t, nodes, "x").(ast.ArrayValue)
+	if !ok || len(a.Elements) != 3 {
+		t.Fatalf("folded into %+v, want array of 3 elements", a)
+	}
+	for i, el := range a.Elements {
+		if v := el.Value.(ast.Value); v.Value != strconv.Itoa(i+1) {
+			t.Errorf("element %d is %s, want %d", i, v.Value, i+1)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		message string
+	}{
+		{
+			name:    "parameter",
+			src:     `"main": { f i32 (a i32) { ret consteval(a + 1); } }`,
+			message: "depends on runtime value a",
+		},
+		{
+			name:    "local",
+			src:     `"main": { main void () { a i32 1; stdout consteval(a); } }`,
+			message: "depends on runtime value a",
+		},
+		{
+			name:    "module declaration assigned at runtime",
+			src:     `"main": { n i32 1 main void () { add n, n, 1; stdout consteval(n); } }`,
+			message: "n, since it's assigned at runtime",
+		},
+		{
+			name:    "initialization cycle",
+			src:     `"main": { a i32 b b i32 a x i32 consteval(a) }`,
+			message: "initialization cycle of a",
+		},
+		{
+			name:    "impure function",
+			src:     `"main": { loud i32 () { stdout "loud"; ret 1; } x i32 consteval([loud]()) }`,
+			message: "can't call loud at compile time",
+		},
+		{
+			name:    "function which never returns",
+			src:     `"main": { spin i32 () { loop { } ret 1; } x i32 consteval([spin]()) }`,
+			message: "can't call spin at compile time: in function spin: step limit exceeded",
+		},
+		{
+			name:    "function as value",
+			src:     `"main": { f void () { } x i32 consteval(f) }`,
+			message: "function f can't be used as a value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list diag.Diagnostics
+			list.Add(consteval.New(false).Evaluate(parsetest.Parse(t, tt.src), nil))
+			if len(list) != 1 {
+				t.Fatalf("got %d diagnostics %v, want 1", len(list), list)
+			}
+			if d := list[0]; d.Code != diag.CodeConsteval || !strings.Contains(d.Message, tt.message) {
+				t.Errorf("got %s %q, want %s containing %q", d.Code, d.Message, diag.CodeConsteval, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package consteval
+
+import "errors"
+
+// ErrRuntimeValue is returned by the evaluator if the consteval expression
+// depends on the value only known at runtime, such as parameters or local variables.
+var ErrRuntimeValue = errors.New("consteval expression depends on runtime value")
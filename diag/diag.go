@@ -0,0 +1,96 @@
+package diag
+
+import (
+	"errors"
+	"strings"
+)
+
+// Diagnoser is implemented by errors of the toolchain stages
+// that can be converted into a diagnostic.
+type Diagnoser interface {
+	Diagnostic() *Diagnostic
+}
+
+// Diagnostics is a list of diagnostics found during one pass,
+// such as scanning or parsing the whole file.
+type Diagnostics []*Diagnostic
+
+// Add appends err to the list.
+// Does nothing if err is nil.
+//
+// If err is Diagnostics or wraps multiple errors, they're appended one by one.
+// If err implements Diagnoser, its diagnostic is appended.
+// Otherwise, if err is not a diagnostic, it's wrapped into the one with CodeUnknown.
+func (d *Diagnostics) Add(err error) {
+	switch e := err.(type) {
+	case nil:
+		return
+	case Diagnostics:
+		*d = append(*d, e...)
+		return
+	case *Diagnostic:
+		*d = append(*d, e)
+		return
+	case Diagnoser:
+		*d = append(*d, e.Diagnostic())
+		return
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			d.Add(inner)
+		}
+		return
+	}
+	var diagnostic *Diagnostic
+	if errors.As(err, &diagnostic) {
+		*d = append(*d, diagnostic)
+		return
+	}
+	*d = append(*d, New(Error, CodeUnknown, err.Error()))
+}
+
+// SetFile sets the file name of diagnostics that have no file name.
+func (d Diagnostics) SetFile(name string) {
+	for _, diagnostic := range d {
+		if diagnostic.File == "" {
+			diagnostic.File = name
+		}
+	}
+}
+
+// HasErrors reports whether the list contains diagnostics of the error severity.
+func (d Diagnostics) HasErrors() bool {
+	for _, diagnostic := range d {
+		if diagnostic.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Error returns all diagnostics joined by a newline.
+func (d Diagnostics) Error() string {
+	parts := make([]string, len(d))
+	for i, diagnostic := range d {
+		parts[i] = diagnostic.Error()
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Unwrap returns the diagnostics of the list,
+// so errors.Is and errors.As can inspect each of them.
+func (d Diagnostics) Unwrap() []error {
+	errs := make([]error, len(d))
+	for i, diagnostic := range d {
+		errs[i] = diagnostic
+	}
+	return errs
+}
+
+// Err returns the list as an error,
+// or nil if the list is empty.
+func (d Diagnostics) Err() error {
+	if len(d) == 0 {
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,104 @@
+package diag_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// diagnoser is an error of some stage converted into the diagnostic.
+type diagnoser struct {
+	d *diag.Diagnostic
+}
+
+func (e diagnoser) Error() string                { return e.d.Message }
+func (e diagnoser) Diagnostic() *diag.Diagnostic { return e.d }
+
+func TestAdd(t *testing.T) {
+	first := diag.Errorf(diag.CodeSyntax, "first")
+	second := diag.Errorf(diag.CodeType, "second")
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{"nil", nil, nil},
+		{"diagnostic", first, []string{"E0100 first"}},
+		{"diagnostics", diag.Diagnostics{first, second}, []string{"E0100 first", "E0300 second"}},
+		{"diagnoser", diagnoser{second}, []string{"E0300 second"}},
+		{"wrapped diagnostic", fmt.Errorf("stage: %w", first), []string{"E0100 first"}},
+		{"joined errors", errors.Join(first, errors.Join(second, first)), []string{"E0100 first", "E0300 second", "E0100 first"}},
+		{"plain error", errors.New("plain"), []string{"E9999 plain"}},
+		{"joined plain error", errors.Join(diag.Diagnostics{second}, errors.New("plain")), []string{"E0300 second", "E9999 plain"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list diag.Diagnostics
+			list.Add(tt.err)
+			var got []string
+			for _, d := range list {
+				got = append(got, fmt.Sprintf("%s %s", d.Code, d.Message))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsError(t *testing.T) {
+	first := diag.Errorf(diag.CodeSyntax, "first")
+	second := diag.New(diag.Warning, diag.CodeShadowed, "second")
+	list := diag.Diagnostics{first, second}
+
+	var err error = list
+	var d *diag.Diagnostic
+	if !errors.As(err, &d) || d != first {
+		t.Errorf("errors.As found %v, want the first diagnostic", d)
+	}
+	if !errors.Is(err, second) {
+		t.Error("errors.Is doesn't find the second diagnostic")
+	}
+	if wrapped := fmt.Errorf("check: %w", err); !errors.Is(wrapped, second) {
+		t.Error("errors.Is doesn't find the diagnostic through the wrapped list")
+	}
+	if got := len(list.Unwrap()); got != 2 {
+		t.Errorf("Unwrap returned %d errors, want 2", got)
+	}
+
+	if !list.HasErrors() {
+		t.Error("HasErrors = false, want true")
+	}
+	if (diag.Diagnostics{second}).HasErrors() {
+		t.Error("HasErrors of warnings = true, want false")
+	}
+	if err := (diag.Diagnostics{}).Err(); err != nil {
+		t.Errorf("Err of the empty list = %v, want nil", err)
+	}
+}
+
+func TestDiagnosticError(t *testing.T) {
+	at := &token.Token{Position: &token.Position{Line: 2, Column: 5}}
+	tests := []struct {
+		name string
+		d    *diag.Diagnostic
+		file string
+		want string
+	}{
+		{"no position", diag.Errorf(diag.CodeSyntax, "bad"), "", "error[E0100]: bad"},
+		{"file only", diag.Errorf(diag.CodeSyntax, "bad"), "a.dl", "a.dl: error[E0100]: bad"},
+		{"position", diag.Errorf(diag.CodeSyntax, "bad").At(at), "", "2:5: error[E0100]: bad"},
+		{"file and position", diag.New(diag.Warning, diag.CodeShadowed, "x").At(at), "a.dl", "a.dl:2:5: warning[W0200]: x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diag.Diagnostics{tt.d}.SetFile(tt.file)
+			if got := tt.d.Error(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
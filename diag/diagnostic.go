@@ -0,0 +1,211 @@
+package diag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// Severity represents how serious the diagnostic is.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Note    Severity = "note"
+)
+
+// Code identifies the kind of diagnostic,
+// so tools can match diagnostics without parsing messages.
+type Code string
+
+const (
+	// CodeIllegalCharacter is reported by the scanner if no tokenizer matches the character.
+	CodeIllegalCharacter Code = "E0001"
+
+	// CodeInvalidToken is reported by the scanner if the tokenizer fails,
+	// such as on unterminated strings.
+	CodeInvalidToken Code = "E0002"
+
+	// CodeSyntax is reported by the parser on malformed syntax.
+	CodeSyntax Code = "E0100"
+
+	// CodeUnexpectedToken is reported by the parser if the token differs from the expected one.
+	CodeUnexpectedToken Code = "E0101"
+
+	// CodeUndefined is reported by the semantic analysis on undefined names.
+	CodeUndefined Code = "E0200"
+
+	// CodeDuplicate is reported by the semantic analysis on names declared more than once.
+	CodeDuplicate Code = "E0201"
+
+	// CodeNotFunction is reported by the semantic analysis on calls of non-function symbols.
+	CodeNotFunction Code = "E0202"
+
+	// CodeMisplaced is reported by the semantic analysis on break and continue outside loops.
+	CodeMisplaced Code = "E0203"
+
+	// CodeCopyNotAllowed is reported by the semantic analysis on copied arguments
+	// passed to parameters marked with copy(false).
+	CodeCopyNotAllowed Code = "E0204"
+
+	// CodeShadowed is reported by the semantic analysis on parameters and locals
+	// shadowing outer locals, parameters or module declarations.
+	CodeShadowed Code = "W0200"
+
+	// CodeType is reported by the type checker.
+	CodeType Code = "E0300"
+
+	// CodeConsteval is reported by the constant evaluator.
+	CodeConsteval Code = "E0400"
+
+	// CodeImport is reported by the loader on imports that can't be read or form a cycle.
+	CodeImport Code = "E0500"
+
+	// CodeMissingDefinition is reported by the linker if the declaration has no exported definition.
+	CodeMissingDefinition Code = "E0600"
+
+	// CodeDuplicateDefinition is reported by the linker if the declaration matches
+	// exported definitions in more than one module.
+	CodeDuplicateDefinition Code = "E0601"
+
+	// CodeNotLinkable is reported by the linker on definitions that aren't exported,
+	// and on declarations marked with link(false).
+	CodeNotLinkable Code = "E0602"
+
+	// CodeSignatureMismatch is reported by the linker if the declaration
+	// and its definition have different types.
+	CodeSignatureMismatch Code = "E0603"
+
+	// CodeUnknown is used for errors that aren't diagnostics themselves.
+	CodeUnknown Code = "E9999"
+)
+
+// Fix is a suggested edit replacing the source between Start and End with Replacement.
+type Fix struct {
+	Message     string         `json:"message"`
+	Start       token.Position `json:"start"`
+	End         token.Position `json:"end"`
+	Replacement string         `json:"replacement"`
+}
+
+// Label is a secondary message pointing to the related source between Start and End.
+//
+// File is empty if the label points to the file of the diagnostic.
+// Module is set by the analysis stages, like Module of Diagnostic.
+type Label struct {
+	Message string         `json:"message"`
+	File    string         `json:"file,omitempty"`
+	Module  string         `json:"module,omitempty"`
+	Start   token.Position `json:"start"`
+	End     token.Position `json:"end"`
+}
+
+// Diagnostic is a structured error or warning pointing to the source.
+//
+// Start and End surround the offending source,
+// End is exclusive.
+// Module is set by the analysis stages, which work on modules rather than files,
+// so the driver can find the file declaring the module.
+type Diagnostic struct {
+	Severity Severity       `json:"severity"`
+	Code     Code           `json:"code"`
+	Message  string         `json:"message"`
+	File     string         `json:"file,omitempty"`
+	Module   string         `json:"module,omitempty"`
+	Start    token.Position `json:"start"`
+	End      token.Position `json:"end"`
+	Labels   []Label        `json:"labels,omitempty"`
+	Notes    []string       `json:"notes,omitempty"`
+	Fixes    []Fix          `json:"fixes,omitempty"`
+}
+
+// New returns a new pointer to Diagnostic.
+func New(severity Severity, code Code, message string) *Diagnostic {
+	return &Diagnostic{Severity: severity, Code: code, Message: message}
+}
+
+// Errorf returns a new pointer to Diagnostic of the error severity,
+// with the formatted message.
+func Errorf(code Code, format string, v ...any) *Diagnostic {
+	return New(Error, code, fmt.Sprintf(format, v...))
+}
+
+// At sets Start and End of d to the positions of the token t,
+// and returns d.
+//
+// Does nothing if t is nil.
+func (d *Diagnostic) At(t *token.Token) *Diagnostic {
+	if t == nil || t.Position == nil {
+		return d
+	}
+	d.Start = *t.Position
+	d.End = *t.Position
+	if t.End != nil {
+		d.End = *t.End
+	}
+	return d
+}
+
+// Spanned is implemented by values surrounding the source, such as AST nodes.
+type Spanned interface {
+	Pos() token.Position
+	End() token.Position
+}
+
+// Span sets Start and End of d to the positions of n,
+// and returns d.
+//
+// Does nothing if n is nil or has no position.
+func (d *Diagnostic) Span(n Spanned) *Diagnostic {
+	if n == nil || n.Pos().Line == 0 {
+		return d
+	}
+	d.Start, d.End = n.Pos(), n.End()
+	return d
+}
+
+// In sets the module where d was found and adds the note telling the module and function,
+// which can be empty, and returns d.
+func (d *Diagnostic) In(module, function string) *Diagnostic {
+	d.Module = module
+	d.Notes = append(d.Notes, Context(module, function))
+	return d
+}
+
+// Label adds the label with the message pointing to n, which is found in module,
+// and returns d.
+//
+// Does nothing if n is nil or has no position.
+func (d *Diagnostic) Label(n Spanned, module, message string) *Diagnostic {
+	if n == nil || n.Pos().Line == 0 {
+		return d
+	}
+	d.Labels = append(d.Labels, Label{Message: message, Module: module, Start: n.Pos(), End: n.End()})
+	return d
+}
+
+// Error returns the diagnostic formatted as "file:line:column: severity[code]: message".
+func (d *Diagnostic) Error() string {
+	var b strings.Builder
+	if d.File != "" {
+		fmt.Fprintf(&b, "%s:", d.File)
+	}
+	if d.Start.Line != 0 {
+		fmt.Fprintf(&b, "%d:%d: ", d.Start.Line, d.Start.Column)
+	} else if d.File != "" {
+		b.WriteString(" ")
+	}
+	fmt.Fprintf(&b, "%s[%s]: %s", d.Severity, d.Code, d.Message)
+	return b.String()
+}
+
+// Context returns the note telling the module and function
+// where the diagnostic was found; function can be empty.
+func Context(module, function string) string {
+	if function == "" {
+		return fmt.Sprintf("in module %q", module)
+	}
+	return fmt.Sprintf("in function %s of module %q", function, module)
+}
@@ -0,0 +1,231 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dywoq/dywoqlang/token"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// Renderer prints diagnostics along with the source lines they point to,
+// underlining the offending spans with carets:
+//
+//	error[E0100]: unknown value type: @
+//	 --> main.dl:2:12
+//	  |
+//	2 |     a i32 @
+//	  |           ^
+//	  |
+//	  = help: insert ';'
+//
+// Secondary labels are underlined with dashes,
+// or rendered as notes if they point to other files.
+type Renderer struct {
+	// Color enables ANSI colors.
+	Color bool
+}
+
+// NewRenderer returns a new pointer to Renderer.
+func NewRenderer(color bool) *Renderer {
+	return &Renderer{Color: color}
+}
+
+type span struct {
+	start, end int
+	message    string
+	primary    bool
+}
+
+// RenderAll renders each diagnostic of list, separated by an empty line.
+func (r *Renderer) RenderAll(w io.Writer, list Diagnostics, source string) error {
+	for i, d := range list {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if err := r.Render(w, d, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render renders d, taking the lines it points to from source.
+//
+// If the diagnostic has no position, only the header, notes and fixes are rendered.
+func (r *Renderer) Render(w io.Writer, d *Diagnostic, source string) error {
+	var b strings.Builder
+	b.WriteString(r.paint(severityColor(d.Severity)+ansiBold, fmt.Sprintf("%s[%s]", d.Severity, d.Code)))
+	b.WriteString(r.paint(ansiBold, ": "+d.Message))
+	b.WriteString("\n")
+
+	spans := []span{{start: offset(source, d.Start), end: offset(source, d.End), message: "", primary: true}}
+	notes := d.Notes
+	for _, l := range d.Labels {
+		if l.File != "" && l.File != d.File {
+			// the source of other files isn't available, so the label is rendered as a note.
+			notes = append(notes, fmt.Sprintf("%s at %s:%d:%d", l.Message, l.File, l.Start.Line, l.Start.Column))
+			continue
+		}
+		spans = append(spans, span{start: offset(source, l.Start), end: offset(source, l.End), message: l.Message})
+	}
+
+	hasPosition := d.Start.Line != 0
+	width := 1
+	if hasPosition {
+		last := d.Start.Line
+		for _, s := range spans {
+			last = max(last, lineOf(source, s.end))
+		}
+		width = len(strconv.Itoa(last))
+	}
+	gutter := strings.Repeat(" ", width)
+
+	if hasPosition {
+		location := fmt.Sprintf("%d:%d", d.Start.Line, d.Start.Column)
+		if d.File != "" {
+			location = d.File + ":" + location
+		}
+		fmt.Fprintf(&b, "%s%s %s\n", gutter, r.paint(ansiBlue+ansiBold, "-->"), location)
+		fmt.Fprintf(&b, "%s %s\n", gutter, r.paint(ansiBlue+ansiBold, "|"))
+		r.snippet(&b, source, spans, d.Severity, width)
+	}
+
+	if len(notes) != 0 || len(d.Fixes) != 0 {
+		if hasPosition {
+			fmt.Fprintf(&b, "%s %s\n", gutter, r.paint(ansiBlue+ansiBold, "|"))
+		}
+		for _, n := range notes {
+			fmt.Fprintf(&b, "%s %s note: %s\n", gutter, r.paint(ansiBlue+ansiBold, "="), n)
+		}
+		for _, f := range d.Fixes {
+			fmt.Fprintf(&b, "%s %s help: %s\n", gutter, r.paint(ansiBlue+ansiBold, "="), f.Message)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// snippet writes source lines covered by spans, each followed by its underlines.
+func (r *Renderer) snippet(b *strings.Builder, source string, spans []span, severity Severity, width int) {
+	lines := map[int]bool{}
+	first, last := -1, -1
+	for _, s := range spans {
+		for l := lineOf(source, s.start); l <= lineOf(source, max(s.start, s.end-1)); l++ {
+			lines[l] = true
+			if first == -1 || l < first {
+				first = l
+			}
+			last = max(last, l)
+		}
+	}
+
+	bar := r.paint(ansiBlue+ansiBold, "|")
+	previous := -1
+	for l := first; l <= last; l++ {
+		if !lines[l] {
+			continue
+		}
+		if previous != -1 && l > previous+1 {
+			fmt.Fprintf(b, "%s\n", r.paint(ansiBlue+ansiBold, "..."))
+		}
+		previous = l
+
+		lineStart, lineEnd := lineBounds(source, l)
+		text := source[lineStart:lineEnd]
+		fmt.Fprintf(b, "%s %s %s\n", r.paint(ansiBlue+ansiBold, fmt.Sprintf("%*d", width, l)), bar, text)
+
+		for _, s := range spans {
+			startLine, endLine := lineOf(source, s.start), lineOf(source, max(s.start, s.end-1))
+			if l < startLine || l > endLine {
+				continue
+			}
+			from := max(s.start, lineStart)
+			to := min(max(s.end, s.start+1), lineEnd)
+			marker, color := "-", ansiBlue
+			if s.primary {
+				marker, color = "^", severityColor(severity)
+			}
+			count := max(1, utf8.RuneCountInString(source[from:max(from, to)]))
+			underline := strings.Repeat(marker, count)
+			if s.message != "" && endLine == l {
+				underline += " " + s.message
+			}
+			fmt.Fprintf(b, "%s %s %s%s\n", strings.Repeat(" ", width), bar, padding(source[lineStart:from]), r.paint(color+ansiBold, underline))
+		}
+	}
+}
+
+// padding returns the whitespace aligned with prefix,
+// keeping tabs so the underline matches the rendered source line.
+func padding(prefix string) string {
+	var b strings.Builder
+	for _, r := range prefix {
+		if r == '\t' {
+			b.WriteRune('\t')
+			continue
+		}
+		b.WriteRune(' ')
+	}
+	return b.String()
+}
+
+func (r *Renderer) paint(color, text string) string {
+	if !r.Color {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+func severityColor(s Severity) string {
+	switch s {
+	case Warning:
+		return ansiYellow
+	case Note:
+		return ansiCyan
+	}
+	return ansiRed
+}
+
+// offset returns the byte offset of pos in source,
+// clamped to the bounds of source.
+func offset(source string, pos token.Position) int {
+	return max(0, min(pos.Position, len(source)))
+}
+
+// lineOf returns the 1-based line number of the byte offset.
+func lineOf(source string, offset int) int {
+	return strings.Count(source[:max(0, min(offset, len(source)))], "\n") + 1
+}
+
+// lineBounds returns the byte offsets of the start and end of the 1-based line,
+// excluding the newline character.
+func lineBounds(source string, line int) (int, int) {
+	start := 0
+	for l := 1; l < line; l++ {
+		i := strings.IndexByte(source[start:], '\n')
+		if i == -1 {
+			return len(source), len(source)
+		}
+		start += i + 1
+	}
+	end := strings.IndexByte(source[start:], '\n')
+	if end == -1 {
+		return start, len(source)
+	}
+	return start, start + end
+}
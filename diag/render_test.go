@@ -0,0 +1,168 @@
+package diag_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// at returns the position of the 1-based line and column, counted in runes, of src.
+func at(src string, line, column int) token.Position {
+	offset := 0
+	for range line - 1 {
+		offset += strings.IndexByte(src[offset:], '\n') + 1
+	}
+	for range column - 1 {
+		_, size := utf8.DecodeRuneInString(src[offset:])
+		offset += size
+	}
+	return token.Position{Line: line, Column: column, Position: offset}
+}
+
+func TestRender(t *testing.T) {
+	src := "\"main\": {\n\tx i32 1\n\ty i32 @\n\n\n\n\n\n\n\tz i32 2\n\tимя str \"é\"\n}"
+	tests := []struct {
+		name string
+		d    func() *diag.Diagnostic
+		want string
+	}{
+		{
+			name: "caret and fix",
+			d: func() *diag.Diagnostic {
+				d := diag.Errorf(diag.CodeSyntax, "unknown value type: @")
+				d.File = "main.dl"
+				d.Start, d.End = at(src, 3, 8), at(src, 3, 9)
+				d.Fixes = []diag.Fix{{Message: "insert ';'"}}
+				return d
+			},
+			want: `error[E0100]: unknown value type: @
+ --> main.dl:3:8
+  |
+3 | 	y i32 @
+  | 	      ^
+  |
+  = help: insert ';'
+`,
+		},
+		{
+			name: "label on distant line",
+			d: func() *diag.Diagnostic {
+				d := diag.Errorf(diag.CodeDuplicate, "z is declared more than once")
+				d.Start, d.End = at(src, 10, 2), at(src, 10, 9)
+				d.Labels = []diag.Label{{Message: "first declared here", Start: at(src, 2, 2), End: at(src, 2, 9)}}
+				d.Notes = []string{`in module "main"`}
+				return d
+			},
+			want: `error[E0201]: z is declared more than once
+  --> 10:2
+   |
+ 2 | 	x i32 1
+   | 	------- first declared here
+...
+10 | 	z i32 2
+   | 	^^^^^^^
+   |
+   = note: in module "main"
+`,
+		},
+		{
+			name: "multi-byte characters",
+			d: func() *diag.Diagnostic {
+				d := diag.New(diag.Warning, diag.CodeShadowed, "shadowed")
+				d.Start, d.End = at(src, 11, 2), at(src, 11, 5)
+				d.Labels = []diag.Label{{Message: "string", Start: at(src, 11, 10), End: at(src, 11, 13)}}
+				return d
+			},
+			want: `warning[W0200]: shadowed
+  --> 11:2
+   |
+11 | 	имя str "é"
+   | 	^^^
+   | 	        --- string
+`,
+		},
+		{
+			name: "label in other file",
+			d: func() *diag.Diagnostic {
+				d := diag.Errorf(diag.CodeSignatureMismatch, "mismatch")
+				d.File = "main.dl"
+				d.Start, d.End = at(src, 2, 2), at(src, 2, 3)
+				d.Labels = []diag.Label{{Message: "defined here", File: "lib.dl", Start: token.Position{Line: 4, Column: 1}}}
+				return d
+			},
+			want: `error[E0603]: mismatch
+ --> main.dl:2:2
+  |
+2 | 	x i32 1
+  | 	^
+  |
+  = note: defined here at lib.dl:4:1
+`,
+		},
+		{
+			name: "no position",
+			d: func() *diag.Diagnostic {
+				d := diag.Errorf(diag.CodeImport, "can't read lib.dl")
+				d.Notes = []string{"imported by main.dl"}
+				return d
+			},
+			want: `error[E0500]: can't read lib.dl
+  = note: imported by main.dl
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := diag.NewRenderer(false).Render(&b, tt.d(), src); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("rendered\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	src := "x i32 @"
+	d := diag.New(diag.Warning, diag.CodeShadowed, "w")
+	d.Start, d.End = at(src, 1, 7), at(src, 1, 8)
+
+	var plain, colored strings.Builder
+	if err := diag.NewRenderer(false).Render(&plain, d, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := diag.NewRenderer(true).Render(&colored, d, src); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Errorf("rendered colors without Color:\n%q", plain.String())
+	}
+	for _, want := range []string{"\x1b[33m\x1b[1mwarning[W0200]\x1b[0m", "\x1b[33m\x1b[1m^\x1b[0m", "\x1b[34m\x1b[1m|\x1b[0m"} {
+		if !strings.Contains(colored.String(), want) {
+			t.Errorf("colored output\n%q\ndoesn't contain %q", colored.String(), want)
+		}
+	}
+	stripped := colored.String()
+	for _, code := range []string{"\x1b[0m", "\x1b[1m", "\x1b[33m", "\x1b[34m"} {
+		stripped = strings.ReplaceAll(stripped, code, "")
+	}
+	if stripped != plain.String() {
+		t.Errorf("colored output without colors is\n%s\nwant\n%s", stripped, plain.String())
+	}
+}
+
+func TestRenderAll(t *testing.T) {
+	list := diag.Diagnostics{diag.Errorf(diag.CodeSyntax, "a"), diag.Errorf(diag.CodeSyntax, "b")}
+	var b strings.Builder
+	if err := diag.NewRenderer(false).RenderAll(&b, list, ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := "error[E0100]: a\n\nerror[E0100]: b\n"; b.String() != want {
+		t.Errorf("rendered %q, want %q", b.String(), want)
+	}
+}
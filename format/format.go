@@ -0,0 +1,67 @@
+// Package format implements the canonical formatting of dywoqlang source code.
+package format
+
+import (
+	"log"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/parser"
+	"github.com/dywoq/dywoqlang/scanner"
+)
+
+// Formatter reprints source code in the canonical style:
+//
+//   - module and function bodies are indented with one tab per level;
+//   - every declaration, statement and comment starts on its own line;
+//   - arguments and parameters are separated by ", ";
+//   - modifiers and values like `link(...)`, `copy(...)` and `consteval(...)`
+//     have no space before the parenthesis;
+//   - at most one blank line is kept between lines.
+//
+// Comments, including doc comments, are preserved.
+type Formatter struct {
+	debug bool
+}
+
+// New returns a new pointer to Formatter.
+func New(debug bool) *Formatter {
+	return &Formatter{debug: debug}
+}
+
+// Format formats input.
+//
+// The input must be free of syntax errors, since malformed code can't be formatted reliably,
+// otherwise Format returns diag.Diagnostics of the scanner and parser.
+func (f *Formatter) Format(input string) (string, error) {
+	if strings.TrimSpace(input) == "" {
+		return "", nil
+	}
+	tokens, err := scanner.New(f.debug).Scan(input)
+	if err != nil {
+		return "", err
+	}
+	if _, err := parser.New(f.debug).Parse(tokens); err != nil {
+		return "", err
+	}
+
+	p := &printer{tokens: tokens, lineStart: true}
+	p.print()
+	output := p.String()
+	f.outputf("formatted %d tokens into %d bytes\n", len(tokens), len(output))
+	return output, nil
+}
+
+// Source formats src, like Formatter.Format with the debug mode off.
+func Source(src []byte) ([]byte, error) {
+	output, err := New(false).Format(string(src))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(output), nil
+}
+
+func (f *Formatter) outputf(format string, v ...any) {
+	if f.debug {
+		log.Printf(format, v...)
+	}
+}
@@ -0,0 +1,144 @@
+package format_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/format"
+)
+
+var tests = []struct {
+	name  string
+	input string
+	want  string
+}{
+	{
+		name:  "spacing",
+		input: `"main":{x i32   1 f i32(a i32,b i32 copy(false)){ret a+b*2;}}`,
+		want: `"main": {
+	x i32 1
+	f i32 (a i32, b i32 copy(false)) {
+		ret a + b * 2;
+	}
+}
+`,
+	},
+	{
+		name:  "comments",
+		input: "# doc\n\"main\": { x i32 1 # trailing\n# about main\nmain void () { # inside\n stdout x; } }\n",
+		want: `# doc
+"main": {
+	x i32 1 # trailing
+	# about main
+	main void () { # inside
+		stdout x;
+	}
+}
+`,
+	},
+	{
+		name:  "blank lines",
+		input: "\"main\": {\n\tx i32 1\n\n\n\n\ty i32 2\n}\n",
+		want:  "\"main\": {\n\tx i32 1\n\n\ty i32 2\n}\n",
+	},
+	{
+		name:  "blocks and labels",
+		input: `"main": { main void () { if 1 < 2 { stdout "x"; } else { jmp end; } end: [f] 1,2; y i32 consteval(1+2); } }`,
+		want: `"main": {
+	main void () {
+		if 1 < 2 {
+			stdout "x";
+		} else {
+			jmp end;
+		}
+	end:
+		[f] 1, 2;
+		y i32 consteval(1 + 2);
+	}
+}
+`,
+	},
+	{
+		name:  "declare and link",
+		input: `"main": { declare f i32() link("lib")  h i32 (x i32) export g i32 ( ) { ret [f]( ); } }`,
+		want: `"main": {
+	declare f i32 ()
+	link("lib") h i32 (x i32)
+	export g i32 () {
+		ret [f]();
+	}
+}
+`,
+	},
+	{
+		name:  "user instruction calls",
+		input: `"main": { main void () { [g] (x+1)*2,"a"; [g](x,1); } }`,
+		want: `"main": {
+	main void () {
+		[g] (x + 1) * 2, "a";
+		[g](x, 1);
+	}
+}
+`,
+	},
+}
+
+func TestFormat(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format.New(false).Format(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("formatted\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIdempotent checks that formatting already formatted code doesn't change it.
+func TestIdempotent(t *testing.T) {
+	sources := map[string]string{}
+	for _, tt := range tests {
+		sources[tt.name] = tt.input
+	}
+	paths, err := filepath.Glob(filepath.Join("..", "vm", "testdata", "*.dl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range append(paths, filepath.Join("..", "main.dl")) {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[filepath.Base(path)] = string(src)
+	}
+
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			once, err := format.New(false).Format(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			twice, err := format.New(false).Format(once)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if once != twice {
+				t.Errorf("formatted once\n%s\nformatted twice\n%s", once, twice)
+			}
+			if strings.Count(once, "#") != strings.Count(src, "#") {
+				t.Errorf("comments were lost:\n%s", once)
+			}
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	if _, err := format.New(false).Format(`"main": { x i32 }`); err == nil {
+		t.Error("formatted code with syntax errors")
+	}
+}
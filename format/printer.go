@@ -0,0 +1,291 @@
+package format
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// block is a kind of the block opened by '{'.
+type block int
+
+const (
+	moduleBlock block = iota
+	bodyBlock
+)
+
+// printer prints tokens in the canonical style.
+type printer struct {
+	bytes.Buffer
+
+	tokens []*token.Token
+	pos    int
+
+	indent    int
+	blocks    []block
+	parens    int
+	lineStart bool
+
+	// glue is set if the next token is written right after the previous one,
+	// such as the operand of unary minus.
+	glue bool
+}
+
+func (p *printer) print() {
+	for p.pos = 0; p.pos < len(p.tokens); p.pos++ {
+		t := p.tokens[p.pos]
+		if t.Kind == token.Eof {
+			break
+		}
+		p.token(t)
+	}
+	p.newline()
+}
+
+func (p *printer) token(t *token.Token) {
+	prev := p.prev()
+
+	if t.Kind == token.Comment {
+		// A comment on the same line as the previous token stays there.
+		if prev != nil && prev.End.Line == t.Position.Line {
+			p.unbreak()
+			p.space()
+			p.write(strings.TrimRight(t.Literal, " \t\r"))
+			p.newline()
+			return
+		}
+		p.newline()
+	}
+	if p.startsDeclaration(t) {
+		p.newline()
+	}
+	if p.lineStart && prev != nil && t.Position.Line-prev.End.Line > 1 &&
+		!isSeparator(prev, "{") && !isSeparator(t, "}") {
+		p.blankLine()
+	}
+
+	switch {
+	case t.Kind == token.Comment:
+		p.write(strings.TrimRight(t.Literal, " \t\r"))
+		p.newline()
+
+	case isSeparator(t, "{"):
+		kind := bodyBlock
+		if isSeparator(prev, ":") {
+			kind = moduleBlock
+		}
+		p.space()
+		p.write("{")
+		p.blocks = append(p.blocks, kind)
+		p.indent++
+		p.newline()
+
+	case isSeparator(t, "}"):
+		if len(p.blocks) > 0 {
+			p.blocks = p.blocks[:len(p.blocks)-1]
+		}
+		if p.indent > 0 {
+			p.indent--
+		}
+		p.newline()
+		p.write("}")
+		if next := p.next(); next != nil && next.Kind == token.Keyword && next.Literal == "else" {
+			// `} else {` stays on one line.
+			return
+		}
+		p.newline()
+
+	case isSeparator(t, ";"):
+		p.write(";")
+		p.newline()
+
+	case isSeparator(t, ")"):
+		p.parens--
+		p.write(")")
+
+	case p.startsLabel(t):
+		// Labels are outdented by one level, so they stand out from statements.
+		p.newline()
+		p.indent--
+		p.write(t.Literal)
+		p.indent++
+
+	case isSeparator(t, ":") && p.inBody():
+		p.write(":")
+		p.newline()
+
+	case isSeparator(t, ","), isSeparator(t, "]"), isSeparator(t, ":"):
+		p.write(t.Literal)
+
+	case isSeparator(t, "("):
+		if !p.attached(prev, t) {
+			p.space()
+		}
+		p.parens++
+		p.write("(")
+
+	case t.Kind == token.String:
+		p.separate(prev)
+		p.write(`"` + t.Literal + `"`)
+
+	case t.Kind == token.BinaryOperator && t.Literal == "-" && unary(prev):
+		p.separate(prev)
+		p.write("-")
+		p.glue = true
+
+	default:
+		p.separate(prev)
+		p.write(t.Literal)
+	}
+}
+
+// startsDeclaration reports whether t starts a module, an import,
+// or a declaration inside a module, except ones preceded by modifiers.
+func (p *printer) startsDeclaration(t *token.Token) bool {
+	if p.parens > 0 || len(p.blocks) > 0 && p.blocks[len(p.blocks)-1] != moduleBlock {
+		return false
+	}
+	next := p.next()
+	switch t.Kind {
+	case token.String:
+		return isSeparator(next, ":")
+	case token.Keyword:
+		if t.Literal != "link" && t.Literal != "export" && t.Literal != "declare" && t.Literal != "import" {
+			return false
+		}
+	case token.Identifier:
+		if next == nil || next.Kind != token.Type {
+			return false
+		}
+	default:
+		return false
+	}
+	return !p.afterModifier()
+}
+
+// startsLabel reports whether t is the name of a label inside a function body.
+func (p *printer) startsLabel(t *token.Token) bool {
+	return t.Kind == token.Identifier && p.parens == 0 && p.inBody() && isSeparator(p.next(), ":")
+}
+
+// inBody reports whether the innermost block is a function body.
+func (p *printer) inBody() bool {
+	return len(p.blocks) > 0 && p.blocks[len(p.blocks)-1] == bodyBlock
+}
+
+// afterModifier reports whether the previous token ends a declaration modifier.
+func (p *printer) afterModifier() bool {
+	prev := p.prev()
+	switch {
+	case prev == nil:
+		return false
+	case prev.Kind == token.Keyword:
+		return prev.Literal == "export" || prev.Literal == "declare"
+	case isSeparator(prev, ")"):
+		// link(<argument>)
+		return p.pos >= 4 && p.tokens[p.pos-4].Kind == token.Keyword && p.tokens[p.pos-4].Literal == "link"
+	}
+	return false
+}
+
+// attached reports whether the parenthesis t is written right after prev.
+func (p *printer) attached(prev, t *token.Token) bool {
+	switch {
+	case prev == nil:
+		return false
+	case prev.Kind == token.Keyword:
+		switch prev.Literal {
+		case "link", "copy", "consteval", "array", "meta":
+			return true
+		}
+	case isSeparator(prev, "("), isSeparator(prev, "["):
+		return true
+	case isSeparator(prev, "]"):
+		// `[name](args)` is a call expression,
+		// while `[name] (args)` is an instruction call with a parenthesized argument.
+		return prev.End.Position == t.Position.Position
+	}
+	return false
+}
+
+// unary reports whether the operator after prev is unary,
+// which is the case if prev can't end an operand.
+func unary(prev *token.Token) bool {
+	switch {
+	case prev == nil:
+		return true
+	case prev.Kind == token.Separator:
+		return prev.Literal != ")"
+	case prev.Kind == token.BinaryOperator, prev.Kind == token.BaseInstruction, prev.Kind == token.Type:
+		return true
+	}
+	return false
+}
+
+// separate writes a space after prev, unless prev opens a parenthesis or a bracket.
+func (p *printer) separate(prev *token.Token) {
+	if isSeparator(prev, "(") || isSeparator(prev, "[") {
+		return
+	}
+	p.space()
+}
+
+func (p *printer) write(s string) {
+	p.glue = false
+	if p.lineStart {
+		p.WriteString(strings.Repeat("\t", p.indent))
+		p.lineStart = false
+	}
+	p.WriteString(s)
+}
+
+func (p *printer) space() {
+	if p.glue || p.lineStart || p.Len() == 0 || bytes.HasSuffix(p.Bytes(), []byte(" ")) {
+		return
+	}
+	p.WriteByte(' ')
+}
+
+func (p *printer) newline() {
+	if p.lineStart {
+		return
+	}
+	p.WriteByte('\n')
+	p.lineStart = true
+}
+
+// unbreak removes the line break written last, if there's one.
+func (p *printer) unbreak() {
+	if p.lineStart && bytes.HasSuffix(p.Bytes(), []byte("\n")) {
+		p.Truncate(p.Len() - 1)
+		p.lineStart = false
+	}
+}
+
+// blankLine ends the current line and writes an empty line,
+// unless there's one already.
+func (p *printer) blankLine() {
+	p.newline()
+	if p.Len() > 0 && !bytes.HasSuffix(p.Bytes(), []byte("\n\n")) {
+		p.WriteByte('\n')
+	}
+}
+
+func (p *printer) prev() *token.Token {
+	if p.pos == 0 {
+		return nil
+	}
+	return p.tokens[p.pos-1]
+}
+
+func (p *printer) next() *token.Token {
+	if p.pos+1 >= len(p.tokens) {
+		return nil
+	}
+	return p.tokens[p.pos+1]
+}
+
+func isSeparator(t *token.Token, lit string) bool {
+	return t != nil && t.Kind == token.Separator && t.Literal == lit
+}
@@ -0,0 +1,25 @@
+// Package parsetest provides a helper for tests of the stages after the parser.
+package parsetest
+
+import (
+	"testing"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/parser"
+	"github.com/dywoq/dywoqlang/scanner"
+)
+
+// Parse scans and parses src with the default options,
+// failing t if the scanner or the parser reports an error.
+func Parse(t testing.TB, src string) []ast.Node {
+	t.Helper()
+	tokens, err := scanner.New(false).Scan(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parser.New(false).Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nodes
+}
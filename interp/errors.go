@@ -0,0 +1,26 @@
+package interp
+
+import "errors"
+
+var (
+	// ErrNoMainModule is returned by the interpreter if there's no "main" module.
+	ErrNoMainModule = errors.New("no \"main\" module")
+
+	// ErrNoMainFunction is returned by the interpreter if the "main" module has no main function.
+	ErrNoMainFunction = errors.New("no main function in \"main\" module")
+
+	// ErrStackOverflow is returned by the interpreter if there are more than MaxDepth nested calls.
+	ErrStackOverflow = errors.New("stack overflow")
+
+	// ErrNoComparison is returned by the interpreter if the conditional jump
+	// is executed before any cmp instruction in the function.
+	ErrNoComparison = errors.New("conditional jump without cmp")
+
+	// ErrImpure is returned by the interpreter in the pure mode
+	// if the function performs I/O or assigns module declarations.
+	ErrImpure = errors.New("function is not pure")
+
+	// ErrStepLimit is returned by the interpreter in the pure mode
+	// if the call executes more than MaxSteps statements and blocks.
+	ErrStepLimit = errors.New("step limit exceeded")
+)
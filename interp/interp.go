@@ -0,0 +1,676 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/linker"
+	"github.com/dywoq/dywoqlang/token"
+	"github.com/dywoq/dywoqlang/value"
+)
+
+// MaxDepth is the maximum depth of nested calls.
+const MaxDepth = 1024
+
+// MaxSteps is the maximum number of statements and blocks
+// executed by one call in the pure mode.
+const MaxSteps = 1_000_000
+
+// Interpreter is a tree-walking interpreter that executes parsed modules.
+type Interpreter struct {
+	modules map[string]*module
+	links   linker.Links
+	depth   int
+	pure    bool
+	steps   int
+
+	stdout io.Writer
+	stderr io.Writer
+
+	debug bool
+}
+
+type module struct {
+	name       string
+	decls      map[string]*ast.Declaration
+	globals    map[string]value.Value
+	evaluating map[string]bool
+}
+
+type frame struct {
+	module *module
+	name   string
+	locals *locals
+
+	// compared reports whether cmp was executed,
+	// and result holds the result of the last one.
+	compared bool
+	result   int
+
+	// target is the label of the last jump taken.
+	target string
+}
+
+// New returns a new pointer to Interpreter,
+// which writes to os.Stdout and os.Stderr.
+func New(debug bool) *Interpreter {
+	return &Interpreter{
+		modules: map[string]*module{},
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		debug:   debug,
+	}
+}
+
+// SetOutput sets the writers used by stdout and stderr instructions.
+func (i *Interpreter) SetOutput(stdout, stderr io.Writer) {
+	i.stdout = stdout
+	i.stderr = stderr
+}
+
+// SetPure enables or disables the pure mode.
+//
+// In the pure mode, functions can't use stdout and stderr instructions
+// or assign module declarations, and fail with ErrImpure instead.
+// Calls also fail with ErrStepLimit after executing MaxSteps statements and blocks,
+// so functions which never return can't hang the compilation.
+// It's used to call functions at compile time.
+func (i *Interpreter) SetPure(pure bool) {
+	i.pure = pure
+}
+
+// Load loads modules from nodes returned by parser.Parser.Parse,
+// so their functions can be called with Call.
+//
+// Functions and variables marked with declare or link("module")
+// refer to the definitions given by links, returned by linker.Linker.Link.
+func (i *Interpreter) Load(nodes []ast.Node, links linker.Links) error {
+	i.reset()
+	i.links = links
+	for _, n := range nodes {
+		if err := i.load(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run executes nodes returned by parser.Parser.Parse,
+// with declarations linked by links, like Load.
+//
+// It finds the "main" module and calls its main function without arguments,
+// returning the value the function returned with ret.
+//
+// Returns ErrNoMainModule or ErrNoMainFunction if there's no entry point.
+func (i *Interpreter) Run(nodes []ast.Node, links linker.Links) (value.Value, error) {
+	if err := i.Load(nodes, links); err != nil {
+		return value.Value{}, err
+	}
+
+	m, ok := i.modules["main"]
+	if !ok {
+		return value.Value{}, ErrNoMainModule
+	}
+	d, ok := m.decls["main"]
+	if !ok {
+		return value.Value{}, ErrNoMainFunction
+	}
+	if _, ok := d.Value.(ast.FunctionValue); !ok {
+		return value.Value{}, ErrNoMainFunction
+	}
+	return i.call(m, d, nil)
+}
+
+// Call calls the function name from the module with args.
+//
+// Load or Run must be called before, so the modules are loaded.
+func (i *Interpreter) Call(moduleName, name string, args []value.Value) (value.Value, error) {
+	m, ok := i.modules[moduleName]
+	if !ok {
+		return value.Value{}, fmt.Errorf("undefined module %q", moduleName)
+	}
+	d, ok := m.decls[name]
+	if !ok {
+		return value.Value{}, fmt.Errorf("undefined function %s in module %q", name, moduleName)
+	}
+	return i.call(m, d, args)
+}
+
+func (i *Interpreter) load(n ast.Node) error {
+	if _, ok := n.(ast.ImportDeclaration); ok {
+		return nil
+	}
+	md, ok := n.(ast.ModuleDeclaration)
+	if !ok {
+		return fmt.Errorf("unexpected top-level node %T", n)
+	}
+	if _, ok := i.modules[md.Name]; ok {
+		return fmt.Errorf("module %q is declared more than once", md.Name)
+	}
+	m := &module{
+		name:       md.Name,
+		decls:      map[string]*ast.Declaration{},
+		globals:    map[string]value.Value{},
+		evaluating: map[string]bool{},
+	}
+	i.modules[md.Name] = m
+	for _, child := range md.Body {
+		switch child := child.(type) {
+		case *ast.Declaration:
+			m.decls[child.Name] = child
+		case ast.ModuleDeclaration:
+			if err := i.load(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (i *Interpreter) call(m *module, d *ast.Declaration, args []value.Value) (value.Value, error) {
+	m, d, err := i.resolve(m, d)
+	if err != nil {
+		return value.Value{}, err
+	}
+	fn, ok := d.Value.(ast.FunctionValue)
+	if !ok {
+		return value.Value{}, fmt.Errorf("%s is not a function", d.Name)
+	}
+	if len(args) != len(fn.Parameters) {
+		return value.Value{}, fmt.Errorf("function %s expects %d arguments, got %d", d.Name, len(fn.Parameters), len(args))
+	}
+	if i.depth >= MaxDepth {
+		return value.Value{}, ErrStackOverflow
+	}
+	if i.depth == 0 {
+		i.steps = 0
+	}
+	i.depth++
+	defer func() { i.depth-- }()
+
+	f := &frame{module: m, name: d.Name, locals: newLocals(nil)}
+	for idx, p := range fn.Parameters {
+		f.locals.values[p.Identifier] = args[idx]
+	}
+
+	i.outputf("calling %s.%s with %v\n", m.name, d.Name, args)
+	ctl, v, err := i.block(f, fn.Body)
+	switch {
+	case err != nil:
+		return value.Value{}, err
+	case ctl == returned:
+		return v, nil
+	case ctl == jumped:
+		return value.Value{}, fmt.Errorf("in function %s: undefined label %s", f.name, f.target)
+	case ctl != normal:
+		return value.Value{}, fmt.Errorf("in function %s: %s is not in a loop", f.name, ctl)
+	}
+	return value.Value{Kind: value.Nil}, nil
+}
+
+// resolve returns the definition d is linked to, if d is marked with declare or link("module").
+// Otherwise, d is returned as is.
+func (i *Interpreter) resolve(m *module, d *ast.Declaration) (*module, *ast.Declaration, error) {
+	if !d.Declared && !d.Linked {
+		return m, d, nil
+	}
+	def, ok := i.links[d]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s isn't linked to its definition", d.Name)
+	}
+	target, ok := i.modules[def.Module]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is linked to undefined module %q", d.Name, def.Module)
+	}
+	return target, def.Declaration, nil
+}
+
+// control is the way the statement or the block finished.
+type control int
+
+const (
+	normal control = iota
+	returned
+	broke
+	continued
+
+	// jumped means the jump to the label frame.target,
+	// which isn't declared in the block, was taken.
+	jumped
+)
+
+func (c control) String() string {
+	switch c {
+	case broke:
+		return "break"
+	case continued:
+		return "continue"
+	}
+	return "statement"
+}
+
+// block executes statements of the function body or a nested block,
+// which declares locals in its own scope.
+//
+// If the statement jumps to the label declared in the block, the execution continues after it.
+// Otherwise, block stops and returns the control, which is handled by the enclosing statement.
+func (i *Interpreter) block(f *frame, body []ast.Node) (control, value.Value, error) {
+	if err := i.step(f); err != nil {
+		return normal, value.Value{}, err
+	}
+	outer := f.locals
+	f.locals = newLocals(outer)
+	defer func() { f.locals = outer }()
+
+	labels := labels(body)
+	for pc := 0; pc < len(body); pc++ {
+		if err := i.step(f); err != nil {
+			return normal, value.Value{}, err
+		}
+		ctl, v, err := i.exec(f, body[pc])
+		if err != nil {
+			return normal, value.Value{}, err
+		}
+		if ctl == jumped {
+			if target, ok := labels[f.target]; ok {
+				pc = target
+				continue
+			}
+		}
+		if ctl != normal {
+			return ctl, v, nil
+		}
+	}
+	return normal, value.Value{}, nil
+}
+
+// step counts the executed statement or block in the pure mode,
+// returning ErrStepLimit once there are more than MaxSteps of them.
+func (i *Interpreter) step(f *frame) error {
+	if !i.pure {
+		return nil
+	}
+	i.steps++
+	if i.steps > MaxSteps {
+		return fmt.Errorf("in function %s: %w", f.name, ErrStepLimit)
+	}
+	return nil
+}
+
+// labels returns indexes of labels declared in body.
+func labels(body []ast.Node) map[string]int {
+	labels := map[string]int{}
+	for idx, stmt := range body {
+		if l, ok := stmt.(ast.Label); ok {
+			labels[l.Name] = idx
+		}
+	}
+	return labels
+}
+
+func (i *Interpreter) exec(f *frame, stmt ast.Node) (control, value.Value, error) {
+	switch stmt := stmt.(type) {
+	case ast.Label:
+		return normal, value.Value{}, nil
+
+	case ast.Jump:
+		if stmt.Name != "jmp" && !f.compared {
+			return normal, value.Value{}, fmt.Errorf("in function %s: %s: %w", f.name, stmt.Name, ErrNoComparison)
+		}
+		jumps, err := value.Jumps(stmt.Name, f.result)
+		if err != nil {
+			return normal, value.Value{}, fmt.Errorf("in function %s: %w", f.name, err)
+		}
+		if !jumps {
+			return normal, value.Value{}, nil
+		}
+		f.target = stmt.Label
+		return jumped, value.Value{}, nil
+
+	case ast.LocalDeclaration:
+		v, err := i.eval(f, stmt.Value)
+		if err != nil {
+			return normal, value.Value{}, fmt.Errorf("in function %s: local %s: %w", f.name, stmt.Name, err)
+		}
+		f.locals.values[stmt.Name] = v
+		return normal, value.Value{}, nil
+
+	case ast.IfStatement:
+		ok, err := i.condition(f, stmt.Condition)
+		if err != nil {
+			return normal, value.Value{}, err
+		}
+		if ok {
+			return i.block(f, stmt.Body)
+		}
+		return i.block(f, stmt.Else)
+
+	case ast.LoopStatement:
+		for {
+			if stmt.Condition != nil {
+				ok, err := i.condition(f, stmt.Condition)
+				if err != nil {
+					return normal, value.Value{}, err
+				}
+				if !ok {
+					return normal, value.Value{}, nil
+				}
+			}
+			ctl, v, err := i.block(f, stmt.Body)
+			if err != nil {
+				return normal, value.Value{}, err
+			}
+			switch ctl {
+			case broke:
+				return normal, value.Value{}, nil
+			case returned, jumped:
+				return ctl, v, nil
+			}
+		}
+
+	case ast.BreakStatement:
+		return broke, value.Value{}, nil
+
+	case ast.ContinueStatement:
+		return continued, value.Value{}, nil
+
+	case ast.InstructionCall:
+		v, ret, err := i.instruction(f, stmt)
+		if err != nil {
+			return normal, value.Value{}, fmt.Errorf("in function %s: %s: %w", f.name, stmt.Name, err)
+		}
+		if ret {
+			return returned, v, nil
+		}
+		return normal, value.Value{}, nil
+	}
+	return normal, value.Value{}, fmt.Errorf("in function %s: unexpected statement %T", f.name, stmt)
+}
+
+// condition evaluates the condition of if or while, which must be a bool.
+func (i *Interpreter) condition(f *frame, n ast.Node) (bool, error) {
+	v, err := i.eval(f, n)
+	if err != nil {
+		return false, fmt.Errorf("in function %s: condition: %w", f.name, err)
+	}
+	if v.Kind != value.Bool {
+		return false, fmt.Errorf("in function %s: condition must be bool, got %s", f.name, v.Kind)
+	}
+	return v.Bool, nil
+}
+
+func (i *Interpreter) instruction(f *frame, ic ast.InstructionCall) (value.Value, bool, error) {
+	if ic.IsUser {
+		d, ok := f.module.decls[ic.Name]
+		if !ok {
+			return value.Value{}, false, fmt.Errorf("undefined function %s", ic.Name)
+		}
+		args, err := i.arguments(f, ic.Arguments)
+		if err != nil {
+			return value.Value{}, false, err
+		}
+		_, err = i.call(f.module, d, args)
+		return value.Value{}, false, err
+	}
+
+	switch ic.Name {
+	case "mov":
+		if len(ic.Arguments) != 2 {
+			return value.Value{}, false, fmt.Errorf("expected 2 arguments, got %d", len(ic.Arguments))
+		}
+		src, err := i.eval(f, ic.Arguments[1].Value)
+		if err != nil {
+			return value.Value{}, false, err
+		}
+		return value.Value{}, false, i.store(f, ic.Arguments[0].Value, src)
+
+	case "add", "sub", "mul", "div":
+		var x, y value.Value
+		var err error
+		switch len(ic.Arguments) {
+		case 2:
+			x, err = i.eval(f, ic.Arguments[0].Value)
+			if err != nil {
+				return value.Value{}, false, err
+			}
+			y, err = i.eval(f, ic.Arguments[1].Value)
+		case 3:
+			x, err = i.eval(f, ic.Arguments[1].Value)
+			if err != nil {
+				return value.Value{}, false, err
+			}
+			y, err = i.eval(f, ic.Arguments[2].Value)
+		default:
+			return value.Value{}, false, fmt.Errorf("expected 2 or 3 arguments, got %d", len(ic.Arguments))
+		}
+		if err != nil {
+			return value.Value{}, false, err
+		}
+		result, err := value.Apply(ic.Name, x, y)
+		if err != nil {
+			return value.Value{}, false, err
+		}
+		return value.Value{}, false, i.store(f, ic.Arguments[0].Value, result)
+
+	case "cmp":
+		if len(ic.Arguments) != 2 {
+			return value.Value{}, false, fmt.Errorf("expected 2 arguments, got %d", len(ic.Arguments))
+		}
+		args, err := i.arguments(f, ic.Arguments)
+		if err != nil {
+			return value.Value{}, false, err
+		}
+		result, err := value.Compare(args[0], args[1])
+		if err != nil {
+			return value.Value{}, false, err
+		}
+		f.compared, f.result = true, result
+		return value.Value{}, false, nil
+
+	case "ret":
+		switch len(ic.Arguments) {
+		case 0:
+			return value.Value{Kind: value.Nil}, true, nil
+		case 1:
+			v, err := i.eval(f, ic.Arguments[0].Value)
+			return v, true, err
+		}
+		return value.Value{}, false, fmt.Errorf("expected at most 1 argument, got %d", len(ic.Arguments))
+
+	case "stdout", "stderr":
+		if i.pure {
+			return value.Value{}, false, ErrImpure
+		}
+		args, err := i.arguments(f, ic.Arguments)
+		if err != nil {
+			return value.Value{}, false, err
+		}
+		parts := make([]string, len(args))
+		for idx, a := range args {
+			parts[idx] = a.String()
+		}
+		w := i.stdout
+		if ic.Name == "stderr" {
+			w = i.stderr
+		}
+		_, err = fmt.Fprintln(w, strings.Join(parts, " "))
+		return value.Value{}, false, err
+	}
+	return value.Value{}, false, fmt.Errorf("unknown instruction")
+}
+
+func (i *Interpreter) arguments(f *frame, args []ast.InstructionCallArgument) ([]value.Value, error) {
+	result := make([]value.Value, len(args))
+	for idx, a := range args {
+		v, err := i.eval(f, a.Value)
+		if err != nil {
+			return nil, err
+		}
+		result[idx] = v
+	}
+	return result, nil
+}
+
+func (i *Interpreter) eval(f *frame, n ast.Node) (value.Value, error) {
+	switch n := n.(type) {
+	case ast.Value:
+		switch {
+		case n.Copied:
+			v, err := i.eval(f, n.ValueNode)
+			return v.Copy(), err
+		case n.Consteval:
+			return i.eval(f, n.ValueNode)
+		case n.ValueNode != nil:
+			return i.eval(f, n.ValueNode)
+		case n.Kind == token.Identifier:
+			return i.lookup(f, n.Value)
+		}
+		return value.FromLiteral(n.Kind, n.Value)
+
+	case ast.BinaryExpression:
+		if len(n.Children) != 2 {
+			return value.Value{}, fmt.Errorf("binary expression %s expects 2 operands, got %d", n.Operator, len(n.Children))
+		}
+		x, err := i.eval(f, n.Children[0])
+		if err != nil {
+			return value.Value{}, err
+		}
+		y, err := i.eval(f, n.Children[1])
+		if err != nil {
+			return value.Value{}, err
+		}
+		return value.Binary(n.Operator, x, y)
+
+	case ast.CallExpression:
+		d, ok := f.module.decls[n.Name]
+		if !ok {
+			return value.Value{}, fmt.Errorf("undefined function %s", n.Name)
+		}
+		args := make([]value.Value, len(n.Arguments))
+		for idx, a := range n.Arguments {
+			v, err := i.eval(f, a)
+			if err != nil {
+				return value.Value{}, err
+			}
+			args[idx] = v
+		}
+		return i.call(f.module, d, args)
+
+	case ast.ArrayValue:
+		elements := make([]value.Value, len(n.Elements))
+		for idx, e := range n.Elements {
+			v, err := i.eval(f, e.Value)
+			if err != nil {
+				return value.Value{}, err
+			}
+			elements[idx] = v
+		}
+		return value.NewArray(elements), nil
+	}
+	return value.Value{}, fmt.Errorf("can't evaluate %T", n)
+}
+
+func (i *Interpreter) lookup(f *frame, name string) (value.Value, error) {
+	if values := f.locals.find(name); values != nil {
+		return values[name], nil
+	}
+	return i.global(f.module, name)
+}
+
+// global returns the value of the module declaration name,
+// evaluating it on the first use.
+//
+// Declarations marked with declare or link("module") have the value of their definition.
+func (i *Interpreter) global(m *module, name string) (value.Value, error) {
+	d, ok := m.decls[name]
+	if !ok {
+		return value.Value{}, fmt.Errorf("undefined identifier %s", name)
+	}
+	m, d, err := i.resolve(m, d)
+	if err != nil {
+		return value.Value{}, err
+	}
+	name = d.Name
+	if v, ok := m.globals[name]; ok {
+		return v, nil
+	}
+	if _, ok := d.Value.(ast.FunctionValue); ok {
+		return value.Value{}, fmt.Errorf("function %s can't be used as a value", name)
+	}
+	if m.evaluating[name] {
+		return value.Value{}, fmt.Errorf("initialization cycle of %s", name)
+	}
+	m.evaluating[name] = true
+	defer delete(m.evaluating, name)
+
+	v, err := i.eval(&frame{module: m, name: name}, d.Value)
+	if err != nil {
+		return value.Value{}, err
+	}
+	m.globals[name] = v
+	return v, nil
+}
+
+func (i *Interpreter) store(f *frame, dst ast.Node, v value.Value) error {
+	ident, ok := dst.(ast.Value)
+	if !ok || ident.Kind != token.Identifier {
+		return errors.New("destination must be an identifier")
+	}
+	if values := f.locals.find(ident.Value); values != nil {
+		values[ident.Value] = v
+		return nil
+	}
+	if d, ok := f.module.decls[ident.Value]; ok {
+		m, d, err := i.resolve(f.module, d)
+		if err != nil {
+			return err
+		}
+		if _, ok := d.Value.(ast.FunctionValue); ok {
+			return fmt.Errorf("can't assign to function %s", ident.Value)
+		}
+		if i.pure {
+			return ErrImpure
+		}
+		m.globals[d.Name] = v
+		return nil
+	}
+	return fmt.Errorf("undefined identifier %s", ident.Value)
+}
+
+// locals holds local variables declared in the block,
+// with locals of the enclosing block as the parent.
+type locals struct {
+	parent *locals
+	values map[string]value.Value
+}
+
+func newLocals(parent *locals) *locals {
+	return &locals{parent: parent, values: map[string]value.Value{}}
+}
+
+// find returns values of the innermost block declaring name,
+// or nil if there's no such local.
+func (l *locals) find(name string) map[string]value.Value {
+	for scope := l; scope != nil; scope = scope.parent {
+		if _, ok := scope.values[name]; ok {
+			return scope.values
+		}
+	}
+	return nil
+}
+
+func (i *Interpreter) outputf(format string, v ...any) {
+	if i.debug {
+		log.Printf(format, v...)
+	}
+}
+
+func (i *Interpreter) reset() {
+	i.modules = map[string]*module{}
+	i.depth = 0
+}
@@ -0,0 +1,126 @@
+package interp_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/internal/parsetest"
+	"github.com/dywoq/dywoqlang/interp"
+	"github.com/dywoq/dywoqlang/value"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		result string
+		stdout string
+	}{
+		{
+			name:   "return value",
+			src:    `"main": { main i32 () { ret 6 * 7; } }`,
+			result: "42",
+		},
+		{
+			name:   "arguments are passed by value",
+			src:    `"main": { inc void (x i32) { add x, x, 1; } main i32 () { x i32 1; [inc] x; ret x; } }`,
+			result: "1",
+		},
+		{
+			name:   "globals are initialized once",
+			src:    `"main": { n i32 [next]() next i32 () { stdout "init"; ret 1; } main void () { stdout n, n; } }`,
+			result: "nil",
+			stdout: "init\n1 1\n",
+		},
+		{
+			name:   "nested modules",
+			src:    `"main": { "inner": { } main str () { ret "a" + "b"; } }`,
+			result: "ab",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			i := interp.New(false)
+			i.SetOutput(&stdout, &bytes.Buffer{})
+			v, err := i.Run(parsetest.Parse(t, tt.src), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.String() != tt.result {
+				t.Errorf("returned %s, want %s", v, tt.result)
+			}
+			if stdout.String() != tt.stdout {
+				t.Errorf("printed %q, want %q", stdout.String(), tt.stdout)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  error
+	}{
+		{"no main module", `"lib": { main void () { } }`, interp.ErrNoMainModule},
+		{"no main function", `"main": { start void () { } }`, interp.ErrNoMainFunction},
+		{"main is not a function", `"main": { main i32 1 }`, interp.ErrNoMainFunction},
+		{"infinite recursion", `"main": { main void () { [main]; } }`, interp.ErrStackOverflow},
+		{"conditional jump without cmp", `"main": { main void () { je end; end: } }`, interp.ErrNoComparison},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := interp.New(false).Run(parsetest.Parse(t, tt.src), nil)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPure(t *testing.T) {
+	src := `"main": {
+		counter i32 0
+		sq i32 (x i32) { ret x * x; }
+		loud i32 (x i32) { stdout x; ret x; }
+		count void () { add counter, counter, 1; }
+		spin void () { loop { } }
+		jump void () { start: jmp start; }
+	}`
+	tests := []struct {
+		name   string
+		fn     string
+		args   []value.Value
+		result string
+		err    error
+	}{
+		{name: "pure function", fn: "sq", args: []value.Value{value.NewInt(7)}, result: "49"},
+		{name: "output", fn: "loud", args: []value.Value{value.NewInt(7)}, err: interp.ErrImpure},
+		{name: "assignment of module declaration", fn: "count", err: interp.ErrImpure},
+		{name: "infinite loop", fn: "spin", err: interp.ErrStepLimit},
+		{name: "infinite jumps", fn: "jump", err: interp.ErrStepLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := interp.New(false)
+			i.SetPure(true)
+			if err := i.Load(parsetest.Parse(t, src), nil); err != nil {
+				t.Fatal(err)
+			}
+			v, err := i.Call("main", tt.fn, tt.args)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && v.String() != tt.result {
+				t.Errorf("returned %s, want %s", v, tt.result)
+			}
+
+			// the steps are counted per call, so the next call isn't limited by this one.
+			if v, err := i.Call("main", "sq", []value.Value{value.NewInt(3)}); err != nil || v.String() != "9" {
+				t.Errorf("the next call returned %s, %v, want 9", v, err)
+			}
+		})
+	}
+}
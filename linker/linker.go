@@ -0,0 +1,211 @@
+// Package linker resolves declarations marked with declare or link("module")
+// to the exported definitions in other modules.
+package linker
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+)
+
+// Linker matches declarations to their definitions:
+//
+//   - `link("module") name ...` is defined by the exported name of the module;
+//   - `declare name ...` is defined by the exported name of any other module,
+//     which must be the only module exporting it.
+//
+// Definitions are declarations that are neither declared nor linked.
+// Declarations marked with link(false) can't be linked in either direction,
+// and the declaration must have the same type and parameter types as its definition.
+type Linker struct {
+	modules map[string]*module
+	order   []*module
+	links   Links
+	errors  diag.Diagnostics
+
+	debug bool
+}
+
+type module struct {
+	name  string
+	decls map[string]*ast.Declaration
+	order []*ast.Declaration
+}
+
+// Definition is the definition the declaration is linked to.
+type Definition struct {
+	Module      string
+	Declaration *ast.Declaration
+}
+
+// Links maps declarations marked with declare or link("module") to their definitions.
+type Links map[*ast.Declaration]Definition
+
+// New returns a new pointer to Linker.
+func New(debug bool) *Linker {
+	return &Linker{debug: debug}
+}
+
+// Link links declarations of nodes returned by parser.Parser.Parse,
+// or by loader.Program.Nodes for programs of multiple files.
+//
+// The linker expects duplicate modules and declarations to be reported by the semantic analysis,
+// so only the first one of them is used.
+//
+// Returns the links of resolved declarations,
+// along with diag.Diagnostics if some declarations can't be linked.
+func (l *Linker) Link(nodes []ast.Node) (Links, error) {
+	l.reset()
+	for _, n := range nodes {
+		if md, ok := n.(ast.ModuleDeclaration); ok {
+			l.collect(md)
+		}
+	}
+
+	for _, m := range l.order {
+		for _, d := range m.order {
+			if d.Declared || d.Linked {
+				l.link(m, d)
+			}
+		}
+	}
+
+	if len(l.errors) != 0 {
+		return l.links, l.errors
+	}
+	return l.links, nil
+}
+
+func (l *Linker) collect(md ast.ModuleDeclaration) {
+	if _, ok := l.modules[md.Name]; ok {
+		return
+	}
+	m := &module{name: md.Name, decls: map[string]*ast.Declaration{}}
+	l.modules[md.Name] = m
+	l.order = append(l.order, m)
+	for _, n := range md.Body {
+		switch n := n.(type) {
+		case *ast.Declaration:
+			if _, ok := m.decls[n.Name]; !ok {
+				m.decls[n.Name] = n
+				m.order = append(m.order, n)
+			}
+		case ast.ModuleDeclaration:
+			l.collect(n)
+		}
+	}
+}
+
+// link finds the definition of d declared in m.
+func (l *Linker) link(m *module, d *ast.Declaration) {
+	if !d.CanBeLinked {
+		l.errorf(diag.CodeNotLinkable, m, d, "%s is marked with link(false), so it can't be declared", d.Name)
+		return
+	}
+
+	var def Definition
+	if d.Linked {
+		target, ok := l.modules[d.LinkedFrom]
+		if !ok {
+			l.errorf(diag.CodeMissingDefinition, m, d, "%s is linked from undefined module %q", d.Name, d.LinkedFrom)
+			return
+		}
+		found, ok := target.decls[d.Name]
+		if !ok || !defines(found) {
+			l.errorf(diag.CodeMissingDefinition, m, d, "module %q doesn't define %s", target.name, d.Name)
+			return
+		}
+		if !found.Exported {
+			l.errorf(diag.CodeNotLinkable, m, d, "module %q doesn't export %s", target.name, d.Name)
+			return
+		}
+		if !found.CanBeLinked {
+			l.errorf(diag.CodeNotLinkable, m, d, "%s is marked with link(false) in module %q, so it can't be linked", d.Name, target.name).
+				Label(found, target.name, fmt.Sprintf("defined in module %q", target.name))
+			return
+		}
+		def = Definition{Module: target.name, Declaration: found}
+	} else {
+		var candidates []Definition
+		for _, target := range l.order {
+			if target == m {
+				continue
+			}
+			found, ok := target.decls[d.Name]
+			if ok && defines(found) && found.Exported && found.CanBeLinked {
+				candidates = append(candidates, Definition{Module: target.name, Declaration: found})
+			}
+		}
+		switch len(candidates) {
+		case 0:
+			l.errorf(diag.CodeMissingDefinition, m, d, "no exported definition of declared %s", d.Name)
+			return
+		case 1:
+			def = candidates[0]
+		default:
+			names := make([]string, len(candidates))
+			for i, c := range candidates {
+				names[i] = fmt.Sprintf("%q", c.Module)
+			}
+			err := l.errorf(diag.CodeDuplicateDefinition, m, d, "%s is defined in more than one module: %s", d.Name, strings.Join(names, ", "))
+			for _, c := range candidates {
+				err.Label(c.Declaration, c.Module, fmt.Sprintf("defined in module %q", c.Module))
+			}
+			return
+		}
+	}
+
+	if got, want := signature(d), signature(def.Declaration); got != want {
+		l.errorf(diag.CodeSignatureMismatch, m, d, "%s is declared as %s, but defined as %s in module %q", d.Name, got, want, def.Module).
+			Label(def.Declaration, def.Module, fmt.Sprintf("defined in module %q", def.Module))
+		return
+	}
+	l.outputf("linked %s.%s to %s.%s\n", m.name, d.Name, def.Module, def.Declaration.Name)
+	l.links[d] = def
+}
+
+// defines reports whether d is a definition rather than a declaration.
+func defines(d *ast.Declaration) bool {
+	return !d.Declared && !d.Linked
+}
+
+// signature returns the type of d, followed by the parameter types if d is a function,
+// such as `i32 (i32, str copy(false))`.
+func signature(d *ast.Declaration) string {
+	fn, ok := d.Value.(ast.FunctionValue)
+	if !ok {
+		return d.Kind
+	}
+	params := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		params[i] = p.Kind
+		if !p.CopyAllowed {
+			params[i] += " copy(false)"
+		}
+	}
+	return fmt.Sprintf("%s (%s)", d.Kind, strings.Join(params, ", "))
+}
+
+// errorf reports the error pointing to d declared in m, returning its diagnostic,
+// so the caller can add labels to it.
+func (l *Linker) errorf(code diag.Code, m *module, d *ast.Declaration, format string, v ...any) *diag.Diagnostic {
+	err := diag.Errorf(code, format, v...).Span(d).In(m.name, "")
+	l.errors = append(l.errors, err)
+	return err
+}
+
+func (l *Linker) outputf(format string, v ...any) {
+	if l.debug {
+		log.Printf(format, v...)
+	}
+}
+
+func (l *Linker) reset() {
+	l.modules = map[string]*module{}
+	l.order = nil
+	l.links = Links{}
+	l.errors = nil
+}
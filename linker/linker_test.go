@@ -0,0 +1,165 @@
+package linker_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/internal/parsetest"
+	"github.com/dywoq/dywoqlang/linker"
+)
+
+// declarations returns the declarations of nodes by the module-qualified name, such as "main.f".
+func declarations(nodes []ast.Node) map[string]*ast.Declaration {
+	decls := map[string]*ast.Declaration{}
+	for _, n := range nodes {
+		ast.Inspect(n, func(n ast.Node) bool {
+			md, ok := n.(ast.ModuleDeclaration)
+			if !ok {
+				return false
+			}
+			for _, child := range md.Body {
+				if d, ok := child.(*ast.Declaration); ok {
+					decls[md.Name+"."+d.Name] = d
+				}
+			}
+			return true
+		})
+	}
+	return decls
+}
+
+func TestLink(t *testing.T) {
+	nodes := parsetest.Parse(t, `
+		"lib": {
+			export sq i32 (x i32) { ret x * x; }
+			export twice i32 (x i32) { ret x + x; }
+			"nested": { export f void (s str copy(false)) { } }
+		}
+		"main": {
+			declare sq i32 (x i32)
+			link("lib") twice i32 (x i32)
+			declare f void (s str copy(false))
+		}
+	`)
+	links, err := linker.New(false).Link(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decls := declarations(nodes)
+	want := linker.Links{
+		decls["main.sq"]:    {Module: "lib", Declaration: decls["lib.sq"]},
+		decls["main.twice"]: {Module: "lib", Declaration: decls["lib.twice"]},
+		decls["main.f"]:     {Module: "nested", Declaration: decls["nested.f"]},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("got %d links, want %d", len(links), len(want))
+	}
+	for d, def := range want {
+		if links[d] != def {
+			t.Errorf("%s is linked to %+v, want %+v", d.Name, links[d], def)
+		}
+	}
+}
+
+func TestLinkErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		codes  []diag.Code
+		labels int
+	}{
+		{
+			name:  "no definition",
+			src:   `"main": { declare f void () }`,
+			codes: []diag.Code{diag.CodeMissingDefinition},
+		},
+		{
+			name:  "definition isn't exported",
+			src:   `"lib": { f void () { } } "main": { declare f void () }`,
+			codes: []diag.Code{diag.CodeMissingDefinition},
+		},
+		{
+			name:  "definition of the same module",
+			src:   `"main": { export f void () { } declare g void () }`,
+			codes: []diag.Code{diag.CodeMissingDefinition},
+		},
+		{
+			name:  "undefined module",
+			src:   `"main": { link("lib") f void () }`,
+			codes: []diag.Code{diag.CodeMissingDefinition},
+		},
+		{
+			name:  "module doesn't define",
+			src:   `"lib": { } "main": { link("lib") f void () }`,
+			codes: []diag.Code{diag.CodeMissingDefinition},
+		},
+		{
+			name:  "module only declares",
+			src:   `"other": { export f void () { } } "lib": { declare f void () } "main": { link("lib") f void () }`,
+			codes: []diag.Code{diag.CodeMissingDefinition},
+		},
+		{
+			name:   "ambiguous definition",
+			src:    `"a": { export f void () { } } "b": { export f void () { } } "main": { declare f void () }`,
+			codes:  []diag.Code{diag.CodeDuplicateDefinition},
+			labels: 2,
+		},
+		{
+			name:  "linked definition isn't exported",
+			src:   `"lib": { f void () { } } "main": { link("lib") f void () }`,
+			codes: []diag.Code{diag.CodeNotLinkable},
+		},
+		{
+			name:  "link(false) declaration",
+			src:   `"lib": { export f void () { } } "main": { link(false) declare f void () }`,
+			codes: []diag.Code{diag.CodeNotLinkable},
+		},
+		{
+			name:  "link(false) definition",
+			src:   `"lib": { export link(false) f void () { } } "main": { declare f void () }`,
+			codes: []diag.Code{diag.CodeMissingDefinition},
+		},
+		{
+			name:   "link(false) linked definition",
+			src:    `"lib": { export link(false) f i32 () { ret 1; } } "main": { link("lib") f i32 () }`,
+			codes:  []diag.Code{diag.CodeNotLinkable},
+			labels: 1,
+		},
+		{
+			name:   "type mismatch",
+			src:    `"lib": { export f i32 () { ret 1; } } "main": { declare f i64 () }`,
+			codes:  []diag.Code{diag.CodeSignatureMismatch},
+			labels: 1,
+		},
+		{
+			name:   "parameter mismatch",
+			src:    `"lib": { export f void (x i32) { } } "main": { link("lib") f void (x i32 copy(false)) }`,
+			codes:  []diag.Code{diag.CodeSignatureMismatch},
+			labels: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := linker.New(false).Link(parsetest.Parse(t, tt.src))
+			var list diag.Diagnostics
+			list.Add(err)
+			var codes []diag.Code
+			labels := 0
+			for _, d := range list {
+				codes = append(codes, d.Code)
+				labels += len(d.Labels)
+				if d.Module != "main" {
+					t.Errorf("reported in module %q, want \"main\"", d.Module)
+				}
+			}
+			if !slices.Equal(codes, tt.codes) {
+				t.Errorf("got codes %v, want %v", codes, tt.codes)
+			}
+			if labels != tt.labels {
+				t.Errorf("got %d labels, want %d", labels, tt.labels)
+			}
+		})
+	}
+}
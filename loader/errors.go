@@ -0,0 +1,7 @@
+package loader
+
+import "errors"
+
+// ErrImportCycle is reported by the loader if the file imports itself,
+// directly or through other files.
+var ErrImportCycle = errors.New("import cycle")
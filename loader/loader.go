@@ -0,0 +1,163 @@
+// Package loader loads the program from the entry files
+// along with all files they import.
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/parser"
+	"github.com/dywoq/dywoqlang/scanner"
+)
+
+// Loader scans and parses the entry files and, recursively, the files they import.
+//
+// The import path is relative to the directory of the importing file,
+// and gets the .dl extension if it has none, so `import "std/io"` in `src/main.dl`
+// loads `src/std/io.dl`.
+// Each file is loaded once, no matter how many files import it.
+type Loader struct {
+	files       map[string]*File
+	loading     []string
+	program     *Program
+	diagnostics diag.Diagnostics
+
+	debug bool
+}
+
+// New returns a new pointer to Loader.
+func New(debug bool) *Loader {
+	return &Loader{debug: debug}
+}
+
+// Load loads the files at paths and all files they import.
+//
+// Load doesn't stop on the first error: scanner and parser errors,
+// imports that can't be read and import cycles are returned at once as diag.Diagnostics
+// along with the partial program.
+// The program is nil only if one of the files at paths can't be read.
+func (l *Loader) Load(paths ...string) (*Program, error) {
+	l.reset()
+	for _, path := range paths {
+		if _, err := l.load(path); err != nil {
+			return nil, err
+		}
+	}
+	return l.program, l.diagnostics.Err()
+}
+
+// load loads the file at path unless it's already loaded.
+// Returns an error only if the file can't be read.
+func (l *Loader) load(path string) (*File, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := l.files[abs]; ok {
+		return f, nil
+	}
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l.outputf("loading %s\n", path)
+
+	f := &File{Path: path, Source: string(bytes)}
+	l.files[abs] = f
+	l.loading = append(l.loading, abs)
+
+	f.Tokens, err = scanner.New(l.debug).Scan(f.Source)
+	l.add(path, err)
+	if len(f.Tokens) != 0 {
+		f.Nodes, err = parser.New(l.debug).Parse(f.Tokens)
+		l.add(path, err)
+	}
+	for _, n := range f.Nodes {
+		if imp, ok := n.(ast.ImportDeclaration); ok {
+			l.importFile(f, imp)
+		}
+	}
+
+	l.loading = l.loading[:len(l.loading)-1]
+	l.program.add(f)
+	return f, nil
+}
+
+// importFile loads the file imported by imp in f,
+// reporting import cycles and files that can't be read.
+func (l *Loader) importFile(f *File, imp ast.ImportDeclaration) {
+	if imp.Path == "" {
+		l.report(f, imp, errors.New("empty import path"))
+		return
+	}
+	path := resolve(f.Path, imp.Path)
+	if abs, err := filepath.Abs(path); err == nil {
+		if i := slices.Index(l.loading, abs); i >= 0 {
+			var chain []string
+			for _, loading := range l.loading[i:] {
+				chain = append(chain, l.files[loading].Path)
+			}
+			chain = append(chain, path)
+			l.report(f, imp, fmt.Errorf("%w: %s", ErrImportCycle, strings.Join(chain, " -> ")))
+			return
+		}
+	}
+	imported, err := l.load(path)
+	if err != nil {
+		l.report(f, imp, fmt.Errorf("can't import %q: %w", imp.Path, err))
+		return
+	}
+	f.Imports = append(f.Imports, imported)
+}
+
+// resolve returns the path of the file imported as path from the file at from.
+func resolve(from, path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.Ext(path) == "" {
+		path += ".dl"
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(from), path)
+}
+
+func (l *Loader) report(f *File, imp ast.ImportDeclaration, err error) {
+	d := diag.New(diag.Error, diag.CodeImport, err.Error()).Span(imp)
+	d.File = f.Path
+	l.diagnostics = append(l.diagnostics, d)
+}
+
+// add adds err of the scanner or parser to the diagnostics of the file at path.
+func (l *Loader) add(path string, err error) {
+	if err == nil {
+		return
+	}
+	var list diag.Diagnostics
+	list.Add(err)
+	list.SetFile(path)
+	l.diagnostics = append(l.diagnostics, list...)
+}
+
+func (l *Loader) reset() {
+	l.files = map[string]*File{}
+	l.loading = nil
+	l.program = &Program{
+		Modules:   map[string]ast.ModuleDeclaration{},
+		declaring: map[string]*File{},
+	}
+	l.diagnostics = nil
+}
+
+func (l *Loader) outputf(format string, v ...any) {
+	if l.debug {
+		log.Printf(format, v...)
+	}
+}
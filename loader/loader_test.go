@@ -0,0 +1,139 @@
+package loader_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/loader"
+)
+
+// write writes files keyed by their slash-separated paths into a temporary directory,
+// returning the directory.
+func write(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := write(t, map[string]string{
+		"main.dl":      "import \"a\"\nimport \"lib/b.dl\"\n\"main\": { }",
+		"a.dl":         "import \"lib/c\"\n\"a\": { }",
+		"lib/b.dl":     "import \"c\"\n\"b\": { \"nested\": { } }",
+		"lib/c.dl":     "\"c\": { }",
+		"lib/other.dl": "\"other\": { }",
+	})
+	p, err := loader.New(false).Load(filepath.Join(dir, "main.dl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range p.Files {
+		rel, err := filepath.Rel(dir, f.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.ToSlash(rel))
+	}
+	if got, want := strings.Join(names, " "), "lib/c.dl a.dl lib/b.dl main.dl"; got != want {
+		t.Errorf("loaded %s, want %s", got, want)
+	}
+	if len(p.Nodes()) != 4 {
+		t.Errorf("got %d module declarations, want 4", len(p.Nodes()))
+	}
+	if _, ok := p.Modules["nested"]; !ok {
+		t.Error("nested module is missing")
+	}
+	if f := p.File("nested"); f == nil || filepath.Base(f.Path) != "b.dl" {
+		t.Errorf("nested is declared in %+v, want b.dl", f)
+	}
+	if p.File("other") != nil {
+		t.Error("file that isn't imported was loaded")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		file    string
+		code    diag.Code
+		message string
+	}{
+		{
+			name:    "import cycle",
+			files:   map[string]string{"main.dl": `import "a"`, "a.dl": `import "b"`, "b.dl": `import "a"`},
+			file:    "b.dl",
+			code:    diag.CodeImport,
+			message: "import cycle",
+		},
+		{
+			name:    "import of itself",
+			files:   map[string]string{"main.dl": `import "main"`},
+			file:    "main.dl",
+			code:    diag.CodeImport,
+			message: "import cycle",
+		},
+		{
+			name:    "missing file",
+			files:   map[string]string{"main.dl": `import "missing"`},
+			file:    "main.dl",
+			code:    diag.CodeImport,
+			message: `can't import "missing"`,
+		},
+		{
+			name:    "empty path",
+			files:   map[string]string{"main.dl": `import ""`},
+			file:    "main.dl",
+			code:    diag.CodeImport,
+			message: "empty import path",
+		},
+		{
+			name:  "syntax error of imported file",
+			files: map[string]string{"main.dl": `import "a"`, "a.dl": `"a": { x i32 }`},
+			file:  "a.dl",
+			code:  diag.CodeSyntax,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := write(t, tt.files)
+			p, err := loader.New(false).Load(filepath.Join(dir, "main.dl"))
+			if p == nil {
+				t.Fatalf("got no program, error %v", err)
+			}
+			var list diag.Diagnostics
+			list.Add(err)
+			if len(list) != 1 {
+				t.Fatalf("got %d diagnostics %v, want 1", len(list), list)
+			}
+			d := list[0]
+			if d.Code != tt.code || !strings.Contains(d.Message, tt.message) {
+				t.Errorf("got %s %q, want %s containing %q", d.Code, d.Message, tt.code, tt.message)
+			}
+			if filepath.Base(d.File) != tt.file {
+				t.Errorf("reported in %s, want %s", d.File, tt.file)
+			}
+		})
+	}
+}
+
+func TestLoadMissingEntry(t *testing.T) {
+	p, err := loader.New(false).Load(filepath.Join(t.TempDir(), "missing.dl"))
+	if p != nil || !os.IsNotExist(err) {
+		t.Errorf("got program %v and error %v, want no program and missing file", p, err)
+	}
+}
@@ -0,0 +1,67 @@
+package loader
+
+import (
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// File is a scanned and parsed source file.
+type File struct {
+	Path    string
+	Source  string
+	Tokens  []*token.Token
+	Nodes   []ast.Node
+	Imports []*File
+}
+
+// Program is a set of loaded files.
+type Program struct {
+	// Files are the loaded files, each one preceded by the files it imports.
+	Files []*File
+
+	// Modules are the module declarations of all files, including nested ones,
+	// keyed by the module name.
+	//
+	// If the module is declared more than once, only the first declaration is kept:
+	// duplicates are reported by sema.Analyzer.
+	Modules map[string]ast.ModuleDeclaration
+
+	declaring map[string]*File
+}
+
+// Nodes returns the top-level module declarations of all files in the loading order,
+// ready to be passed to the analysis stages, compiler or interpreter.
+func (p *Program) Nodes() []ast.Node {
+	var nodes []ast.Node
+	for _, f := range p.Files {
+		for _, n := range f.Nodes {
+			if _, ok := n.(ast.ModuleDeclaration); ok {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+// File returns the file declaring the module,
+// or nil if there's no such module.
+func (p *Program) File(module string) *File {
+	return p.declaring[module]
+}
+
+func (p *Program) add(f *File) {
+	p.Files = append(p.Files, f)
+	for _, n := range f.Nodes {
+		ast.Inspect(n, func(n ast.Node) bool {
+			md, ok := n.(ast.ModuleDeclaration)
+			if !ok {
+				return false
+			}
+			if _, ok := p.Modules[md.Name]; !ok {
+				p.Modules[md.Name] = md
+				p.declaring[md.Name] = f
+			}
+			return true
+		})
+	}
+}
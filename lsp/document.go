@@ -0,0 +1,355 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/parser"
+	"github.com/dywoq/dywoqlang/scanner"
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// symbolKind is a kind of symbol.
+type symbolKind int
+
+const (
+	moduleSymbol symbolKind = iota
+	declarationSymbol
+	parameterSymbol
+	localSymbol
+)
+
+// symbol is a module, declaration, parameter or local
+// declared in the document.
+type symbol struct {
+	name   string
+	kind   symbolKind
+	module string
+
+	// ident is the token naming the symbol.
+	ident *token.Token
+
+	// first and last are indexes of the tokens surrounding the symbol,
+	// for parameters they surround the function they're visible in,
+	// and for locals the part of the block after the declaration.
+	first, last int
+
+	// typ is the type of the local.
+	typ string
+
+	decl     *ast.Declaration
+	param    *ast.FunctionParameter
+	children []*symbol
+}
+
+// document is an opened text document along with its analysis.
+type document struct {
+	uri  string
+	text string
+
+	tokens      []*token.Token
+	nodes       []ast.Node
+	diagnostics diag.Diagnostics
+
+	// symbols holds top-level modules,
+	// all holds every symbol in the order of declaration.
+	symbols []*symbol
+	all     []*symbol
+}
+
+// newDocument scans, parses and indexes text.
+// Errors are kept as diagnostics, so the partial results are still usable.
+func newDocument(uri, text string, debug bool) *document {
+	d := &document{uri: uri, text: text}
+	if strings.TrimSpace(text) == "" {
+		return d
+	}
+	tokens, err := scanner.New(debug, scanner.WithUTF16(true)).Scan(text)
+	d.diagnostics.Add(err)
+	d.tokens = tokens
+	if len(tokens) == 0 {
+		return d
+	}
+	d.nodes, err = parser.New(debug).Parse(tokens)
+	d.diagnostics.Add(err)
+	d.index()
+	return d
+}
+
+// index builds the symbols of the document from its tokens,
+// attaching the parsed declarations to them.
+func (d *document) index() {
+	decls := map[string]map[string]*ast.Declaration{}
+	for _, n := range d.nodes {
+		ast.Inspect(n, func(n ast.Node) bool {
+			md, ok := n.(ast.ModuleDeclaration)
+			if !ok {
+				return false
+			}
+			if decls[md.Name] == nil {
+				decls[md.Name] = map[string]*ast.Declaration{}
+			}
+			for _, child := range md.Body {
+				if decl, ok := child.(*ast.Declaration); ok {
+					decls[md.Name][decl.Name] = decl
+				}
+			}
+			return true
+		})
+	}
+
+	var modules []*symbol
+	for i := 0; i < len(d.tokens); i++ {
+		t := d.tokens[i]
+		switch {
+		case t.Kind == token.String && d.is(i+1, ":") && d.is(i+2, "{"):
+			m := &symbol{name: t.Literal, kind: moduleSymbol, ident: t, first: i, last: d.closing(i + 2)}
+			if len(modules) > 0 {
+				parent := modules[len(modules)-1]
+				m.module = parent.name
+				parent.children = append(parent.children, m)
+			} else {
+				d.symbols = append(d.symbols, m)
+			}
+			d.all = append(d.all, m)
+			modules = append(modules, m)
+			i += 2
+
+		case d.is(i, "}"):
+			if len(modules) > 0 && modules[len(modules)-1].last == i {
+				modules = modules[:len(modules)-1]
+			}
+
+		case t.Kind == token.Identifier && i+1 < len(d.tokens) && d.tokens[i+1].Kind == token.Type && len(modules) > 0:
+			m := modules[len(modules)-1]
+			s := &symbol{name: t.Literal, kind: declarationSymbol, module: m.name, ident: t, first: i, last: i + 2}
+			if decl := decls[m.name][t.Literal]; decl != nil {
+				s.decl = decl
+			}
+			m.children = append(m.children, s)
+			d.all = append(d.all, s)
+			i = d.declaration(s, i+2)
+		}
+	}
+}
+
+// declaration indexes the value of the declaration s starting at the token i,
+// returning the index of its last token.
+func (d *document) declaration(s *symbol, i int) int {
+	if !d.is(i, "(") {
+		// Other values have no symbols inside, skip to the next declaration.
+		for j := i; j < len(d.tokens); j++ {
+			if d.startsDeclaration(j) || d.is(j, "}") {
+				s.last = j - 1
+				return j - 1
+			}
+		}
+		return len(d.tokens) - 1
+	}
+
+	end := d.closing(i)
+	s.last = end
+	if d.is(end+1, "{") {
+		s.last = d.closing(end + 1)
+	}
+
+	var params []ast.FunctionParameter
+	if s.decl != nil {
+		if fn, ok := s.decl.Value.(ast.FunctionValue); ok {
+			params = fn.Parameters
+		}
+	}
+	for j := i + 1; j < end; j++ {
+		t := d.tokens[j]
+		if t.Kind != token.Identifier || d.tokens[j+1].Kind != token.Type {
+			continue
+		}
+		p := &symbol{name: t.Literal, kind: parameterSymbol, module: s.module, ident: t, first: i, last: s.last}
+		for k := range params {
+			if params[k].Identifier == t.Literal {
+				p.param = &params[k]
+			}
+		}
+		s.children = append(s.children, p)
+		d.all = append(d.all, p)
+	}
+	if d.is(end+1, "{") {
+		d.locals(s, end+1)
+	}
+	return s.last
+}
+
+// locals indexes local declarations of the function s,
+// which body starts at the token i.
+func (d *document) locals(s *symbol, i int) {
+	blocks := []int{d.closing(i)}
+	for j := i + 1; j < s.last; j++ {
+		t := d.tokens[j]
+		switch {
+		case d.is(j, "{"):
+			blocks = append(blocks, d.closing(j))
+		case d.is(j, "}"):
+			if len(blocks) > 1 {
+				blocks = blocks[:len(blocks)-1]
+			}
+		case t.Kind == token.Identifier && d.tokens[j+1].Kind == token.Type:
+			l := &symbol{name: t.Literal, kind: localSymbol, module: s.module, ident: t, typ: d.tokens[j+1].Literal, first: j, last: blocks[len(blocks)-1]}
+			s.children = append(s.children, l)
+			d.all = append(d.all, l)
+		}
+	}
+}
+
+// resolve returns the symbol the token at i refers to,
+// or nil if there's none.
+//
+// Identifiers are resolved to locals of the enclosing blocks, innermost first,
+// then to parameters of the enclosing function,
+// then to declarations of the enclosing module,
+// and then to declarations of other modules, preferring exported ones.
+// Strings are resolved to modules with the same name.
+func (d *document) resolve(i int) *symbol {
+	t := d.tokens[i]
+	for _, s := range d.all {
+		if s.ident == t {
+			return s
+		}
+	}
+
+	switch t.Kind {
+	case token.String:
+		for _, s := range d.all {
+			if s.kind == moduleSymbol && s.name == t.Literal {
+				return s
+			}
+		}
+	case token.Identifier:
+		var local *symbol
+		for _, s := range d.all {
+			if s.kind == localSymbol && s.name == t.Literal && s.first <= i && i <= s.last {
+				local = s
+			}
+		}
+		if local != nil {
+			return local
+		}
+		for _, s := range d.all {
+			if s.kind == parameterSymbol && s.name == t.Literal && s.first <= i && i <= s.last {
+				return s
+			}
+		}
+		if m := d.moduleAt(i); m != nil {
+			for _, s := range m.children {
+				if s.kind == declarationSymbol && s.name == t.Literal {
+					return s
+				}
+			}
+		}
+		var found *symbol
+		for _, s := range d.all {
+			if s.kind != declarationSymbol || s.name != t.Literal {
+				continue
+			}
+			if found == nil || s.decl != nil && s.decl.Exported && !s.decl.Declared {
+				found = s
+			}
+		}
+		return found
+	}
+	return nil
+}
+
+// moduleAt returns the innermost module containing the token at i.
+func (d *document) moduleAt(i int) *symbol {
+	var found *symbol
+	for _, s := range d.all {
+		if s.kind == moduleSymbol && s.first <= i && i <= s.last {
+			found = s
+		}
+	}
+	return found
+}
+
+// tokenAt returns the index of the token at pos,
+// or the one ending right before pos, since the cursor is often placed after a word.
+// Returns -1 if there's none.
+func (d *document) tokenAt(pos Position) int {
+	found := -1
+	for i, t := range d.tokens {
+		if t.Kind == token.Eof || t.Kind == token.Comment || t.End == nil {
+			continue
+		}
+		start, end := toPosition(*t.Position), toPosition(*t.End)
+		if start.Line != pos.Line {
+			continue
+		}
+		if start.Character <= pos.Character && pos.Character < end.Character {
+			return i
+		}
+		if pos.Character == end.Character {
+			found = i
+		}
+	}
+	return found
+}
+
+// startsDeclaration reports whether the token at i starts a declaration or module.
+func (d *document) startsDeclaration(i int) bool {
+	t := d.tokens[i]
+	switch t.Kind {
+	case token.Keyword:
+		return t.Literal == "link" || t.Literal == "export" || t.Literal == "declare"
+	case token.Identifier:
+		return i+1 < len(d.tokens) && d.tokens[i+1].Kind == token.Type
+	case token.String:
+		return d.is(i+1, ":")
+	}
+	return false
+}
+
+// closing returns the index of the separator closing the one at i,
+// or the last index if it's not closed.
+func (d *document) closing(i int) int {
+	opener := d.tokens[i].Literal
+	closer := map[string]string{"(": ")", "{": "}", "[": "]"}[opener]
+	depth := 0
+	for j := i; j < len(d.tokens); j++ {
+		switch {
+		case d.is(j, opener):
+			depth++
+		case d.is(j, closer):
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(d.tokens) - 1
+}
+
+// is reports whether the token at i is the separator lit.
+func (d *document) is(i int, lit string) bool {
+	if i < 0 || i >= len(d.tokens) {
+		return false
+	}
+	t := d.tokens[i]
+	return t.Kind == token.Separator && t.Literal == lit
+}
+
+// toPosition converts the one-based token.Position to the zero-based Position.
+func toPosition(p token.Position) Position {
+	if p.Line == 0 {
+		return Position{}
+	}
+	return Position{Line: p.Line - 1, Character: p.Column - 1}
+}
+
+// tokenRange returns the range of t.
+func tokenRange(t *token.Token) Range {
+	r := Range{Start: toPosition(*t.Position), End: toPosition(*t.Position)}
+	if t.End != nil {
+		r.End = toPosition(*t.End)
+	}
+	return r
+}
@@ -0,0 +1,12 @@
+package lsp
+
+import "errors"
+
+var (
+	// ErrHeader is returned if the message header is malformed
+	// or has no Content-Length.
+	ErrHeader = errors.New("malformed message header")
+
+	// ErrUnknownDocument is returned by requests to the documents that weren't opened.
+	ErrUnknownDocument = errors.New("unknown document")
+)
@@ -0,0 +1,247 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// Legend is the legend of semantic tokens returned by the server.
+var Legend = SemanticTokensLegend{
+	TokenTypes: []string{
+		"keyword", "type", "namespace", "variable", "parameter",
+		"function", "number", "string", "operator", "comment",
+	},
+	TokenModifiers: []string{"declaration", "defaultLibrary"},
+}
+
+// Indexes of Legend.TokenTypes.
+const (
+	semanticKeyword = iota
+	semanticType
+	semanticNamespace
+	semanticVariable
+	semanticParameter
+	semanticFunction
+	semanticNumber
+	semanticString
+	semanticOperator
+	semanticComment
+)
+
+// Bits of Legend.TokenModifiers.
+const (
+	modifierDeclaration = 1 << iota
+	modifierDefaultLibrary
+)
+
+// lspDiagnostics converts the diagnostics of the document.
+func (d *document) lspDiagnostics() []Diagnostic {
+	result := []Diagnostic{}
+	for _, dg := range d.diagnostics {
+		severity := SeverityError
+		switch dg.Severity {
+		case diag.Warning:
+			severity = SeverityWarning
+		case diag.Note:
+			severity = SeverityInformation
+		}
+		message := dg.Message
+		for _, note := range dg.Notes {
+			message += "\nnote: " + note
+		}
+		result = append(result, Diagnostic{
+			Range:    Range{Start: toPosition(dg.Start), End: toPosition(dg.End)},
+			Severity: severity,
+			Code:     string(dg.Code),
+			Source:   "dywoq",
+			Message:  message,
+		})
+	}
+	return result
+}
+
+// hover returns the signature and documentation of the symbol at pos,
+// or nil if there's none.
+func (d *document) hover(pos Position) *Hover {
+	i := d.tokenAt(pos)
+	if i < 0 {
+		return nil
+	}
+	s := d.resolve(i)
+	if s == nil {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("```dywoqlang\n")
+	b.WriteString(signature(s))
+	b.WriteString("\n```")
+	if s.decl != nil && s.decl.Documentation != "" {
+		b.WriteString("\n\n")
+		b.WriteString(s.decl.Documentation)
+	}
+	r := tokenRange(d.tokens[i])
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: b.String()}, Range: &r}
+}
+
+// signature returns the source-like signature of s.
+func signature(s *symbol) string {
+	switch s.kind {
+	case moduleSymbol:
+		return fmt.Sprintf("%q: {}", s.name)
+	case parameterSymbol:
+		if s.param != nil && !s.param.CopyAllowed {
+			return fmt.Sprintf("%s %s copy(false)", s.name, s.param.Kind)
+		}
+		if s.param != nil {
+			return fmt.Sprintf("%s %s", s.name, s.param.Kind)
+		}
+		return s.name
+	case localSymbol:
+		return fmt.Sprintf("%s %s", s.name, s.typ)
+	}
+	if s.decl == nil {
+		return s.name
+	}
+
+	var b strings.Builder
+	if s.decl.Linked {
+		fmt.Fprintf(&b, "link(%q) ", s.decl.LinkedFrom)
+	} else if !s.decl.CanBeLinked {
+		b.WriteString("link(false) ")
+	}
+	if s.decl.Exported {
+		b.WriteString("export ")
+	}
+	if s.decl.Declared {
+		b.WriteString("declare ")
+	}
+	fmt.Fprintf(&b, "%s %s", s.decl.Name, s.decl.Kind)
+	if fn, ok := s.decl.Value.(ast.FunctionValue); ok {
+		params := make([]string, len(fn.Parameters))
+		for i, p := range fn.Parameters {
+			params[i] = p.Identifier + " " + p.Kind
+			if !p.CopyAllowed {
+				params[i] += " copy(false)"
+			}
+		}
+		fmt.Fprintf(&b, " (%s)", strings.Join(params, ", "))
+	}
+	return fmt.Sprintf("%s # module %q", b.String(), s.module)
+}
+
+// definition returns the location of the symbol the token at pos refers to,
+// or nil if there's none.
+func (d *document) definition(pos Position) *Location {
+	i := d.tokenAt(pos)
+	if i < 0 {
+		return nil
+	}
+	s := d.resolve(i)
+	if s == nil {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: tokenRange(s.ident)}
+}
+
+// documentSymbols returns the modules of the document
+// with their declarations as children.
+func (d *document) documentSymbols() []DocumentSymbol {
+	var convert func(symbols []*symbol) []DocumentSymbol
+	convert = func(symbols []*symbol) []DocumentSymbol {
+		result := []DocumentSymbol{}
+		for _, s := range symbols {
+			if s.kind == parameterSymbol || s.kind == localSymbol {
+				continue
+			}
+			ds := DocumentSymbol{
+				Name:           s.name,
+				Kind:           SymbolModule,
+				Range:          Range{Start: toPosition(*d.tokens[s.first].Position), End: tokenRange(d.tokens[s.last]).End},
+				SelectionRange: tokenRange(s.ident),
+				Children:       convert(s.children),
+			}
+			if s.kind == declarationSymbol {
+				ds.Kind = SymbolVariable
+				if s.decl != nil {
+					ds.Detail = s.decl.Kind
+					if _, ok := s.decl.Value.(ast.FunctionValue); ok {
+						ds.Kind = SymbolFunction
+					}
+				}
+			}
+			result = append(result, ds)
+		}
+		return result
+	}
+	return convert(d.symbols)
+}
+
+// semanticTokens returns the semantic tokens of the document,
+// derived from the token kinds and resolved symbols.
+func (d *document) semanticTokens() SemanticTokens {
+	data := []int{}
+	var prev Position
+	for i, t := range d.tokens {
+		typ, modifiers, ok := d.semanticType(i)
+		if !ok || t.End == nil || t.End.Line != t.Position.Line {
+			continue
+		}
+		r := tokenRange(t)
+		deltaStart := r.Start.Character
+		if r.Start.Line == prev.Line {
+			deltaStart -= prev.Character
+		}
+		data = append(data, r.Start.Line-prev.Line, deltaStart, r.End.Character-r.Start.Character, typ, modifiers)
+		prev = r.Start
+	}
+	return SemanticTokens{Data: data}
+}
+
+// semanticType returns the semantic token type and modifiers of the token at i,
+// or false if the token isn't highlighted.
+func (d *document) semanticType(i int) (int, int, bool) {
+	t := d.tokens[i]
+	switch t.Kind {
+	case token.Keyword, token.BoolConstant, token.Special:
+		return semanticKeyword, 0, true
+	case token.Type:
+		return semanticType, 0, true
+	case token.Integer, token.Float:
+		return semanticNumber, 0, true
+	case token.BinaryOperator:
+		return semanticOperator, 0, true
+	case token.Comment:
+		return semanticComment, 0, true
+	case token.BaseInstruction:
+		return semanticFunction, modifierDefaultLibrary, true
+	case token.String:
+		if d.is(i+1, ":") {
+			return semanticNamespace, modifierDeclaration, true
+		}
+		return semanticString, 0, true
+	case token.Identifier:
+		s := d.resolve(i)
+		if s == nil {
+			return semanticVariable, 0, true
+		}
+		modifiers := 0
+		if s.ident == t {
+			modifiers = modifierDeclaration
+		}
+		switch {
+		case s.kind == parameterSymbol:
+			return semanticParameter, modifiers, true
+		case s.decl != nil:
+			if _, ok := s.decl.Value.(ast.FunctionValue); ok {
+				return semanticFunction, modifiers, true
+			}
+		}
+		return semanticVariable, modifiers, true
+	}
+	return 0, 0, false
+}
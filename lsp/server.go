@@ -0,0 +1,232 @@
+// Package lsp implements the Language Server Protocol server for dywoqlang.
+//
+// The server communicates over JSON-RPC framed by the Content-Length header,
+// usually over the standard input and output, and supports:
+//
+//   - publishing scanner and parser diagnostics when documents are opened or changed;
+//   - hover showing signatures and documentation of declarations;
+//   - going to the definition of identifiers and user instruction calls;
+//   - document symbols for modules and declarations;
+//   - semantic tokens derived from token kinds.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// Server is a Language Server Protocol server.
+type Server struct {
+	conn      *conn
+	documents map[string]*document
+
+	initialized bool
+	shutdown    bool
+
+	debug bool
+}
+
+// New returns a new pointer to Server.
+func New(debug bool) *Server {
+	return &Server{documents: map[string]*document{}, debug: debug}
+}
+
+// Serve reads requests from r and writes responses to w
+// until the client sends the exit notification or closes r.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+	for {
+		content, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(content, &msg); err != nil {
+			if err := s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		s.outputf("received %s\n", msg.Method)
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, rerr := s.handle(&msg)
+		if msg.ID == nil {
+			if rerr != nil {
+				s.outputf("notification %s failed: %s\n", msg.Method, rerr.Message)
+			}
+			continue
+		}
+		if err := s.reply(msg.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+// handle handles msg, returning the result of the request.
+func (s *Server) handle(msg *message) (any, *responseError) {
+	switch {
+	case msg.Method == "initialize":
+		s.initialized = true
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:       textDocumentSyncOptions{OpenClose: true, Change: 1},
+				HoverProvider:          true,
+				DefinitionProvider:     true,
+				DocumentSymbolProvider: true,
+				SemanticTokensProvider: semanticTokensOptions{Legend: Legend, Full: true},
+			},
+			ServerInfo: serverInfo{Name: "dywoq"},
+		}, nil
+	case !s.initialized:
+		return nil, &responseError{Code: codeServerNotReady, Message: "server is not initialized"}
+	case s.shutdown:
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+
+	switch msg.Method {
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// The documents are synced fully, so the last change has the whole text.
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.update(params.TextDocument.URI, text)
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.publish(params.TextDocument.URI, []Diagnostic{})
+
+	case "textDocument/hover":
+		d, pos, rerr := s.position(msg.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if h := d.hover(pos); h != nil {
+			return h, nil
+		}
+		return nil, nil
+
+	case "textDocument/definition":
+		d, pos, rerr := s.position(msg.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if l := d.definition(pos); l != nil {
+			return l, nil
+		}
+		return nil, nil
+
+	case "textDocument/documentSymbol":
+		d, rerr := s.document(msg.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return d.documentSymbols(), nil
+
+	case "textDocument/semanticTokens/full":
+		d, rerr := s.document(msg.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return d.semanticTokens(), nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q is not supported", msg.Method)}
+}
+
+// update analyzes the new text of the document and publishes its diagnostics.
+func (s *Server) update(uri, text string) *responseError {
+	d := newDocument(uri, text, s.debug)
+	s.documents[uri] = d
+	return s.publish(uri, d.lspDiagnostics())
+}
+
+func (s *Server) publish(uri string, diagnostics []Diagnostic) *responseError {
+	params, err := json.Marshal(publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+	if err != nil {
+		return &responseError{Code: codeInvalidRequest, Message: err.Error()}
+	}
+	err = s.conn.write(message{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params})
+	if err != nil {
+		return &responseError{Code: codeInvalidRequest, Message: err.Error()}
+	}
+	return nil
+}
+
+// document returns the document requested by params.
+func (s *Server) document(raw json.RawMessage) (*document, *responseError) {
+	var params documentParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err)
+	}
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, invalidParams(fmt.Errorf("%w %s", ErrUnknownDocument, params.TextDocument.URI))
+	}
+	return d, nil
+}
+
+// position returns the document and the position requested by params.
+func (s *Server) position(raw json.RawMessage) (*document, Position, *responseError) {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, Position{}, invalidParams(err)
+	}
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, Position{}, invalidParams(fmt.Errorf("%w %s", ErrUnknownDocument, params.TextDocument.URI))
+	}
+	return d, params.Position, nil
+}
+
+func (s *Server) reply(id *json.RawMessage, result any, rerr *responseError) error {
+	resp := response{JSONRPC: "2.0", ID: id, Error: rerr}
+	if rerr == nil {
+		bytes, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resp.Result = bytes
+	}
+	return s.conn.write(resp)
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+func (s *Server) outputf(format string, v ...any) {
+	if s.debug {
+		log.Printf(format, v...)
+	}
+}
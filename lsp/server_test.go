@@ -0,0 +1,184 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/lsp"
+)
+
+const uri = "file:///main.dl"
+
+// message is a request, notification or response exchanged with the server.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int            `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *struct {
+		Code int `json:"code"`
+	} `json:"error,omitempty"`
+}
+
+func request(id int, method string, params any) message {
+	return message{JSONRPC: "2.0", ID: &id, Method: method, Params: params}
+}
+
+func notification(method string, params any) message {
+	return message{JSONRPC: "2.0", Method: method, Params: params}
+}
+
+// serve sends messages to a new server after the initialize request,
+// returning everything the server wrote back except the initialize response.
+func serve(t *testing.T, messages ...message) []message {
+	t.Helper()
+	var in bytes.Buffer
+	for _, msg := range append([]message{request(0, "initialize", struct{}{})}, messages...) {
+		content, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	}
+	var out bytes.Buffer
+	if err := lsp.New(false).Serve(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var result []message
+	r := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := make([]byte, length)
+		if _, err := io.ReadFull(r.R, content); err != nil {
+			t.Fatal(err)
+		}
+		var msg message
+		if err := json.Unmarshal(content, &msg); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, msg)
+	}
+	if len(result) == 0 || result[0].ID == nil || *result[0].ID != 0 {
+		t.Fatalf("got %+v, want the initialize response first", result)
+	}
+	return result[1:]
+}
+
+func open(text string) message {
+	return notification("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "dywoqlang", "version": 1, "text": text},
+	})
+}
+
+func position(id int, method string, line, character int) message {
+	return request(id, method, map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	})
+}
+
+func TestPublishDiagnostics(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		codes []string
+	}{
+		{"valid", `"main": { x i32 1 }`, nil},
+		{"empty", "", nil},
+		{"illegal character", `"main": { x i32 1 $ }`, []string{"E0001"}},
+		{"missing value", `"main": { x i32 }`, []string{"E0100"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := serve(t, open(tt.text))
+			if len(messages) != 1 || messages[0].Method != "textDocument/publishDiagnostics" {
+				t.Fatalf("got %+v, want one publishDiagnostics notification", messages)
+			}
+			params, err := json.Marshal(messages[0].Params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var published struct {
+				URI         string           `json:"uri"`
+				Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+			}
+			if err := json.Unmarshal(params, &published); err != nil {
+				t.Fatal(err)
+			}
+			if published.URI != uri {
+				t.Errorf("published for %s, want %s", published.URI, uri)
+			}
+			var codes []string
+			for _, d := range published.Diagnostics {
+				codes = append(codes, d.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("got codes %v, want %v", codes, tt.codes)
+			}
+		})
+	}
+}
+
+func TestRequests(t *testing.T) {
+	text := "\"main\": {\n\t# Answer.\n\tx i32 42\n\tmain void () {\n\t\tstdout x;\n\t}\n}\n"
+	messages := serve(t,
+		open(text),
+		position(1, "textDocument/hover", 4, 9),
+		position(2, "textDocument/definition", 4, 9),
+		request(3, "textDocument/unknown", struct{}{}),
+	)
+	if len(messages) != 4 {
+		t.Fatalf("got %d messages, want 4", len(messages))
+	}
+
+	var hover lsp.Hover
+	if err := json.Unmarshal(messages[1].Result, &hover); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(hover.Contents.Value, "x i32") || !strings.Contains(hover.Contents.Value, "Answer.") {
+		t.Errorf("got hover %q, want the signature and documentation of x", hover.Contents.Value)
+	}
+
+	var location lsp.Location
+	if err := json.Unmarshal(messages[2].Result, &location); err != nil {
+		t.Fatal(err)
+	}
+	if want := (lsp.Position{Line: 2, Character: 1}); location.Range.Start != want {
+		t.Errorf("got definition at %+v, want %+v", location.Range.Start, want)
+	}
+
+	if messages[3].Error == nil {
+		t.Error("unknown method succeeded")
+	}
+}
+
+func TestNotInitialized(t *testing.T) {
+	var in, out bytes.Buffer
+	content := `{"jsonrpc":"2.0","id":1,"method":"textDocument/hover","params":{}}`
+	fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	if err := lsp.New(false).Serve(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"error"`) {
+		t.Errorf("got %s, want an error response", out.String())
+	}
+}
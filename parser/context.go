@@ -15,6 +15,12 @@ type Reader interface {
 	// Returns a error if the parser reached End Of File (EOF) token,
 	// or the current position+1 will make the position out of bounds.
 	Peek() (*token.Token, error)
+
+	// Previous returns the token before the current one,
+	// which is usually the last token of the parsed node.
+	//
+	// Returns an error if the parser is at the first token.
+	Previous() (*token.Token, error)
 }
 
 type Tracker interface {
@@ -36,7 +42,7 @@ type Advancer interface {
 }
 
 type ErrorCreator interface {
-	// Error returns a new error.
+	// Error returns a new error, which is *diag.Diagnostic.
 	// The difference from errors.New is that Error automatically inserts the position where the error occurred.
 	Error(v ...any) error
 
@@ -53,6 +59,12 @@ type Expecter interface {
 	ExpectLiterals(lits ...string) (*token.Token, error)
 }
 
+type Reporter interface {
+	// Report records err and lets the parser continue,
+	// so all errors are returned at once after parsing.
+	Report(err error)
+}
+
 type ModuleManager interface {
 	// SetModule sets the current processing module name.
 	SetModule(name string)
@@ -61,6 +73,12 @@ type ModuleManager interface {
 	Module() string
 }
 
+type Registry interface {
+	// Lookup returns the mini function registered for the form starting with t,
+	// preferring the one registered for its literal over the one for its kind.
+	Lookup(form Form, t *token.Token) (MiniFunc, bool)
+}
+
 type Context interface {
 	Reader
 	Tracker
@@ -68,5 +86,7 @@ type Context interface {
 	Advancer
 	ErrorCreator
 	Expecter
+	Reporter
 	ModuleManager
+	Registry
 }
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 
@@ -30,13 +31,19 @@ func ParseDeclaration(c Context) (ast.Node, error) {
 		canBeLinked                bool = true
 		linkedFrom                 string
 	)
+	start, err := c.Current()
+	if err != nil {
+		return nil, err
+	}
 loop:
 	for !c.Eof() {
 		t, _ := c.Current()
 		switch t.Literal {
 		case "link":
 			_, _ = c.ExpectLiteral("link")
-			_, _ = c.ExpectLiteral("(")
+			if _, err := c.ExpectLiteral("("); err != nil {
+				return nil, err
+			}
 			v, err := c.ExpectMultiple(token.String, token.BoolConstant)
 			if err != nil {
 				return nil, err
@@ -51,7 +58,9 @@ loop:
 				linked = true
 			}
 
-			_, _ = c.ExpectLiteral(")")
+			if _, err := c.ExpectLiteral(")"); err != nil {
+				return nil, err
+			}
 
 		case "export":
 			if !canBeLinked {
@@ -69,14 +78,21 @@ loop:
 		}
 	}
 
-	identifier, _ := c.Expect(token.Identifier)
-	tType, _ := c.Expect(token.Type)
+	identifier, err := c.Expect(token.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	tType, err := c.Expect(token.Type)
+	if err != nil {
+		return nil, err
+	}
 	value, err := ParseValue(c, declared, linked)
 	if err != nil {
 		return nil, err
 	}
 
 	return &ast.Declaration{
+		Span:        span(c, start),
 		Name:        identifier.Literal,
 		Kind:        tType.Literal,
 		Exported:    exported,
@@ -97,28 +113,143 @@ loop:
 //   - consteval expression: `consteval(<expr>)`
 //   - meta expression: `meta(<literal, strings>)`. Function values and identifiers are not allowed.
 //   - arrays: `i32{2, 3, 4}[]` or `i32{2, 3, 4}[10]`
+//   - function calls: `[name](10, x)`
+//   - binary expressions of values: `x * 2 + 1`, `(x + 1) / 2`
+//
+// Binary expressions are parsed by precedence climbing:
+// '*' and '/' bind tighter than '+' and '-', which bind tighter than comparisons,
+// and operators of the same precedence are left-associative. Parentheses that don't start a parameter list group the expression.
 //
-// Returns an *ast.Value or *ast.FunctionValue node.
+// Unary minus before number literals negates the literal,
+// and before other values it's parsed as subtraction from zero.
+//
+// Returns an *ast.Value, *ast.FunctionValue, *ast.ArrayValue,
+// *ast.CallExpression or *ast.BinaryExpression node.
 //
 // If the value is consteval, Consteval=true and
 // the evaluated expression is stored in ValueNode.
+//
+// Operands starting with the token registered by WithValue are parsed
+// by the registered mini function instead.
 func ParseValue(c Context, declared, linked bool) (ast.Node, error) {
+	return parseBinary(c, declared, linked, 1)
+}
+
+// precedences holds precedences of binary operators,
+// the higher one binds tighter.
+var precedences = map[string]int{
+	"==": 1,
+	"!=": 1,
+	"<":  1,
+	">":  1,
+	"<=": 1,
+	">=": 1,
+	"+":  2,
+	"-":  2,
+	"*":  3,
+	"/":  3,
+}
+
+// parseBinary parses the binary expression
+// which operators have at least the precedence min.
+func parseBinary(c Context, declared, linked bool, min int) (ast.Node, error) {
+	left, err := parseUnary(c, declared, linked)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, err := c.Current()
+		if err != nil || t.Kind != token.BinaryOperator {
+			return left, nil
+		}
+		precedence, ok := precedences[t.Literal]
+		if !ok || precedence < min {
+			return left, nil
+		}
+		_ = c.Advance(1)
+
+		right, err := parseBinary(c, false, false, precedence+1)
+		if err != nil {
+			return nil, err
+		}
+		left = ast.BinaryExpression{
+			Span:     ast.Span{StartPos: left.Pos(), EndPos: right.End()},
+			Operator: t.Literal,
+			Children: []ast.Node{left, right},
+		}
+	}
+}
+
+// parseUnary parses the value with the optional unary minus.
+func parseUnary(c Context, declared, linked bool) (ast.Node, error) {
 	t, err := c.Current()
 	if err != nil {
 		return nil, err
 	}
+	if t.Kind != token.BinaryOperator || t.Literal != "-" {
+		return parsePrimary(c, declared, linked)
+	}
+	_ = c.Advance(1)
+
+	operand, err := parseUnary(c, false, false)
+	if err != nil {
+		return nil, err
+	}
+	sp := ast.Span{StartPos: *t.Position, EndPos: operand.End()}
+	if v, ok := operand.(ast.Value); ok && v.ValueNode == nil && (v.Kind == token.Integer || v.Kind == token.Float) {
+		if negative, ok := strings.CutPrefix(v.Value, "-"); ok {
+			v.Value = negative
+		} else {
+			v.Value = "-" + v.Value
+		}
+		v.Span = sp
+		return v, nil
+	}
+	zero := ast.Value{Span: ast.Span{StartPos: *t.Position, EndPos: *t.End}, Kind: token.Integer, Value: "0"}
+	return ast.BinaryExpression{
+		Span:     sp,
+		Operator: "-",
+		Children: []ast.Node{zero, operand},
+	}, nil
+}
+
+// parsePrimary parses the value which isn't a binary expression.
+func parsePrimary(c Context, declared, linked bool) (ast.Node, error) {
+	t, err := c.Current()
+	if err != nil {
+		return nil, err
+	}
+	if fn, ok := c.Lookup(FormValue, t); ok {
+		return fn(c)
+	}
 
 	switch {
 	case t.Kind == token.Integer, t.Kind == token.Float, t.Kind == token.String:
 		_, _ = c.Expect(t.Kind)
-		return ast.Value{Value: t.Literal, Kind: t.Kind}, nil
+		value := t.Literal
+		if t.Value != "" {
+			// numbers are stored normalized, so 0x1F and 31 are the same value.
+			value = t.Value
+		}
+		return ast.Value{Span: span(c, t), Value: value, Kind: t.Kind}, nil
 
 	case t.Kind == token.Identifier:
 		_, _ = c.Expect(token.Identifier)
-		return ast.Value{Value: t.Literal, Kind: t.Kind}, nil
+		return ast.Value{Span: span(c, t), Value: t.Literal, Kind: t.Kind}, nil
 
-	case t.Literal == "(":
+	case isSeparator(t, "("):
 		_, _ = c.ExpectLiteral("(")
+		if !startsParameters(c) {
+			expr, err := parseBinary(c, false, false, 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := c.ExpectLiteral(")"); err != nil {
+				return nil, err
+			}
+			return expr, nil
+		}
+
 		params := []ast.FunctionParameter{}
 		for !c.Eof() {
 			next, _ := c.Current()
@@ -145,13 +276,21 @@ func ParseValue(c Context, declared, linked bool) (ast.Node, error) {
 
 			if next.Literal == "copy" {
 				_, _ = c.ExpectLiteral("copy")
-				_, _ = c.ExpectLiteral("(")
-				val, _ := c.Expect(token.BoolConstant)
+				if _, err := c.ExpectLiteral("("); err != nil {
+					return nil, err
+				}
+				val, err := c.Expect(token.BoolConstant)
+				if err != nil {
+					return nil, err
+				}
 				copyAllowed, _ = strconv.ParseBool(val.Literal)
-				_, _ = c.ExpectLiteral(")")
+				if _, err := c.ExpectLiteral(")"); err != nil {
+					return nil, err
+				}
 			}
 
 			params = append(params, ast.FunctionParameter{
+				Span:        span(c, ident),
 				Identifier:  ident.Literal,
 				Kind:        typ.Literal,
 				CopyAllowed: copyAllowed,
@@ -162,7 +301,9 @@ func ParseValue(c Context, declared, linked bool) (ast.Node, error) {
 				_, _ = c.ExpectLiteral(",")
 			}
 		}
-		_, _ = c.ExpectLiteral(")")
+		if _, err := c.ExpectLiteral(")"); err != nil {
+			return nil, err
+		}
 		next, err := c.Current()
 		if err == nil && next.Literal == "{" {
 			if declared || linked {
@@ -173,29 +314,36 @@ func ParseValue(c Context, declared, linked bool) (ast.Node, error) {
 				return nil, err
 			}
 			return ast.FunctionValue{
+				Span:       span(c, t),
 				Parameters: params,
 				Body:       body,
 			}, nil
 		}
 
-		if next.Literal != "{" && (!declared || !linked) {
+		if next.Literal != "{" && !declared && !linked {
 			return nil, c.Errorf("non-declared or non-linked functions must have a body")
 		}
 
 		return ast.FunctionValue{
+			Span:       span(c, t),
 			Parameters: params,
 			Body:       nil,
 		}, nil
 
 	case t.Literal == "consteval":
 		_, _ = c.ExpectLiteral("consteval")
-		_, _ = c.ExpectLiteral("(")
+		if _, err := c.ExpectLiteral("("); err != nil {
+			return nil, err
+		}
 		expr, err := ParseValue(c, declared, linked)
 		if err != nil {
 			return nil, err
 		}
-		_, _ = c.ExpectLiteral(")")
+		if _, err := c.ExpectLiteral(")"); err != nil {
+			return nil, err
+		}
 		return ast.Value{
+			Span:      span(c, t),
 			Kind:      t.Kind,
 			ValueNode: expr,
 			Consteval: true,
@@ -203,13 +351,18 @@ func ParseValue(c Context, declared, linked bool) (ast.Node, error) {
 
 	case t.Literal == "copy":
 		_, _ = c.ExpectLiteral("copy")
-		_, _ = c.ExpectLiteral("(")
+		if _, err := c.ExpectLiteral("("); err != nil {
+			return nil, err
+		}
 		expr, err := ParseValue(c, false, false)
 		if err != nil {
 			return nil, err
 		}
-		_, _ = c.ExpectLiteral(")")
+		if _, err := c.ExpectLiteral(")"); err != nil {
+			return nil, err
+		}
 		return ast.Value{
+			Span:      span(c, t),
 			Kind:      t.Kind,
 			ValueNode: expr,
 			Consteval: false,
@@ -218,28 +371,72 @@ func ParseValue(c Context, declared, linked bool) (ast.Node, error) {
 
 	case t.Literal == "array":
 		_, _ = c.ExpectLiteral("array")
-		_, _ = c.ExpectLiteral("(")
+		if _, err := c.ExpectLiteral("("); err != nil {
+			return nil, err
+		}
 		elements := []ast.ArrayElement{}
 		for {
 			if c.Eof() {
-				return nil, c.Errorf("")
+				return nil, c.Errorf("array must be closed")
+			}
+			if next, _ := c.Current(); isSeparator(next, ")") {
+				break
 			}
 			n, err := ParseValue(c, false, false)
 			if err != nil {
 				return nil, err
 			}
-			elements = append(elements, ast.ArrayElement{Value: n})
-			c.Advance(1)
+			elements = append(elements, ast.ArrayElement{Span: ast.Span{StartPos: n.Pos(), EndPos: n.End()}, Value: n})
 
-			if t, _ = c.Current(); t.Literal == "," {
+			next, err := c.Current()
+			if err != nil {
+				return nil, err
+			}
+			if isSeparator(next, ",") {
+				_ = c.Advance(1)
 				continue
 			}
-			if t, _ = c.Current(); t.Literal == ")" {
+			if !isSeparator(next, ")") {
+				return nil, c.Errorf("expected ',' or ')' after array element, got %v", next.Literal)
+			}
+		}
+		if _, err := c.ExpectLiteral(")"); err != nil {
+			return nil, err
+		}
+		return ast.ArrayValue{Span: span(c, t), MaxSize: len(elements), Elements: elements}, nil
+
+	case t.Literal == "[":
+		_, _ = c.ExpectLiteral("[")
+		ident, err := c.Expect(token.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := c.ExpectLiteral("]"); err != nil {
+			return nil, err
+		}
+		if _, err := c.ExpectLiteral("("); err != nil {
+			return nil, err
+		}
+		args := []ast.Node{}
+		for !c.Eof() {
+			next, _ := c.Current()
+			if next.Literal == ")" {
 				break
 			}
+			arg, err := ParseValue(c, false, false)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			next, _ = c.Current()
+			if next.Literal == "," {
+				_, _ = c.ExpectLiteral(",")
+			}
+		}
+		if _, err := c.ExpectLiteral(")"); err != nil {
+			return nil, err
 		}
-		_, _ = c.ExpectLiteral(")")
-		return ast.ArrayValue{MaxSize: len(elements), Elements: elements}, nil
+		return ast.CallExpression{Span: span(c, t), Name: ident.Literal, Arguments: args}, nil
 	}
 
 	return nil, c.Errorf("unknown value type: %v", t.Literal)
@@ -250,6 +447,12 @@ func ParseValue(c Context, declared, linked bool) (ast.Node, error) {
 // Instruction calls can be base or user-defined:
 //   - Base: `mov x, 10;`
 //   - User: `[ret] 10, 20;`
+//   - User with the call syntax: `[ret](10, 20);`
+//
+// The call syntax is only used if the parenthesis follows `]` without spaces,
+// so `[f] (x + 1), 2;` is a user instruction call with a parenthesized argument.
+// The call syntax takes the whole statement,
+// so `[f](1) + 2;` is an error rather than `[f] (1) + 2;`.
 //
 // Each argument inside the instruction is parsed via ParseValue.
 // Returns an *ast.InstructionCall node containing arguments.
@@ -270,10 +473,28 @@ func ParseInstructionCall(c Context) (ast.Node, error) {
 	switch t.Kind {
 	case token.Separator:
 		_, _ = c.ExpectLiteral("[")
-		ident, _ := c.Expect(token.Identifier)
-		_, _ = c.ExpectLiteral("]")
+		ident, err := c.Expect(token.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		closing, err := c.ExpectLiteral("]")
+		if err != nil {
+			return nil, err
+		}
 		isUser = true
 		name = ident.Literal
+		if next, err := c.Current(); err == nil && isSeparator(next, "(") && adjacent(closing, next) {
+			args, err := parseCallArguments(c, name)
+			if err != nil {
+				return nil, err
+			}
+			return ast.InstructionCall{
+				Span:      span(c, t),
+				Name:      name,
+				IsUser:    true,
+				Arguments: args,
+			}, nil
+		}
 	case token.BaseInstruction:
 		ident, _ := c.Expect(token.BaseInstruction)
 		name = ident.Literal
@@ -299,32 +520,285 @@ func ParseInstructionCall(c Context) (ast.Node, error) {
 			return nil, err
 		}
 		args = append(args, ast.InstructionCallArgument{
+			Span:  ast.Span{StartPos: val.Pos(), EndPos: val.End()},
 			Value: val,
 			Kind:  nextToken.Kind,
 		})
 	}
 
 	return ast.InstructionCall{
+		Span:      span(c, t),
 		Name:      name,
 		IsUser:    isUser,
 		Arguments: args,
 	}, nil
 }
 
+// parseCallArguments parses the arguments of the user instruction name
+// written with the call syntax, `(x, y);`.
+func parseCallArguments(c Context, name string) ([]ast.InstructionCallArgument, error) {
+	_, _ = c.ExpectLiteral("(")
+	args := []ast.InstructionCallArgument{}
+	for !c.Eof() {
+		next, _ := c.Current()
+		if isSeparator(next, ")") {
+			break
+		}
+		val, err := ParseValue(c, false, false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, ast.InstructionCallArgument{
+			Span:  ast.Span{StartPos: val.Pos(), EndPos: val.End()},
+			Value: val,
+			Kind:  next.Kind,
+		})
+		next, err = c.Current()
+		if err != nil {
+			return nil, err
+		}
+		if isSeparator(next, ",") {
+			_ = c.Advance(1)
+			continue
+		}
+		if !isSeparator(next, ")") {
+			return nil, c.Errorf("expected ',' or ')' after argument of [%s], got %v", name, next.Literal)
+		}
+	}
+	if _, err := c.ExpectLiteral(")"); err != nil {
+		return nil, err
+	}
+	if next, err := c.Current(); err == nil && next.Kind == token.BinaryOperator {
+		return nil, c.Errorf("the result of [%s](...) can't be used in a statement", name)
+	}
+	if _, err := c.ExpectLiteral(";"); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// ParseLabel parses a label declaration inside the function body, such as `start:`.
+//
+// Returns an ast.Label node.
+func ParseLabel(c Context) (ast.Node, error) {
+	ident, err := c.Expect(token.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.ExpectLiteral(":"); err != nil {
+		return nil, err
+	}
+	return ast.Label{
+		Span: span(c, ident),
+		Name: ident.Literal,
+	}, nil
+}
+
+// ParseJump parses a jump instruction, which takes the label name instead of values:
+//   - Unconditional: `jmp start;`
+//   - Conditional, after cmp: `jl start;`
+//
+// Returns an ast.Jump node.
+func ParseJump(c Context) (ast.Node, error) {
+	instruction, err := c.Expect(token.BaseInstruction)
+	if err != nil {
+		return nil, err
+	}
+	label, err := c.Expect(token.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.ExpectLiteral(";"); err != nil {
+		return nil, err
+	}
+	return ast.Jump{
+		Span:  span(c, instruction),
+		Name:  instruction.Literal,
+		Label: label.Literal,
+	}, nil
+}
+
+// ParseLocalDeclaration parses a local variable declaration inside the body,
+// which has the shape of ParseDeclaration without modifiers, followed by ';':
+//
+//	<identifier> <type> <value>;
+//
+// The local is visible from the next statement until the end of the enclosing block.
+//
+// Returns an ast.LocalDeclaration node.
+func ParseLocalDeclaration(c Context) (ast.Node, error) {
+	identifier, err := c.Expect(token.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	tType, err := c.Expect(token.Type)
+	if err != nil {
+		return nil, err
+	}
+	value, err := ParseValue(c, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.(ast.FunctionValue); ok {
+		return nil, c.Errorf("local %s can't be a function", identifier.Literal)
+	}
+	if _, err := c.ExpectLiteral(";"); err != nil {
+		return nil, err
+	}
+	return ast.LocalDeclaration{
+		Span:  span(c, identifier),
+		Name:  identifier.Literal,
+		Kind:  tType.Literal,
+		Value: value,
+	}, nil
+}
+
+// ParseIf parses the if block with the optional else branch:
+//
+//	if x < 10 {
+//	    # ...
+//	} else if x < 20 {
+//	    # ...
+//	} else {
+//	    # ...
+//	}
+//
+// The condition is parsed via ParseValue.
+// The `else if` branch is stored as the only statement of Else.
+//
+// Returns an ast.IfStatement node.
+func ParseIf(c Context) (ast.Node, error) {
+	start, err := c.ExpectLiteral("if")
+	if err != nil {
+		return nil, err
+	}
+	condition, err := ParseValue(c, false, false)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ParseBody(c)
+	if err != nil {
+		return nil, err
+	}
+	stmt := ast.IfStatement{Condition: condition, Body: body}
+
+	if t, err := c.Current(); err == nil && t.Kind == token.Keyword && t.Literal == "else" {
+		_ = c.Advance(1)
+		next, err := c.Current()
+		if err != nil {
+			return nil, err
+		}
+		if next.Kind == token.Keyword && next.Literal == "if" {
+			nested, err := ParseIf(c)
+			if err != nil {
+				return nil, err
+			}
+			stmt.Else = []ast.Node{nested}
+		} else {
+			stmt.Else, err = ParseBody(c)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	stmt.Span = span(c, start)
+	return stmt, nil
+}
+
+// ParseLoop parses the infinite loop or the loop with the condition,
+// checked before each iteration:
+//   - `loop { ... }`
+//   - `while x < 10 { ... }`
+//
+// Returns an ast.LoopStatement node, which Condition is nil for `loop`.
+func ParseLoop(c Context) (ast.Node, error) {
+	start, err := c.Expect(token.Keyword)
+	if err != nil {
+		return nil, err
+	}
+	var condition ast.Node
+	if start.Literal == "while" {
+		condition, err = ParseValue(c, false, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	body, err := ParseBody(c)
+	if err != nil {
+		return nil, err
+	}
+	return ast.LoopStatement{
+		Span:      span(c, start),
+		Condition: condition,
+		Body:      body,
+	}, nil
+}
+
+// ParseBranch parses `break;` or `continue;`.
+//
+// Returns an ast.BreakStatement or ast.ContinueStatement node.
+func ParseBranch(c Context) (ast.Node, error) {
+	start, err := c.Expect(token.Keyword)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.ExpectLiteral(";"); err != nil {
+		return nil, err
+	}
+	if start.Literal == "continue" {
+		return ast.ContinueStatement{Span: span(c, start)}, nil
+	}
+	return ast.BreakStatement{Span: span(c, start)}, nil
+}
+
 // ParseStatement parses a single statement.
 //
 // A statement currently can only be:
 //   - Base instruction (e.g. `mov ...;`)
 //   - User instruction (e.g. `[ret] ...;`)
+//   - Jump instruction (e.g. `jmp start;`)
+//   - Label (e.g. `start:`)
+//   - Local declaration (e.g. `x i32 10;`)
+//   - if, loop and while blocks, break and continue
 //
-// Returns an *ast.InstructionCall node.
+// Returns an ast.InstructionCall, ast.Jump, ast.Label, ast.LocalDeclaration, ast.IfStatement,
+// ast.LoopStatement, ast.BreakStatement or ast.ContinueStatement node.
 // Any unexpected token produces an error.
+//
+// Statements starting with the token registered by WithStatement are parsed
+// by the registered mini function instead.
 func ParseStatement(c Context) (ast.Node, error) {
 	t, _ := c.Current()
+	if fn, ok := c.Lookup(FormStatement, t); ok {
+		return fn(c)
+	}
 
 	switch t.Kind {
 	case token.BaseInstruction:
+		if token.JumpInstructionsMap.Is(t.Literal) {
+			return ParseJump(c)
+		}
 		return ParseInstructionCall(c)
+	case token.Keyword:
+		switch t.Literal {
+		case "if":
+			return ParseIf(c)
+		case "loop", "while":
+			return ParseLoop(c)
+		case "break", "continue":
+			return ParseBranch(c)
+		}
+		return nil, c.Errorf("unexpected keyword in statement: %v", t.Literal)
+	case token.Identifier:
+		next, err := c.Peek()
+		switch {
+		case err != nil:
+		case isSeparator(next, ":"):
+			return ParseLabel(c)
+		case next.Kind == token.Type:
+			return ParseLocalDeclaration(c)
+		}
+		return nil, c.Errorf("unexpected identifier in statement: %v", t.Literal)
 	case token.Separator:
 		return ParseInstructionCall(c)
 	case token.String:
@@ -339,28 +813,81 @@ func ParseStatement(c Context) (ast.Node, error) {
 // The body must start with '{' and end with '}'.
 // Inside the braces, statements are parsed by ParseStatement.
 //
+// If a statement is malformed, the error is reported
+// and parsing continues after the next ';',
+// so the body contains every statement parsed successfully.
+//
 // Returns a slice of AST nodes representing statements.
 func ParseBody(c Context) ([]ast.Node, error) {
-	_, _ = c.ExpectLiteral("{")
+	if _, err := c.ExpectLiteral("{"); err != nil {
+		return nil, err
+	}
 	var statements []ast.Node
 
 	for !c.Eof() {
 		t, _ := c.Current()
-		if t.Literal == "}" {
+		if isSeparator(t, "}") {
 			break
 		}
+		if t.Kind == token.Comment {
+			_ = c.Advance(1)
+			continue
+		}
 
 		stmt, err := ParseStatement(c)
 		if err != nil {
-			return nil, err
+			c.Report(err)
+			SkipStatement(c)
+			continue
 		}
 		statements = append(statements, stmt)
 	}
 
-	_, _ = c.ExpectLiteral("}")
+	if _, err := c.ExpectLiteral("}"); err != nil {
+		c.Report(err)
+	}
 	return statements, nil
 }
 
+// ParseImport parses the import of another file at the top level of the file:
+//
+//	import "path";
+//
+// The trailing ';' is optional. Leading comments are skipped.
+// Returns ErrNoMatch if the next token is not the import keyword.
+//
+// Returns an ast.ImportDeclaration node.
+func ParseImport(c Context) (ast.Node, error) {
+	for !c.Eof() {
+		t, _ := c.Current()
+		if t.Kind != token.Comment {
+			break
+		}
+		c.Advance(1)
+	}
+
+	start, err := c.Current()
+	if err != nil {
+		return nil, err
+	}
+	if start.Kind != token.Keyword || start.Literal != "import" {
+		return nil, ErrNoMatch
+	}
+	c.Advance(1)
+
+	path, err := c.Expect(token.String)
+	if err != nil {
+		return nil, err
+	}
+	if t, err := c.Current(); err == nil && isSeparator(t, ";") {
+		c.Advance(1)
+	}
+	return ast.ImportDeclaration{
+		Span: span(c, start),
+		Path: path.Literal,
+	}, nil
+}
+
 // ParseModuleDeclaration parses a module declaration.
 //
 // Allowed syntax:
@@ -384,33 +911,49 @@ func ParseModuleDeclaration(c Context) (ast.Node, error) {
 		return nil, err
 	}
 
-	_, _ = c.ExpectLiteral(":")
-	_, _ = c.ExpectLiteral("{")
+	if _, err := c.ExpectLiteral(":"); err != nil {
+		return nil, err
+	}
+	if _, err := c.ExpectLiteral("{"); err != nil {
+		return nil, err
+	}
 	var body []ast.Node
 	for {
 		if c.Eof() {
-			return nil, c.Errorf("module %s must be closed", ident.Literal)
+			c.Report(c.Errorf("module %s must be closed", ident.Literal))
+			break
 		}
-		if brace, _ := c.Current(); brace.Literal == "}" {
+		if brace, _ := c.Current(); isSeparator(brace, "}") {
+			_, _ = c.ExpectLiteral("}")
 			break
 		}
 		n, err := ParseTopStatement(c)
+		if errors.Is(err, ErrNoMatch) {
+			continue
+		}
 		if err != nil {
-			return nil, err
+			c.Report(err)
+			SkipTopStatement(c)
+			continue
 		}
 		body = append(body, n)
 	}
-	_, _ = c.ExpectLiteral("}")
 
 	c.SetModule(ident.Literal)
 	return ast.ModuleDeclaration{
+		Span: span(c, ident),
 		Name: c.Module(),
 		Body: body,
 	}, nil
 }
 
 // ParseTopStatement parses the top statements.
-// It can be a function, variable, constant or module.
+// It can be a function, variable, constant or module,
+// or the statement registered by WithTopStatement, which gets the doc comment
+// if it implements ast.Documentable.
+//
+// Returns ErrNoMatch if there are only comments
+// before the '}' closing the enclosing module.
 func ParseTopStatement(c Context) (ast.Node, error) {
 	var docLines []string
 	for !c.Eof() {
@@ -429,12 +972,18 @@ func ParseTopStatement(c Context) (ast.Node, error) {
 	if err != nil {
 		return nil, err
 	}
+	if isSeparator(t, "}") {
+		return nil, ErrNoMatch
+	}
 
 	var node ast.Node
-	switch t.Kind {
-	case token.String:
+	fn, registered := c.Lookup(FormTopStatement, t)
+	switch {
+	case registered:
+		node, err = fn(c)
+	case t.Kind == token.String:
 		node, err = ParseModuleDeclaration(c)
-	case token.Identifier, token.Keyword:
+	case t.Kind == token.Identifier, t.Kind == token.Keyword:
 		node, err = ParseDeclaration(c)
 	default:
 		return nil, c.Errorf("unexpected token at top level: %v", t.Literal)
@@ -446,3 +995,133 @@ func ParseTopStatement(c Context) (ast.Node, error) {
 
 	return node, err
 }
+
+// SkipStatement skips tokens of the malformed statement
+// until the next ';' at the same nesting level, which is consumed,
+// or the '}' closing the enclosing body, which is not.
+func SkipStatement(c Context) {
+	depth := 0
+	for !c.Eof() {
+		t, _ := c.Current()
+		switch {
+		case isSeparator(t, "{"):
+			depth++
+		case isSeparator(t, "}"):
+			if depth == 0 {
+				return
+			}
+			depth--
+		case isSeparator(t, ";") && depth == 0:
+			_ = c.Advance(1)
+			return
+		}
+		if err := c.Advance(1); err != nil {
+			return
+		}
+	}
+}
+
+// SkipTopStatement skips tokens of the malformed top statement
+// until the start of the next declaration or module,
+// or the '}' closing the enclosing module.
+//
+// It always skips at least one token, so the parser makes progress.
+func SkipTopStatement(c Context) {
+	depth := 0
+	for first := true; !c.Eof(); first = false {
+		t, _ := c.Current()
+		if !first && depth == 0 && startsTopStatement(c, t) {
+			return
+		}
+		switch {
+		case isSeparator(t, "{"):
+			depth++
+		case isSeparator(t, "}"):
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+		if err := c.Advance(1); err != nil {
+			return
+		}
+	}
+}
+
+// SkipModule skips tokens until the start of the next module declaration or import.
+//
+// It always skips at least one token, so the parser makes progress.
+func SkipModule(c Context) {
+	for first := true; !c.Eof(); first = false {
+		t, _ := c.Current()
+		if !first && t.Kind == token.Keyword && t.Literal == "import" {
+			return
+		}
+		if !first && t.Kind == token.String {
+			if next, err := c.Peek(); err == nil && isSeparator(next, ":") {
+				return
+			}
+		}
+		if err := c.Advance(1); err != nil {
+			return
+		}
+	}
+}
+
+// startsTopStatement reports whether t starts a declaration or module.
+func startsTopStatement(c Context, t *token.Token) bool {
+	switch t.Kind {
+	case token.Keyword:
+		return t.Literal == "link" || t.Literal == "export" || t.Literal == "declare"
+	case token.Comment:
+		return true
+	case token.Identifier, token.String:
+		next, err := c.Peek()
+		if err != nil {
+			return false
+		}
+		if t.Kind == token.String {
+			return isSeparator(next, ":")
+		}
+		return next.Kind == token.Type
+	}
+	return false
+}
+
+// startsParameters reports whether the tokens after '(' are a parameter list
+// of the function value, rather than a parenthesized expression.
+func startsParameters(c Context) bool {
+	t, err := c.Current()
+	if err != nil {
+		return false
+	}
+	if isSeparator(t, ")") {
+		return true
+	}
+	next, err := c.Peek()
+	return err == nil && t.Kind == token.Identifier && next.Kind == token.Type
+}
+
+// span returns the span from the start of the token start
+// to the end of the previous token, which is the last one of the parsed node.
+func span(c Context, start *token.Token) ast.Span {
+	s := ast.Span{StartPos: *start.Position, EndPos: *start.Position}
+	if start.End != nil {
+		s.EndPos = *start.End
+	}
+	if prev, err := c.Previous(); err == nil && prev.End != nil && prev.Position.Position >= start.Position.Position {
+		s.EndPos = *prev.End
+	}
+	return s
+}
+
+// adjacent reports whether next is written right after prev, without spaces.
+func adjacent(prev, next *token.Token) bool {
+	return prev != nil && prev.End != nil && prev.End.Position == next.Position.Position
+}
+
+// isSeparator reports whether t is the separator lit,
+// unlike comparing literals, which also matches string literals.
+func isSeparator(t *token.Token, lit string) bool {
+	return t != nil && t.Kind == token.Separator && t.Literal == lit
+}
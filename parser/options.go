@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"slices"
+
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// Form is the syntactic form custom mini functions can be registered for.
+type Form int
+
+const (
+	// FormTopStatement is a declaration inside the module, parsed by ParseTopStatement.
+	FormTopStatement Form = iota
+
+	// FormStatement is a statement inside the body, parsed by ParseStatement.
+	FormStatement
+
+	// FormValue is an operand of the value expression, parsed by ParseValue.
+	FormValue
+)
+
+// Key selects the token starting the form:
+// by its kind and literal, or by its kind alone if Literal is empty.
+type Key struct {
+	Kind    token.Kind
+	Literal string
+}
+
+// Option changes the parser created by New.
+// Options are applied in the order they're given.
+type Option func(p *Parser)
+
+// DefaultParsers returns a new list of the default mini parsers of the top level of the file,
+// in the order the parser tries them.
+func DefaultParsers() []MiniFunc {
+	return []MiniFunc{
+		ParseImport,
+		ParseModuleDeclaration,
+		ParseDeclaration,
+		ParseInstructionCall,
+	}
+}
+
+// WithParsers replaces all mini parsers of the top level of the file with fns.
+func WithParsers(fns ...MiniFunc) Option {
+	return func(p *Parser) {
+		p.parsers = slices.Clone(fns)
+	}
+}
+
+// PrependParsers adds fns before the mini parsers of the top level of the file,
+// so they're tried first.
+//
+// To let the next mini parser try, fn must return ErrNoMatch without advancing.
+func PrependParsers(fns ...MiniFunc) Option {
+	return func(p *Parser) {
+		p.parsers = append(slices.Clone(fns), p.parsers...)
+	}
+}
+
+// AppendParsers adds fns after the mini parsers of the top level of the file.
+func AppendParsers(fns ...MiniFunc) Option {
+	return func(p *Parser) {
+		p.parsers = append(p.parsers, fns...)
+	}
+}
+
+// WithTopStatement registers fn to parse declarations inside modules starting with key.
+func WithTopStatement(key Key, fn MiniFunc) Option {
+	return register(FormTopStatement, key, fn)
+}
+
+// WithStatement registers fn to parse statements inside bodies starting with key.
+func WithStatement(key Key, fn MiniFunc) Option {
+	return register(FormStatement, key, fn)
+}
+
+// WithValue registers fn to parse operands of value expressions starting with key.
+func WithValue(key Key, fn MiniFunc) Option {
+	return register(FormValue, key, fn)
+}
+
+func register(form Form, key Key, fn MiniFunc) Option {
+	return func(p *Parser) {
+		if p.registry[form] == nil {
+			p.registry[form] = map[Key]MiniFunc{}
+		}
+		p.registry[form][key] = fn
+	}
+}
@@ -0,0 +1,156 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/parser"
+	"github.com/dywoq/dywoqlang/scanner"
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// meta is the custom node returned by the registered mini functions.
+type meta struct {
+	ast.Span
+	Name string
+	Docs string
+}
+
+func (*meta) Node() {}
+
+func (m *meta) SetDocs(doc string) error {
+	m.Docs = doc
+	return nil
+}
+
+// parseMeta parses `meta "name"`, optionally followed by ';'.
+func parseMeta(c parser.Context) (ast.Node, error) {
+	start, err := c.Current()
+	if err != nil {
+		return nil, err
+	}
+	if start.Literal != "meta" {
+		return nil, parser.ErrNoMatch
+	}
+	_ = c.Advance(1)
+	name, err := c.Expect(token.String)
+	if err != nil {
+		return nil, err
+	}
+	if t, err := c.Current(); err == nil && t.Literal == ";" {
+		_ = c.Advance(1)
+	}
+	return &meta{Span: ast.Span{StartPos: *start.Position, EndPos: *name.End}, Name: name.Literal}, nil
+}
+
+// parseFlag parses the bool constant into the meta node named after it, with the given prefix.
+func parseFlag(prefix string) parser.MiniFunc {
+	return func(c parser.Context) (ast.Node, error) {
+		t, err := c.Expect(token.BoolConstant)
+		if err != nil {
+			return nil, err
+		}
+		return &meta{Span: ast.Span{StartPos: *t.Position, EndPos: *t.End}, Name: prefix + t.Literal}, nil
+	}
+}
+
+func parseWith(t *testing.T, src string, opts ...parser.Option) []ast.Node {
+	t.Helper()
+	tokens, err := scanner.New(false).Scan(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parser.New(false, opts...).Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nodes
+}
+
+func TestRegisteredMiniFunctions(t *testing.T) {
+	metaKey := parser.Key{Kind: token.Keyword, Literal: "meta"}
+	tests := []struct {
+		name string
+		opts []parser.Option
+		src  string
+		get  func(nodes []ast.Node) ast.Node
+		want meta
+	}{
+		{
+			name: "top statement",
+			opts: []parser.Option{parser.WithTopStatement(metaKey, parseMeta)},
+			src: `"main": {
+				# Describes the module.
+				meta "m"
+			}`,
+			get: func(nodes []ast.Node) ast.Node {
+				return nodes[0].(ast.ModuleDeclaration).Body[0]
+			},
+			want: meta{Name: "m", Docs: "Describes the module."},
+		},
+		{
+			name: "statement",
+			opts: []parser.Option{parser.WithStatement(metaKey, parseMeta)},
+			src:  `"main": { main void () { stdout 1; meta "s"; } }`,
+			get: func(nodes []ast.Node) ast.Node {
+				fn := nodes[0].(ast.ModuleDeclaration).Body[0].(*ast.Declaration).Value.(ast.FunctionValue)
+				return fn.Body[1]
+			},
+			want: meta{Name: "s"},
+		},
+		{
+			name: "value by kind",
+			opts: []parser.Option{parser.WithValue(parser.Key{Kind: token.BoolConstant}, parseFlag("kind "))},
+			src:  `"main": { x bool false }`,
+			get: func(nodes []ast.Node) ast.Node {
+				return nodes[0].(ast.ModuleDeclaration).Body[0].(*ast.Declaration).Value
+			},
+			want: meta{Name: "kind false"},
+		},
+		{
+			name: "value by literal",
+			opts: []parser.Option{
+				parser.WithValue(parser.Key{Kind: token.BoolConstant}, parseFlag("kind ")),
+				parser.WithValue(parser.Key{Kind: token.BoolConstant, Literal: "true"}, parseFlag("literal ")),
+			},
+			src: `"main": { main void () { stdout true, false; } }`,
+			get: func(nodes []ast.Node) ast.Node {
+				fn := nodes[0].(ast.ModuleDeclaration).Body[0].(*ast.Declaration).Value.(ast.FunctionValue)
+				return fn.Body[0].(ast.InstructionCall).Arguments[0].Value
+			},
+			want: meta{Name: "literal true"},
+		},
+		{
+			name: "prepended top-level parser",
+			opts: []parser.Option{parser.PrependParsers(parseMeta)},
+			src:  `meta "top"; "main": { }`,
+			get: func(nodes []ast.Node) ast.Node {
+				return nodes[0]
+			},
+			want: meta{Name: "top"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.get(parseWith(t, tt.src, tt.opts...)).(*meta)
+			if !ok {
+				t.Fatalf("the registered mini function wasn't called")
+			}
+			if got.Name != tt.want.Name || got.Docs != tt.want.Docs {
+				t.Errorf("got %q with docs %q, want %q with docs %q", got.Name, got.Docs, tt.want.Name, tt.want.Docs)
+			}
+			if got.Pos().Line == 0 {
+				t.Error("the node has no position")
+			}
+		})
+	}
+}
+
+func TestRegisteredMiniFunctionsIsolation(t *testing.T) {
+	src := `"main": { x i32 1 }`
+	_ = parser.New(false, parser.WithValue(parser.Key{Kind: token.Integer}, parseFlag("")))
+	d := parseWith(t, src)[0].(ast.ModuleDeclaration).Body[0].(*ast.Declaration)
+	if _, ok := d.Value.(ast.Value); !ok {
+		t.Errorf("default parser got %T, want ast.Value", d.Value)
+	}
+}
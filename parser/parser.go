@@ -3,11 +3,13 @@ package parser
 import (
 	"errors"
 	"fmt"
+	"iter"
 	"log"
 	"runtime"
 	"slices"
 
 	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
 
 	"github.com/dywoq/dywoqlang/token"
 )
@@ -17,20 +19,33 @@ type Parser struct {
 	parsers []MiniFunc
 	tokens  []*token.Token
 
-	debug   bool
-	setupOn bool
+	// next pulls the next token when parsing the stream,
+	// tokens then holds only a window of them starting at the previous one.
+	next    func() (*token.Token, error, bool)
+	stream  bool
+	dropped int
 
-	module string
+	debug    bool
+	registry map[Form]map[Key]MiniFunc
+
+	module      string
+	diagnostics diag.Diagnostics
 }
 
-func New(debug bool) *Parser {
-	return &Parser{
-		pos:     0,
-		parsers: make([]MiniFunc, 0),
-		tokens:  make([]*token.Token, 0),
-		debug:   debug,
-		setupOn: false,
+// New returns a new pointer to Parser
+// with the default mini parsers, changed by opts.
+func New(debug bool, opts ...Option) *Parser {
+	p := &Parser{
+		pos:      0,
+		parsers:  DefaultParsers(),
+		tokens:   make([]*token.Token, 0),
+		debug:    debug,
+		registry: map[Form]map[Key]MiniFunc{},
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
 func (p *Parser) Current() (*token.Token, error) {
@@ -45,29 +60,92 @@ func (p *Parser) Peek() (*token.Token, error) {
 	switch {
 	case p.Eof():
 		return nil, ErrEof
-	case p.pos+1 >= len(p.tokens):
+	case !p.load(1):
 		return nil, errors.New("the current position+1 will make the position out of tokens")
 	}
 	return p.tokens[p.pos+1], nil
 }
 
+func (p *Parser) Previous() (*token.Token, error) {
+	if p.pos == 0 || p.pos > len(p.tokens) {
+		return nil, errors.New("there is no previous token")
+	}
+	return p.tokens[p.pos-1], nil
+}
+
 func (p *Parser) Advance(n int) error {
 	switch {
 	case p.Eof():
 		return nil
-	case p.pos+n >= len(p.tokens):
+	case !p.load(n):
 		return fmt.Errorf("the current position+%d will make the position out of tokens", n)
 	}
-	p.pos += n
+	p.move(n)
 	return nil
 }
 
 func (p *Parser) Eof() bool {
-	return p.tokens[p.pos].Kind == token.Eof || p.pos > len(p.tokens)
+	return !p.load(0) || p.tokens[p.pos].Kind == token.Eof
 }
 
 func (p *Parser) Position() int {
-	return p.pos
+	return p.dropped + p.pos
+}
+
+// load makes sure the token at the current position+n is available,
+// pulling tokens from the stream if needed.
+//
+// Scanner errors coming along with tokens are reported,
+// and illegal tokens are skipped like in Parse.
+// If the stream fails or ends without the Eof token, it's completed with one.
+func (p *Parser) load(n int) bool {
+	for p.next != nil && p.pos+n >= len(p.tokens) {
+		t, err, ok := p.next()
+		if err != nil {
+			p.Report(err)
+		}
+		if t == nil || !ok {
+			p.next = nil
+			p.tokens = append(p.tokens, p.eof())
+			break
+		}
+		if t.Kind == token.Illegal {
+			continue
+		}
+		p.tokens = append(p.tokens, t)
+		if t.Kind == token.Eof {
+			p.next = nil
+		}
+	}
+	return p.pos+n < len(p.tokens)
+}
+
+// eof returns the Eof token placed at the end of the last token.
+func (p *Parser) eof() *token.Token {
+	pos := token.Position{Line: 1, Column: 1}
+	if len(p.tokens) > 0 {
+		if last := p.tokens[len(p.tokens)-1]; last.End != nil {
+			pos = *last.End
+		}
+	}
+	end := pos
+	return &token.Token{Kind: token.Eof, Position: &pos, End: &end}
+}
+
+// move advances the position by n.
+// When parsing the stream, the tokens before the previous one are dropped,
+// so the parser keeps only the tokens it may still look at.
+func (p *Parser) move(n int) {
+	p.pos += n
+	if !p.stream || p.pos < 2 {
+		return
+	}
+	drop := p.pos - 1
+	kept := copy(p.tokens, p.tokens[drop:])
+	clear(p.tokens[kept:])
+	p.tokens = p.tokens[:kept]
+	p.pos -= drop
+	p.dropped += drop
 }
 
 func (p *Parser) Expect(kind token.Kind) (*token.Token, error) {
@@ -76,9 +154,9 @@ func (p *Parser) Expect(kind token.Kind) (*token.Token, error) {
 	}
 	tok := p.tokens[p.pos]
 	if tok.Kind != kind {
-		return nil, p.Errorf("expected token kind %v, got %v", kind, tok.Kind)
+		return nil, p.diagnostic(diag.CodeUnexpectedToken, fmt.Sprintf("expected %v, got %v %q", kind, tok.Kind, tok.Literal))
 	}
-	p.pos++
+	p.move(1)
 	return tok, nil
 }
 
@@ -88,9 +166,11 @@ func (p *Parser) ExpectLiteral(lit string) (*token.Token, error) {
 	}
 	tok := p.tokens[p.pos]
 	if tok.Literal != lit {
-		return nil, p.Errorf("expected literal '%s', got '%s'", lit, tok.Literal)
+		d := p.diagnostic(diag.CodeUnexpectedToken, fmt.Sprintf("expected '%s', got '%s'", lit, tok.Literal))
+		d.Fixes = append(d.Fixes, diag.Fix{Message: fmt.Sprintf("insert '%s'", lit), Start: d.Start, End: d.Start, Replacement: lit})
+		return nil, d
 	}
-	p.pos++
+	p.move(1)
 	return tok, nil
 }
 
@@ -100,9 +180,9 @@ func (p *Parser) ExpectMultiple(kinds ...token.Kind) (*token.Token, error) {
 	}
 	tok := p.tokens[p.pos]
 	if !slices.Contains(kinds, tok.Kind) {
-		return nil, p.Errorf("expected at least one token kind (%v), got %v", kinds, tok.Kind)
+		return nil, p.diagnostic(diag.CodeUnexpectedToken, fmt.Sprintf("expected one of %v, got %v %q", kinds, tok.Kind, tok.Literal))
 	}
-	p.pos++
+	p.move(1)
 	return tok, nil
 }
 
@@ -112,33 +192,43 @@ func (p *Parser) ExpectLiterals(lits ...string) (*token.Token, error) {
 	}
 	tok := p.tokens[p.pos]
 	if !slices.Contains(lits, tok.Literal) {
-		return nil, p.Errorf("expected at least one token literal (%v), got %v", lits, tok.Kind)
+		return nil, p.diagnostic(diag.CodeUnexpectedToken, fmt.Sprintf("expected one of %v, got '%s'", lits, tok.Literal))
 	}
-	p.pos++
+	p.move(1)
 	return tok, nil
 }
 
+// Error returns a new diagnostic.
+// The difference from errors.New is that Error automatically inserts the position where the error occurred.
 func (p *Parser) Error(v ...any) error {
-	name := p.functionName(2)
-	t, _ := p.Current()
-	pos := &token.Position{}
-	if t != nil {
-		pos = t.Position
-	}
-	formatted := fmt.Sprintf("%v (source is %d, token position: %v, function: %s)", v, p.pos, pos, name)
-	return errors.New(formatted)
+	return p.diagnostic(diag.CodeSyntax, fmt.Sprint(v...))
 }
 
+// Errorf returns a new diagnostic,
+// but formatted.
+// The difference from fmt.Errorf is that Error automatically inserts the position where the error occurred.
 func (p *Parser) Errorf(format string, v ...any) error {
-	name := p.functionName(2)
-	t, _ := p.Current()
-	pos := &token.Position{}
-	if t != nil {
-		pos = t.Position
+	return p.diagnostic(diag.CodeSyntax, fmt.Sprintf(format, v...))
+}
+
+// diagnostic returns a new diagnostic pointing to the current token.
+// In the debug mode, it notes the function that raised the error.
+func (p *Parser) diagnostic(code diag.Code, message string) *diag.Diagnostic {
+	d := diag.Errorf(code, "%s", message)
+	if p.load(0) {
+		d.At(p.tokens[p.pos])
+	}
+	if p.debug {
+		d.Notes = append(d.Notes, "raised by "+p.functionName(3))
 	}
-	custom := fmt.Sprintf(format, v...)
-	formatted := fmt.Sprintf("%s (source is %d, token position: %v, function: %s)", custom, p.pos, pos, name)
-	return errors.New(formatted)
+	return d
+}
+
+// Report records err and lets the parser continue,
+// so all errors are returned at once after parsing.
+func (p *Parser) Report(err error) {
+	p.outputf("reporting error: %v\n", err)
+	p.diagnostics.Add(err)
 }
 
 // SetModule sets the current processing module name.
@@ -157,27 +247,62 @@ func (p *Parser) outputf(format string, v ...any) {
 	}
 }
 
+// Parse parses tokens returned by scanner.Scanner.Scan into the AST nodes.
+//
+// Parse doesn't stop on the first error: it skips the malformed statement,
+// declaration or module and continues, so all errors are returned at once
+// as diag.Diagnostics along with the partial AST.
+//
+// Illegal tokens are skipped, since the scanner already reported them,
+// so each mistake is reported once.
 func (p *Parser) Parse(tokens []*token.Token) ([]ast.Node, error) {
 	if len(tokens) == 0 {
 		return nil, errors.New("tokens slice is empty")
 	}
-	p.setup()
 	if len(p.parsers) == 0 {
 		return nil, errors.New("there are no mini parsers")
 	}
-	p.reset(tokens)
+	p.reset(slices.DeleteFunc(slices.Clone(tokens), func(t *token.Token) bool {
+		return t.Kind == token.Illegal
+	}))
+	return p.parseAll()
+}
+
+// ParseStream parses tokens pulled one at a time from the stream,
+// such as scanner.Scanner.All, into the AST nodes.
+//
+// Unlike Parse, it keeps only the tokens it may still look at,
+// which are the previous, the current and the next one.
+// Errors coming along with tokens are reported with the errors of the parser,
+// and the stream ending with an error is treated as the end of the input.
+func (p *Parser) ParseStream(tokens iter.Seq2[*token.Token, error]) ([]ast.Node, error) {
+	if len(p.parsers) == 0 {
+		return nil, errors.New("there are no mini parsers")
+	}
+	next, stop := iter.Pull2(tokens)
+	defer stop()
+	p.reset(nil)
+	p.next, p.stream = next, true
+	return p.parseAll()
+}
 
+func (p *Parser) parseAll() ([]ast.Node, error) {
 	nodes := []ast.Node{}
 	for !p.Eof() {
 		node, err := p.parse()
+		if errors.Is(err, ErrEof) {
+			break
+		}
 		if err != nil {
-			return nil, err
+			p.Report(err)
+			SkipModule(p)
+			continue
 		}
 		nodes = append(nodes, node)
 		p.outputf("parsed %s\n", ast.ToString(node))
 	}
 
-	return nodes, nil
+	return nodes, p.diagnostics.Err()
 }
 
 func (p *Parser) parse() (ast.Node, error) {
@@ -187,26 +312,36 @@ func (p *Parser) parse() (ast.Node, error) {
 	for _, parser := range p.parsers {
 		node, err := parser(p)
 		if err != nil {
-			if errors.Is(err, ErrEof) {
+			if errors.Is(err, ErrEof) || errors.Is(err, ErrNoMatch) {
 				continue
 			}
 			return nil, err
 		}
 		return node, nil
 	}
+	if p.Eof() {
+		return nil, ErrEof
+	}
 	t, _ := p.Current()
 	return nil, p.Errorf("met illegal token: %s", token.ToString(t))
 }
 
-func (p *Parser) setup() {
-	if !p.setupOn {
-		p.parsers = []MiniFunc{
-			ParseModuleDeclaration,
-			ParseDeclaration,
-			ParseInstructionCall,
-		}
-		p.setupOn = true
+// Lookup returns the mini function registered by options for the form starting with t,
+// preferring the one registered for its literal over the one for its kind.
+func (p *Parser) Lookup(form Form, t *token.Token) (MiniFunc, bool) {
+	if t == nil {
+		return nil, false
+	}
+	fns := p.registry[form]
+	if fn, ok := fns[Key{Kind: t.Kind, Literal: t.Literal}]; ok {
+		p.outputf("using registered mini function for %s %q\n", t.Kind, t.Literal)
+		return fn, true
+	}
+	fn, ok := fns[Key{Kind: t.Kind}]
+	if ok {
+		p.outputf("using registered mini function for %s\n", t.Kind)
 	}
+	return fn, ok
 }
 
 func (p *Parser) functionName(skip int) string {
@@ -224,4 +359,8 @@ func (p *Parser) functionName(skip int) string {
 func (p *Parser) reset(tokens []*token.Token) {
 	p.tokens = tokens
 	p.pos = 0
+	p.next = nil
+	p.stream = false
+	p.dropped = 0
+	p.diagnostics = nil
 }
@@ -0,0 +1,323 @@
+package parser_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/parser"
+	"github.com/dywoq/dywoqlang/scanner"
+)
+
+func parse(src string) ([]ast.Node, error) {
+	tokens, err := scanner.New(false).Scan(src)
+	if err != nil {
+		return nil, err
+	}
+	return parser.New(false).Parse(tokens)
+}
+
+// sexpr renders the expression n with every binary expression parenthesized.
+func sexpr(n ast.Node) string {
+	switch n := n.(type) {
+	case ast.Value:
+		switch {
+		case n.Consteval:
+			return "consteval(" + sexpr(n.ValueNode) + ")"
+		case n.Copied:
+			return "copy(" + sexpr(n.ValueNode) + ")"
+		case n.ValueNode != nil:
+			return sexpr(n.ValueNode)
+		}
+		return n.Value
+	case ast.BinaryExpression:
+		children := make([]string, len(n.Children))
+		for i, child := range n.Children {
+			children[i] = sexpr(child)
+		}
+		return "(" + strings.Join(append([]string{n.Operator}, children...), " ") + ")"
+	case ast.CallExpression:
+		args := make([]string, len(n.Arguments))
+		for i, arg := range n.Arguments {
+			args[i] = sexpr(arg)
+		}
+		return "[" + n.Name + "](" + strings.Join(args, ", ") + ")"
+	}
+	return fmt.Sprintf("%T", n)
+}
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1 + 2 * 3", "(+ 1 (* 2 3))"},
+		{"1 * 2 + 3", "(+ (* 1 2) 3)"},
+		{"1 - 2 - 3", "(- (- 1 2) 3)"},
+		{"8 / 4 / 2", "(/ (/ 8 4) 2)"},
+		{"(1 + 2) * 3", "(* (+ 1 2) 3)"},
+		{"1 - (2 - 3)", "(- 1 (- 2 3))"},
+		{"a + b < c * d", "(< (+ a b) (* c d))"},
+		{"1 < 2 == 3 < 4", "(< (== (< 1 2) 3) 4)"},
+		{"-2 * 3", "(* -2 3)"},
+		{"-a * b", "(* (- 0 a) b)"},
+		{"-(1 + 2)", "(- 0 (+ 1 2))"},
+		{"1 - -2", "(- 1 -2)"},
+		{"[f](1 + 2, 3) * 4", "(* [f]((+ 1 2), 3) 4)"},
+		{"consteval(1 + 2) * 3", "(* consteval((+ 1 2)) 3)"},
+		{"copy(a) + 1", "(+ copy(a) 1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			nodes, err := parse(`"main": { x i32 ` + tt.src + ` }`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := nodes[0].(ast.ModuleDeclaration).Body[0].(*ast.Declaration)
+			if got := sexpr(d.Value); got != tt.want {
+				t.Errorf("parsed %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInstructionCall(t *testing.T) {
+	tests := []struct {
+		src  string
+		name string
+		want []string
+	}{
+		{"[f](x, y);", "f", []string{"x", "y"}},
+		{"[f]();", "f", nil},
+		{"[f](x + 1, [g](2) * 3);", "f", []string{"(+ x 1)", "(* [g](2) 3)"}},
+		{"[f]((1 + 2) * 3, -x);", "f", []string{"(* (+ 1 2) 3)", "(- 0 x)"}},
+		{"[f] x * 2, (y - 1) / 2;", "f", []string{"(* x 2)", "(/ (- y 1) 2)"}},
+		{`[g] (x + 1), "a";`, "g", []string{"(+ x 1)", "a"}},
+		{`[g] (x + 1) * 2, "a";`, "g", []string{"(* (+ x 1) 2)", "a"}},
+		{"[f] (x), (y);", "f", []string{"x", "y"}},
+		{"add a, x * 2 + 1;", "add", []string{"a", "(+ (* x 2) 1)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			nodes, err := parse(`"main": { main void () { ` + tt.src + ` } }`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fn := nodes[0].(ast.ModuleDeclaration).Body[0].(*ast.Declaration).Value.(ast.FunctionValue)
+			if len(fn.Body) != 1 {
+				t.Fatalf("parsed %d statements, want 1", len(fn.Body))
+			}
+			ic := fn.Body[0].(ast.InstructionCall)
+			var args []string
+			for _, a := range ic.Arguments {
+				args = append(args, sexpr(a.Value))
+			}
+			if ic.Name != tt.name || !slices.Equal(args, tt.want) {
+				t.Errorf("parsed %s %v, want %s %v", ic.Name, args, tt.name, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"missing value", `"main": { x i32 }`},
+		{"unclosed parenthesis", `"main": { x i32 (1 + 2 }`},
+		{"missing operand", `"main": { x i32 1 + }`},
+		{"unclosed module", `"main": { x i32 1`},
+		{"missing semicolon", `"main": { main void () { stdout 1 } }`},
+		{"unknown statement", `"main": { main void () { 1; } }`},
+		{"result of call statement", `"main": { main void () { [f](1) + 2; } }`},
+		{"unclosed call statement", `"main": { main void () { [f](1, 2; } }`},
+		{"arguments after call statement", `"main": { main void () { [f](1), 2; } }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.src)
+			var list diag.Diagnostics
+			list.Add(err)
+			if len(list) == 0 {
+				t.Fatal("parsed without errors")
+			}
+			for _, d := range list {
+				if d.Code != diag.CodeSyntax && d.Code != diag.CodeUnexpectedToken {
+					t.Errorf("got %s %q, want syntax error", d.Code, d.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		lines []int
+		want  []string
+	}{
+		{
+			name: "statements",
+			src: `"main": {
+				main void () {
+					1;
+					stdout 1;
+					stdout );
+					stdout 2;
+				}
+			}`,
+			lines: []int{3, 5},
+			want:  []string{"main", "main", "stdout", "stdout"},
+		},
+		{
+			name: "top statements",
+			src: `"main": {
+				x i32 ;
+				y i32 1
+				z 5
+				w i32 2
+			}`,
+			lines: []int{2, 4},
+			want:  []string{"main", "y", "w"},
+		},
+		{
+			name: "modules",
+			src: `"a": { x i32 1 }
+			42
+			"b": { y i32 2 }
+			mov
+			"c": { z i32 3 }`,
+			lines: []int{2, 4},
+			want:  []string{"a", "x", "b", "y", "c", "z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := parse(tt.src)
+			var list diag.Diagnostics
+			list.Add(err)
+			var lines []int
+			for _, d := range list {
+				lines = append(lines, d.Start.Line)
+			}
+			if fmt.Sprint(lines) != fmt.Sprint(tt.lines) {
+				t.Errorf("got errors on lines %v, want %v: %v", lines, tt.lines, list)
+			}
+
+			var names []string
+			for _, n := range nodes {
+				ast.Inspect(n, func(n ast.Node) bool {
+					switch n := n.(type) {
+					case ast.ModuleDeclaration:
+						names = append(names, n.Name)
+					case *ast.Declaration:
+						names = append(names, n.Name)
+					case ast.InstructionCall:
+						names = append(names, n.Name)
+					}
+					return true
+				})
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+				t.Errorf("parsed %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIllegalTokens(t *testing.T) {
+	tokens, err := scanner.New(false).Scan(`"main": { x i32 1 $ y i32 = 2 }`)
+	var list diag.Diagnostics
+	list.Add(err)
+	if len(list) != 2 {
+		t.Fatalf("got %v, want 2 scanner errors", list)
+	}
+	nodes, err := parser.New(false).Parse(tokens)
+	if err != nil {
+		t.Fatalf("illegal tokens are reported again: %v", err)
+	}
+	if got := len(nodes[0].(ast.ModuleDeclaration).Body); got != 2 {
+		t.Errorf("parsed %d declarations, want 2", got)
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		errors bool
+	}{
+		{
+			name: "program",
+			src: `import "lib.dl";
+			# Entry point.
+			"main": {
+				link("lib") twice i32 (x i32)
+				export total i32 [twice](2) * (3 + 4)
+				main void (a i32, b str copy(false)) {
+					n i32 0;
+					start:
+					add n, n, 1;
+					cmp n, 10;
+					jl start;
+					if n < 10 { stdout n; } else if n == 10 { stdout "ten"; } else { loop { break; } }
+					while n > 0 { sub n, n, 1; continue; }
+					[f] copy(a), consteval(array(1, 2) + array(3));
+				}
+				"inner": { x f64 -1.5e3 }
+			}`,
+		},
+		{
+			name: "errors",
+			src: `"a": { x i32 ; y i32 1 }
+			42
+			"b": { f void () { 1; stdout ); [g](1, 2); } }
+			"c": { z i32 $ 3 }`,
+			errors: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := scanner.New(false).Scan(tt.src)
+			var want diag.Diagnostics
+			want.Add(err)
+			nodes, err := parser.New(false).Parse(tokens)
+			want.Add(err)
+			if failed := len(want) != 0; failed != tt.errors {
+				t.Fatalf("got errors %v, want errors: %v", want, tt.errors)
+			}
+
+			streamed, err := parser.New(false).ParseStream(scanner.NewReader(strings.NewReader(tt.src), false).All())
+			var got diag.Diagnostics
+			got.Add(err)
+
+			if len(streamed) != len(nodes) {
+				t.Fatalf("got %d nodes, want %d", len(streamed), len(nodes))
+			}
+			for i := range nodes {
+				if got, want := ast.ToString(streamed[i]), ast.ToString(nodes[i]); got != want {
+					t.Errorf("node %d is\n%s\nwant\n%s", i, got, want)
+				}
+			}
+			if got, want := messages(got), messages(want); !slices.Equal(got, want) {
+				t.Errorf("got errors %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// messages returns sorted messages of list,
+// since the stream reports scanner errors along with the parser ones.
+func messages(list diag.Diagnostics) []string {
+	var result []string
+	for _, d := range list {
+		result = append(result, d.Error())
+	}
+	slices.Sort(result)
+	return result
+}
@@ -16,7 +16,14 @@ type Reader interface {
 	Current() (rune, error)
 
 	// Input returns the current input from the scanner.
+	//
+	// When reading from io.Reader, it's only the buffered part of the input,
+	// which starts at the current token.
 	Input() string
+
+	// Rest returns the input from the current position
+	// to the end of the current line at least.
+	Rest() string
 }
 
 type Tracker interface {
@@ -46,7 +53,8 @@ type Slicer interface {
 }
 
 type Advancer interface {
-	// Advance advances to the next position by n
+	// Advance advances to the next position by n characters,
+	// decoding them as UTF-8 runes.
 	//
 	// If newline character is met,
 	// scanner increases line and column beside the current position.
@@ -58,6 +66,11 @@ type Advancer interface {
 	Advance(n int) error
 }
 
+type TableHolder interface {
+	// Tables returns the token tables used by the tokenizers.
+	Tables() *token.Tables
+}
+
 // Context is an interface that allows you to work with scanners directly,
 // such as reading character, getting position and create tokens more simple and comfortably.
 type Context interface {
@@ -67,6 +80,7 @@ type Context interface {
 	EofChecker
 	Slicer
 	Advancer
+	TableHolder
 }
 
 // Peek uses Reader.Peek method to peek the future character.
@@ -9,4 +9,7 @@ var (
 
 	// ErrEof is returned by scanner if the scanner reached End Of File (EOF).
 	ErrEof = errors.New("reached eof")
+
+	// ErrIllegalCharacter is returned by scanner if no tokenizer matches the current character.
+	ErrIllegalCharacter = errors.New("illegal character")
 )
@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// Option changes the scanner created by New or NewReader.
+// Options are applied in the order they're given.
+type Option func(s *Scanner)
+
+// DefaultTokenizers returns a new list of the default tokenizers,
+// in the order the scanner tries them.
+func DefaultTokenizers() []TokenizerFunc {
+	return []TokenizerFunc{
+		TokenizeComment,
+		TokenizeBoolConstant,
+		TokenizeTypes,
+		TokenizeBaseInstruction,
+		TokenizeIdentifier,
+		TokenizeSpecial,
+		TokenizeKeyword,
+		TokenizeSeparator,
+		TokenizeBinaryOperator,
+		TokenizeNumber,
+		TokenizeString,
+	}
+}
+
+// WithTokenizers replaces all tokenizers of the scanner with fns.
+func WithTokenizers(fns ...TokenizerFunc) Option {
+	return func(s *Scanner) {
+		s.tokenizers = slices.Clone(fns)
+	}
+}
+
+// PrependTokenizers adds fns before the tokenizers of the scanner,
+// so they're tried first.
+func PrependTokenizers(fns ...TokenizerFunc) Option {
+	return func(s *Scanner) {
+		s.tokenizers = append(slices.Clone(fns), s.tokenizers...)
+	}
+}
+
+// AppendTokenizers adds fns after the tokenizers of the scanner,
+// so they're tried if no other tokenizer matches.
+func AppendTokenizers(fns ...TokenizerFunc) Option {
+	return func(s *Scanner) {
+		s.tokenizers = append(s.tokenizers, fns...)
+	}
+}
+
+// ReplaceTokenizer replaces the tokenizer old, such as TokenizeString, with fn.
+// Does nothing if the scanner doesn't use old.
+//
+// Tokenizers are compared by the function they refer to,
+// so old must be a function rather than a closure.
+func ReplaceTokenizer(old, fn TokenizerFunc) Option {
+	return func(s *Scanner) {
+		target := reflect.ValueOf(old).Pointer()
+		for i, t := range s.tokenizers {
+			if reflect.ValueOf(t).Pointer() == target {
+				s.tokenizers[i] = fn
+			}
+		}
+	}
+}
+
+// WithTables replaces all token tables of the scanner.
+// Nil maps of t are left as they are.
+func WithTables(t token.Tables) Option {
+	return func(s *Scanner) {
+		set := func(dst *token.Map, m token.Map) {
+			if m != nil {
+				*dst = m
+			}
+		}
+		set(&s.tables.Keywords, t.Keywords)
+		set(&s.tables.Types, t.Types)
+		set(&s.tables.Instructions, t.Instructions)
+		set(&s.tables.Specials, t.Specials)
+		set(&s.tables.BoolConstants, t.BoolConstants)
+		set(&s.tables.Separators, t.Separators)
+		set(&s.tables.BinaryOperators, t.BinaryOperators)
+	}
+}
+
+// WithKeywords sets the keywords matched by TokenizeKeyword.
+func WithKeywords(m token.Map) Option {
+	return WithTables(token.Tables{Keywords: m})
+}
+
+// WithTypes sets the types matched by TokenizeTypes.
+func WithTypes(m token.Map) Option {
+	return WithTables(token.Tables{Types: m})
+}
+
+// WithInstructions sets the base instructions matched by TokenizeBaseInstruction.
+func WithInstructions(m token.Map) Option {
+	return WithTables(token.Tables{Instructions: m})
+}
+
+// WithUTF16 sets whether columns are counted in UTF-16 code units,
+// as the language server protocol expects, instead of runes.
+func WithUTF16(utf16 bool) Option {
+	return func(s *Scanner) {
+		s.utf16 = utf16
+	}
+}
@@ -0,0 +1,151 @@
+package scanner_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/scanner"
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// kinds returns kinds of tokens scanned from src, without the Eof one.
+// Illegal tokens are kept, so the test can tell what the scanner didn't match.
+func kinds(s *scanner.Scanner, src string) []token.Kind {
+	tokens, _ := s.Scan(src)
+	var result []token.Kind
+	for _, t := range tokens {
+		if t.Kind != token.Eof {
+			result = append(result, t.Kind)
+		}
+	}
+	return result
+}
+
+func tokenizeAt(c scanner.Context) (*token.Token, error) {
+	if r, _ := c.Current(); r != '@' {
+		return nil, scanner.ErrNoMatch
+	}
+	if err := c.Advance(1); err != nil {
+		return nil, err
+	}
+	return c.New("@", token.Special), nil
+}
+
+func tokenizeQuoted(c scanner.Context) (*token.Token, error) {
+	t, err := scanner.TokenizeString(c)
+	if err != nil {
+		return nil, err
+	}
+	t.Kind = token.Identifier
+	return t, nil
+}
+
+func TestTokenizerOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  scanner.Option
+		src  string
+		want []token.Kind
+	}{
+		{
+			name: "prepend",
+			opt:  scanner.PrependTokenizers(tokenizeAt),
+			src:  "x @",
+			want: []token.Kind{token.Identifier, token.Special},
+		},
+		{
+			name: "append",
+			opt:  scanner.AppendTokenizers(tokenizeAt),
+			src:  "x @",
+			want: []token.Kind{token.Identifier, token.Special},
+		},
+		{
+			name: "replace",
+			opt:  scanner.ReplaceTokenizer(scanner.TokenizeString, tokenizeQuoted),
+			src:  `"s" 1`,
+			want: []token.Kind{token.Identifier, token.Integer},
+		},
+		{
+			name: "with",
+			opt:  scanner.WithTokenizers(tokenizeAt),
+			src:  "@@",
+			want: []token.Kind{token.Special, token.Special},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(scanner.DefaultTokenizers())
+			custom := scanner.New(false, tt.opt)
+			other := scanner.New(false, tt.opt)
+			plain := scanner.New(false)
+
+			if got := kinds(custom, tt.src); !slices.Equal(got, tt.want) {
+				t.Errorf("custom scanner got %v, want %v", got, tt.want)
+			}
+			if got := kinds(other, tt.src); !slices.Equal(got, tt.want) {
+				t.Errorf("second scanner with the same option got %v, want %v", got, tt.want)
+			}
+			if got := kinds(plain, tt.src); slices.Equal(got, tt.want) {
+				t.Errorf("default scanner got %v too", got)
+			}
+			if after := len(scanner.DefaultTokenizers()); after != before {
+				t.Errorf("got %d default tokenizers, want %d", after, before)
+			}
+		})
+	}
+}
+
+func TestTableOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  scanner.Option
+		src  string
+		want []token.Kind
+	}{
+		{
+			name: "keywords",
+			opt:  scanner.WithKeywords(token.Map{"fn": token.Keyword}),
+			src:  "fn if",
+			want: []token.Kind{token.Keyword, token.Identifier},
+		},
+		{
+			name: "types",
+			opt:  scanner.WithTypes(token.Map{"int": token.Type}),
+			src:  "int str",
+			want: []token.Kind{token.Type, token.Identifier},
+		},
+		{
+			name: "instructions",
+			opt:  scanner.WithInstructions(token.Map{"push": token.BaseInstruction}),
+			src:  "push mov",
+			want: []token.Kind{token.BaseInstruction, token.Identifier},
+		},
+		{
+			name: "tables",
+			opt:  scanner.WithTables(token.Tables{BoolConstants: token.Map{"yes": token.BoolConstant}}),
+			src:  "yes true if",
+			want: []token.Kind{token.BoolConstant, token.Identifier, token.Keyword},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults := *token.DefaultTables()
+			custom := scanner.New(false, tt.opt)
+			plain := scanner.New(false)
+
+			if got := kinds(custom, tt.src); !slices.Equal(got, tt.want) {
+				t.Errorf("custom scanner got %v, want %v", got, tt.want)
+			}
+			if got := kinds(plain, tt.src); slices.Equal(got, tt.want) {
+				t.Errorf("default scanner got %v too", got)
+			}
+			for _, m := range []token.Map{defaults.Keywords, defaults.Types, defaults.Instructions, defaults.BoolConstants} {
+				for _, word := range []string{"fn", "int", "push", "yes"} {
+					if m.Is(word) {
+						t.Errorf("%s leaked into the default tables", word)
+					}
+				}
+			}
+		})
+	}
+}
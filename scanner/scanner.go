@@ -1,38 +1,74 @@
 package scanner
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
+	"io"
+	"iter"
 	"log"
 	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 
+	"github.com/dywoq/dywoqlang/diag"
 	"github.com/dywoq/dywoqlang/token"
 )
 
 type Scanner struct {
+	// input holds the input starting at the byte offset base.
+	// When reading from io.Reader, the scanned lines are discarded between tokens,
+	// and the next ones are read on demand.
 	input    string
+	base     int
 	position *token.Position
 
-	setupOn bool
-	debug   bool
+	reader   *bufio.Reader
+	stream   bool
+	err      error
+	finished bool
+
+	debug bool
+	utf16 bool
 
 	tokenizers []TokenizerFunc
+	tables     token.Tables
 }
 
-// New returns a new pointer to Scanner.
-func New(debug bool) *Scanner {
-	return &Scanner{
+// New returns a new pointer to Scanner
+// with the default tokenizers and token tables, changed by opts.
+func New(debug bool, opts ...Option) *Scanner {
+	s := &Scanner{
 		input:      "",
 		position:   &token.Position{Line: 1, Column: 1},
-		setupOn:    false,
 		debug:      debug,
-		tokenizers: make([]TokenizerFunc, 0),
+		tokenizers: DefaultTokenizers(),
+		tables:     *token.DefaultTables(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
+}
+
+// NewReader returns a new pointer to Scanner that reads the input from r,
+// producing tokens on demand with Next or All.
+//
+// Since no token spans multiple lines, only the line being scanned is kept in memory.
+func NewReader(r io.Reader, debug bool, opts ...Option) *Scanner {
+	s := New(debug, opts...)
+	s.reset("")
+	s.reader = bufio.NewReader(r)
+	s.stream = true
+	return s
 }
 
-// Advance advances to the next position by n
+// Advance advances to the next position by n characters,
+// decoding them as UTF-8 runes.
 //
 // If newline character is met,
 // scanner increases line and column beside the current position.
+// Columns are counted in runes, or in UTF-16 code units if set by WithUTF16.
 //
 // Returns an error if scanner reached End Of File (EOF),
 // or the current position+n will make the position out of the input.
@@ -42,7 +78,7 @@ func (s *Scanner) Advance(n int) error {
 	if s.Eof() {
 		return ErrEof
 	}
-	if s.position.Position+n > len(s.input) {
+	if !s.available(n) {
 		return errors.New("the current position+n is higher than the length")
 	}
 	if n == 0 {
@@ -53,41 +89,65 @@ func (s *Scanner) Advance(n int) error {
 		if s.Eof() {
 			return nil
 		}
-		r := rune(s.input[s.position.Position])
+		r, size := utf8.DecodeRuneInString(s.input[s.position.Position-s.base:])
 		s.outputf("advancing past %s\n", string(r))
-		s.position.Position++
+		s.position.Position += size
 
 		if r == '\n' {
 			s.outputf("entering new line...\n")
 			s.position.Line++
 			s.position.Column = 1
 		} else {
-			s.position.Column++
+			s.position.Column += s.width(r)
 		}
 	}
 	return nil
 }
 
+// available reports whether n runes are left from the current position,
+// reading the input from io.Reader if needed.
+func (s *Scanner) available(n int) bool {
+	offset := s.position.Position - s.base
+	for range n {
+		for offset >= len(s.input) {
+			if !s.more() {
+				return false
+			}
+		}
+		_, size := utf8.DecodeRuneInString(s.input[offset:])
+		offset += size
+	}
+	return true
+}
+
 // Slice takes a substring from the input surrounded by start and end.
 //
 // Returns an error if start is negative, start is higher than end,
 // or end is higher than the input.
+// When reading from io.Reader, start can't point before the current token.
 func (s *Scanner) Slice(start, end int) (string, error) {
 	switch {
 	case start > end:
 		return "", errors.New("start is higher than the end")
 	case start < 0:
 		return "", errors.New("start is negative")
-	case end > len(s.input):
+	case start < s.base:
+		return "", errors.New("start is before the buffered input")
+	case end > s.base+len(s.input):
 		return "", errors.New("end is higher than the input")
 	}
 	s.outputf("slicing %d and %d\n", start, end)
-	return s.input[start:end], nil
+	return s.input[start-s.base : end-s.base], nil
 }
 
 // Eof returns true if scanner reached End Of File (EOF).
 func (s *Scanner) Eof() bool {
-	return s.position.Position >= len(s.input)
+	for s.position.Position >= s.base+len(s.input) {
+		if !s.more() {
+			return true
+		}
+	}
+	return false
 }
 
 // Peek returns the future character.
@@ -95,13 +155,18 @@ func (s *Scanner) Eof() bool {
 // Returns an error if the scanner reached EOF,
 // or the current position+1 will make the scanner position out of the input.
 func (s *Scanner) Peek() (rune, error) {
-	switch {
-	case s.Eof():
+	if s.Eof() {
 		return 0, errors.New("reached eof")
-	case s.position.Position+1 >= len(s.input):
+	}
+	_, size := utf8.DecodeRuneInString(s.input[s.position.Position-s.base:])
+	next := s.position.Position + size
+	for next >= s.base+len(s.input) && s.more() {
+	}
+	if next >= s.base+len(s.input) {
 		return 0, errors.New("current position+1 is higher than the input")
 	}
-	return rune(s.input[s.position.Position+1]), nil
+	r, _ := utf8.DecodeRuneInString(s.input[next-s.base:])
+	return r, nil
 }
 
 // Current returns the current character.
@@ -110,8 +175,22 @@ func (s *Scanner) Current() (rune, error) {
 	if s.Eof() {
 		return 0, errors.New("reached eof")
 	}
-	s.outputf("getting current character %s\n", string(s.input[s.position.Position]))
-	return rune(s.input[s.position.Position]), nil
+	r, _ := utf8.DecodeRuneInString(s.input[s.position.Position-s.base:])
+	s.outputf("getting current character %s\n", string(r))
+	return r, nil
+}
+
+// Tables returns the token tables used by the tokenizers.
+func (s *Scanner) Tables() *token.Tables {
+	return &s.tables
+}
+
+// width returns the number of columns taken by r.
+func (s *Scanner) width(r rune) int {
+	if s.utf16 && r >= 0x10000 {
+		return utf16.RuneLen(r)
+	}
+	return 1
 }
 
 // Position returns a current position.
@@ -130,41 +209,179 @@ func (s *Scanner) New(literal string, kind token.Kind) *token.Token {
 }
 
 // Input returns the current input from the scanner.
+//
+// When reading from io.Reader, it's only the buffered part of the input,
+// which starts at the current token.
 func (s *Scanner) Input() string {
 	return s.input
 }
 
+// Rest returns the input from the current position
+// to the end of the current line at least.
+func (s *Scanner) Rest() string {
+	if s.Eof() {
+		return ""
+	}
+	return s.input[s.position.Position-s.base:]
+}
+
 // Scan scans input, turning characters into the tokens.
 //
-// If there are no tokenizers even after the setup of default tokenizers (which is rare),
+// If there are no tokenizers, which is only possible if they were removed by options,
 // Scan returns an error.
 //
 // If input is empty, the function returns an error.
+//
+// Scan doesn't stop on the first tokenizer error:
+// it inserts an illegal token, skips the offending character and continues,
+// so all errors are returned at once as diag.Diagnostics
+// along with the tokens scanned.
 func (s *Scanner) Scan(input string) ([]*token.Token, error) {
 	if len(input) == 0 {
 		return nil, errors.New("input is empty")
 	}
-	s.setup()
 	if len(s.tokenizers) == 0 {
 		return nil, errors.New("there are no tokenizers")
 	}
 	s.reset(input)
 
-	var result []*token.Token
-	for !s.Eof() {
-		err := s.skip()
-		if err != nil {
-			return nil, err
+	var (
+		result      []*token.Token
+		diagnostics diag.Diagnostics
+	)
+	for {
+		t, err := s.Next()
+		if errors.Is(err, io.EOF) {
+			break
 		}
-		t, err := s.tokenize()
-		if err != nil {
+		if t == nil {
 			return nil, err
 		}
+		diagnostics.Add(err)
 		result = append(result, t)
 	}
+	return result, diagnostics.Err()
+}
+
+// Next scans and returns the next token,
+// reading the input from io.Reader on demand if the scanner was created with NewReader.
+//
+// After the last token, Next returns the token.Eof one, and then nil with io.EOF.
+// If the tokenizer fails, Next returns the illegal token covering the skipped input
+// along with the diagnostic, so the caller may continue.
+// Errors of reading the input are returned with a nil token.
+func (s *Scanner) Next() (*token.Token, error) {
+	if s.finished {
+		return nil, io.EOF
+	}
+	if len(s.tokenizers) == 0 {
+		return nil, errors.New("there are no tokenizers")
+	}
+	s.discard()
+
+	if err := s.skip(); err != nil {
+		return nil, err
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.Eof() {
+		s.finished = true
+		end := *s.position
+		return s.span(token.NewToken("", token.Eof, &end), end), nil
+	}
+
+	start := *s.position
+	t, err := s.tokenize()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if err != nil {
+		illegal := s.recover(start)
+		return illegal, s.diagnostic(err, illegal)
+	}
+	return s.span(t, start), nil
+}
+
+// All returns an iterator over tokens returned by Next,
+// ending with the token.Eof one, or with the error of reading the input.
+func (s *Scanner) All() iter.Seq2[*token.Token, error] {
+	return func(yield func(*token.Token, error) bool) {
+		for {
+			t, err := s.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(t, err) || t == nil {
+				return
+			}
+		}
+	}
+}
 
-	result = append(result, token.NewToken("", token.Eof, s.position))
-	return result, nil
+// more reads the next line from io.Reader into the buffered input,
+// reporting whether anything was read.
+func (s *Scanner) more() bool {
+	if s.reader == nil {
+		return false
+	}
+	line, err := s.reader.ReadString('\n')
+	s.input += line
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			s.err = err
+		}
+		s.reader = nil
+	}
+	return line != ""
+}
+
+// discard drops the input before the current position
+// when reading from io.Reader, since the tokens before it are already returned.
+func (s *Scanner) discard() {
+	if !s.stream {
+		return
+	}
+	s.input = s.input[s.position.Position-s.base:]
+	s.base = s.position.Position
+}
+
+// span sets the position of t to start,
+// and its end to the current position.
+func (s *Scanner) span(t *token.Token, start token.Position) *token.Token {
+	end := *s.position
+	t.Position = &start
+	t.End = &end
+	return t
+}
+
+// diagnostic converts the tokenizer error into the diagnostic
+// pointing to the illegal token, unless it's already a diagnostic with the position.
+func (s *Scanner) diagnostic(err error, illegal *token.Token) *diag.Diagnostic {
+	var d *diag.Diagnostic
+	if !errors.As(err, &d) {
+		code := diag.CodeInvalidToken
+		if errors.Is(err, ErrIllegalCharacter) {
+			code = diag.CodeIllegalCharacter
+		}
+		d = diag.Errorf(code, "%s", err)
+	}
+	if d.Start.Line == 0 {
+		d.At(illegal)
+	}
+	return d
+}
+
+// recover makes sure the scanner moved past the character
+// where the tokenizer failed, returning an illegal token
+// covering the skipped input.
+func (s *Scanner) recover(start token.Position) *token.Token {
+	if s.position.Position == start.Position && !s.Eof() {
+		_ = s.Advance(1)
+	}
+	literal, _ := s.Slice(start.Position, s.position.Position)
+	s.outputf("recovering after illegal input %q\n", literal)
+	return s.span(token.NewToken(literal, token.Illegal, nil), start)
 }
 
 func (s *Scanner) skip() error {
@@ -196,6 +413,11 @@ func (s *Scanner) outputf(format string, v ...any) {
 
 func (s *Scanner) reset(input string) {
 	s.input = input
+	s.base = 0
+	s.reader = nil
+	s.stream = false
+	s.err = nil
+	s.finished = false
 	s.position.Position = 0
 	s.position.Line = 1
 	s.position.Column = 1
@@ -213,24 +435,6 @@ func (s *Scanner) tokenize() (*token.Token, error) {
 		}
 		return tok, nil
 	}
-	return token.NewToken("illegal", token.Illegal, s.position), errors.New("met illegal character")
-}
-
-func (s *Scanner) setup() {
-	if !s.setupOn {
-		s.tokenizers = []TokenizerFunc{
-			TokenizeComment,
-			TokenizeBoolConstant,
-			TokenizeTypes,
-			TokenizeBaseInstruction,
-			TokenizeIdentifier,
-			TokenizeSpecial,
-			TokenizeKeyword,
-			TokenizeSeparator,
-			TokenizeBinaryOperator,
-			TokenizeNumber,
-			TokenizeString,
-		}
-		s.setupOn = true
-	}
+	r, _ := s.Current()
+	return nil, fmt.Errorf("%w %q", ErrIllegalCharacter, r)
 }
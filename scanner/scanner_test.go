@@ -0,0 +1,210 @@
+package scanner_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/scanner"
+	"github.com/dywoq/dywoqlang/token"
+)
+
+func TestScanNumbers(t *testing.T) {
+	tests := []struct {
+		src   string
+		value string
+		kind  token.Kind
+	}{
+		{"0x1F", "31", token.Integer},
+		{"0b1010", "10", token.Integer},
+		{"0o17", "15", token.Integer},
+		{"1_000", "1000", token.Integer},
+		{"1.5e3", "1500", token.Float},
+		{"2E-1", "0.2", token.Float},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			tokens, err := scanner.New(false).Scan(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tokens) == 0 {
+				t.Fatal("got no tokens")
+			}
+			got := tokens[0]
+			if got.Kind != tt.kind || got.Literal != tt.src || got.Value != tt.value {
+				t.Errorf("got %s %s with value %s, want %s %s with value %s", got.Kind, got.Literal, got.Value, tt.kind, tt.src, tt.value)
+			}
+		})
+	}
+}
+
+func TestScanNumberErrors(t *testing.T) {
+	for _, src := range []string{"0x1__F", "0b102", "1e999", "1_"} {
+		t.Run(src, func(t *testing.T) {
+			_, err := scanner.New(false).Scan(src)
+			var list diag.Diagnostics
+			list.Add(err)
+			if len(list) != 1 || list[0].Code != diag.CodeInvalidToken {
+				t.Errorf("got %v, want one %s diagnostic", list, diag.CodeInvalidToken)
+			}
+		})
+	}
+}
+
+func TestScanUTF8(t *testing.T) {
+	src := "имя \"héllo 😀\" x"
+	tests := []struct {
+		name    string
+		utf16   bool
+		columns []int
+	}{
+		{"runes", false, []int{1, 5, 15, 16}},
+		{"utf-16", true, []int{1, 5, 16, 17}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := scanner.New(false, scanner.WithUTF16(tt.utf16)).Scan(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []struct {
+				kind    token.Kind
+				literal string
+			}{
+				{token.Identifier, "имя"},
+				{token.String, "héllo 😀"},
+				{token.Identifier, "x"},
+				{token.Eof, ""},
+			}
+			if len(tokens) != len(want) {
+				t.Fatalf("got %d tokens, want %d", len(tokens), len(want))
+			}
+			for i, tok := range tokens {
+				if tok.Kind != want[i].kind || tok.Literal != want[i].literal || tok.Position.Column != tt.columns[i] {
+					t.Errorf("token %d is %s %q at column %d, want %s %q at column %d",
+						i, tok.Kind, tok.Literal, tok.Position.Column, want[i].kind, want[i].literal, tt.columns[i])
+				}
+			}
+			if got, want := tokens[2].Position.Position, len(src)-1; got != want {
+				t.Errorf("x is at byte %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestAdvanceRunes(t *testing.T) {
+	var errs []error
+	advance := func(c scanner.Context) (*token.Token, error) {
+		if r, _ := c.Current(); r != 'é' {
+			return nil, scanner.ErrNoMatch
+		}
+		errs = append(errs, c.Advance(3), c.Advance(2))
+		return c.New("é", token.Identifier), nil
+	}
+	if _, err := scanner.New(false, scanner.PrependTokenizers(advance)).Scan("éü"); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 || errs[0] == nil || errs[1] != nil {
+		t.Errorf("advancing by 3 and 2 runes of 2 returned %v, want an error and nil", errs)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	src := `# Sums numbers.
+"main": {
+	total i32 0x10
+	sum i32 (n i32) {
+		acc i32 0;
+		loop: add acc, acc, n;
+		sub n, n, 1;
+		cmp n, 0;
+		jg loop;
+		ret acc;
+	}
+	name str "имя 😀" $ x
+}`
+	want, err := scanner.New(false).Scan(src)
+	var list diag.Diagnostics
+	list.Add(err)
+	if len(list) != 1 {
+		t.Fatalf("got %v, want one error for $", list)
+	}
+
+	tests := []struct {
+		name string
+		scan func(s *scanner.Scanner) ([]*token.Token, int)
+	}{
+		{"Next", func(s *scanner.Scanner) ([]*token.Token, int) {
+			var tokens []*token.Token
+			errs := 0
+			for {
+				tok, err := s.Next()
+				if errors.Is(err, io.EOF) {
+					return tokens, errs
+				}
+				if tok == nil {
+					t.Fatal(err)
+				}
+				if err != nil {
+					errs++
+				}
+				tokens = append(tokens, tok)
+			}
+		}},
+		{"All", func(s *scanner.Scanner) ([]*token.Token, int) {
+			var tokens []*token.Token
+			errs := 0
+			for tok, err := range s.All() {
+				if err != nil {
+					errs++
+				}
+				tokens = append(tokens, tok)
+			}
+			return tokens, errs
+		}},
+	}
+	for _, tt := range tests {
+		for _, r := range []struct {
+			name string
+			r    io.Reader
+		}{
+			{"whole", strings.NewReader(src)},
+			{"one byte", iotest.OneByteReader(strings.NewReader(src))},
+		} {
+			t.Run(tt.name+"/"+r.name, func(t *testing.T) {
+				got, errs := tt.scan(scanner.NewReader(r.r, false))
+				if errs != 1 {
+					t.Errorf("got %d errors, want 1", errs)
+				}
+				if len(got) != len(want) {
+					t.Fatalf("got %d tokens, want %d", len(got), len(want))
+				}
+				for i := range want {
+					if !reflect.DeepEqual(got[i], want[i]) {
+						t.Errorf("token %d is %+v at %+v, want %+v at %+v", i, got[i], got[i].Position, want[i], want[i].Position)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("x\ny"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	var last error
+	n := 0
+	for tok, err := range scanner.NewReader(r, false).All() {
+		if tok != nil {
+			n++
+		}
+		last = err
+	}
+	if n != 1 || !errors.Is(last, io.ErrUnexpectedEOF) {
+		t.Errorf("got %d tokens and %v, want 1 token and %v", n, last, io.ErrUnexpectedEOF)
+	}
+}
@@ -3,8 +3,11 @@ package scanner
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/dywoq/dywoqlang/diag"
 	"github.com/dywoq/dywoqlang/token"
 )
 
@@ -19,64 +22,51 @@ type TokenizerFunc func(c Context) (*token.Token, error)
 //
 // Returns an error if scanner reached End Of File (EOF).
 //
-// If there's a point after the number, the tokenizer will mark it as a float number,
-// if there's still a point, but after it there's no number, the tokenizer will return an error.
+// Besides decimal integers, the tokenizer accepts hexadecimal (0x1F), binary (0b1010)
+// and octal (0o755) ones, digits separated by underscores (1_000_000),
+// and floats with the fractional part, the exponent or both (1.5e-3).
+// The literal is kept as written, and its normalized value is stored in Value of the token.
+//
+// If there's a point after the number, but there's no number after it, the tokenizer returns an error.
 func TokenizeNumber(c Context) (*token.Token, error) {
 	if c.Eof() {
 		return nil, ErrEof
 	}
-	if r, _ := c.Current(); !unicode.IsNumber(r) {
+	rest := c.Rest()
+	if rest == "" || rest[0] < '0' || rest[0] > '9' {
 		return nil, ErrNoMatch
 	}
 
-	start := c.Position().Position
-	r, _ := c.Current()
-
-	for unicode.IsNumber(r) {
-		if err := c.Advance(1); err != nil {
-			return nil, err
-		}
-		if c.Eof() {
-			break
-		}
-		r, _ = c.Current()
-	}
-
-	if r, _ := c.Current(); r != '.' {
-		substr, err := c.Slice(start, c.Position().Position)
-		if err != nil {
-			return nil, err
+	prefixed := len(rest) > 1 && rest[0] == '0' && strings.ContainsRune("xXbBoO", rune(rest[1]))
+	end, point := 0, false
+loop:
+	for end < len(rest) {
+		r := rest[end]
+		switch {
+		case r == '_' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z':
+		case r == '.' && !prefixed && !point:
+			if end+1 >= len(rest) || rest[end+1] < '0' || rest[end+1] > '9' {
+				return nil, errors.New("expected a number after point")
+			}
+			point = true
+		case (r == '+' || r == '-') && !prefixed && (rest[end-1] == 'e' || rest[end-1] == 'E'):
+		default:
+			break loop
 		}
-		return c.New(substr, token.Integer), nil
+		end++
 	}
 
-	if err := c.Advance(1); err != nil {
+	literal := rest[:end]
+	if err := c.Advance(end); err != nil {
 		return nil, err
 	}
-	if c.Eof() {
-		return nil, errors.New("expected a number after point")
-	}
-
-	r, _ = c.Current()
-	if !unicode.IsNumber(r) {
-		return nil, errors.New("expected a number after point")
-	}
-
-	for unicode.IsNumber(r) {
-		if err := c.Advance(1); err != nil {
-			return nil, err
-		}
-		if c.Eof() {
-			break
-		}
-		r, _ = c.Current()
-	}
-
-	substr, err := c.Slice(start, c.Position().Position)
+	value, kind, err := token.ParseNumber(literal)
 	if err != nil {
 		return nil, err
 	}
-	return c.New(substr, token.Float), nil
+	t := c.New(literal, kind)
+	t.Value = value
+	return t, nil
 }
 
 // TokenizeString tokenizes a string.
@@ -101,12 +91,12 @@ func TokenizeString(c Context) (*token.Token, error) {
 	start := c.Position().Position
 	for {
 		if c.Eof() {
-			return nil, fmt.Errorf("unterminated string at line %d, column %d", c.Position().Line, c.Position().Column)
+			return nil, unterminatedString(c)
 		}
 
 		r, _ := c.Current()
 		if r == '\n' {
-			return nil, fmt.Errorf("unterminated string at line %d, column %d", c.Position().Line, c.Position().Column)
+			return nil, unterminatedString(c)
 		}
 
 		if r == '"' {
@@ -131,6 +121,15 @@ func TokenizeString(c Context) (*token.Token, error) {
 	return c.New(substr, token.String), nil
 }
 
+// unterminatedString returns the diagnostic suggesting to close the string
+// at the current position.
+func unterminatedString(c Context) *diag.Diagnostic {
+	d := diag.Errorf(diag.CodeInvalidToken, "unterminated string")
+	pos := *c.Position()
+	d.Fixes = append(d.Fixes, diag.Fix{Message: "close the string", Start: pos, End: pos, Replacement: `"`})
+	return d
+}
+
 // TokenizeKeyword tokenizes a keyword.
 //
 // Returns an error if the scanner reached End Of File (EOF).
@@ -138,40 +137,7 @@ func TokenizeString(c Context) (*token.Token, error) {
 // If it doesn't match, the function returns ErrNoMatch
 // and advances to the initial position.
 func TokenizeKeyword(c Context) (*token.Token, error) {
-	if c.Eof() {
-		return nil, ErrEof
-	}
-	if r, _ := c.Current(); !unicode.IsLetter(r) {
-		return nil, ErrNoMatch
-	}
-
-	start := c.Position().Position
-	pos := start
-	for {
-		if pos >= len(c.Input()) {
-			break
-		}
-		r := rune(c.Input()[pos])
-		if !unicode.IsLetter(r) {
-			break
-		}
-		pos++
-	}
-
-	substr, err := c.Slice(start, pos)
-	if err != nil {
-		return nil, err
-	}
-
-	if !token.KeywordsMap.Is(substr) {
-		return nil, ErrNoMatch
-	}
-
-	if err := c.Advance(pos - start); err != nil {
-		return nil, err
-	}
-
-	return c.New(substr, token.Keyword), nil
+	return tokenizeWord(c, c.Tables().Keywords, token.Keyword)
 }
 
 // TokenizeSeparator tokenizes a separator.
@@ -185,7 +151,7 @@ func TokenizeSeparator(c Context) (*token.Token, error) {
 		return nil, ErrEof
 	}
 	r, _ := c.Current()
-	if !token.SeparatorsMap.Is(string(r)) {
+	if !c.Tables().Separators.Is(string(r)) {
 		return nil, ErrNoMatch
 	}
 
@@ -203,40 +169,7 @@ func TokenizeSeparator(c Context) (*token.Token, error) {
 // If it doesn't match, the function returns ErrNoMatch
 // and advances to the initial position.
 func TokenizeSpecial(c Context) (*token.Token, error) {
-	if c.Eof() {
-		return nil, ErrEof
-	}
-	if r, _ := c.Current(); !unicode.IsLetter(r) {
-		return nil, ErrNoMatch
-	}
-
-	start := c.Position().Position
-	for {
-		err := c.Advance(1)
-		if err != nil {
-			return nil, err
-		}
-
-		if c.Eof() {
-			break
-		}
-
-		r, _ := c.Current()
-		if !unicode.IsLetter(r) {
-			break
-		}
-	}
-
-	substr, err := c.Slice(start, c.Position().Position)
-	if err != nil {
-		return nil, err
-	}
-
-	if !token.SpecialMap.Is(substr) {
-		c.Position().Position = start
-		return nil, ErrNoMatch
-	}
-	return c.New(substr, token.Special), nil
+	return tokenizeWord(c, c.Tables().Specials, token.Special)
 }
 
 // TokenizeBaseInstruction tokenizes base instructions.
@@ -246,40 +179,7 @@ func TokenizeSpecial(c Context) (*token.Token, error) {
 // If it doesn't match, the function returns ErrNoMatch
 // and advances to the initial position.
 func TokenizeBaseInstruction(c Context) (*token.Token, error) {
-	if c.Eof() {
-		return nil, ErrEof
-	}
-	if r, _ := c.Current(); !unicode.IsLetter(r) {
-		return nil, ErrNoMatch
-	}
-
-	start := c.Position().Position
-	for {
-		err := c.Advance(1)
-		if err != nil {
-			return nil, err
-		}
-
-		if c.Eof() {
-			break
-		}
-
-		r, _ := c.Current()
-		if !unicode.IsLetter(r) {
-			break
-		}
-	}
-
-	substr, err := c.Slice(start, c.Position().Position)
-	if err != nil {
-		return nil, err
-	}
-
-	if !token.BaseInstructionsMap.Is(substr) {
-		c.Position().Position = start
-		return nil, ErrNoMatch
-	}
-	return c.New(substr, token.BaseInstruction), nil
+	return tokenizeWord(c, c.Tables().Instructions, token.BaseInstruction)
 }
 
 // TokenizeBinaryOperator tokenizes binary operators
@@ -292,19 +192,26 @@ func TokenizeBinaryOperator(c Context) (*token.Token, error) {
 	if c.Eof() {
 		return nil, ErrEof
 	}
-	r, _ := c.Current()
-	if !token.BinaryOperatorsMap.Is(string(r)) {
-		return nil, ErrNoMatch
-	}
-
-	if err := c.Advance(1); err != nil {
-		return nil, err
+	// the longest operator is matched, so "<=" isn't scanned as "<" and "=".
+	operators, rest := c.Tables().BinaryOperators, c.Rest()
+	for end := min(operators.Longest(), len(rest)); end > 0; end-- {
+		literal := rest[:end]
+		if !operators.Is(literal) {
+			continue
+		}
+		if err := c.Advance(utf8.RuneCountInString(literal)); err != nil {
+			return nil, err
+		}
+		return c.New(literal, token.BinaryOperator), nil
 	}
-	return c.New(string(r), token.BinaryOperator), nil
+	return nil, ErrNoMatch
 }
 
 // TokenizeIdentifier tokenizes identifiers.
 //
+// Identifiers start with a Unicode letter or underscore,
+// followed by letters, digits and underscores.
+//
 // Returns an error if the scanner reached End Of File (EOF).
 //
 // If it doesn't match, the function returns ErrNoMatch
@@ -319,27 +226,12 @@ func TokenizeIdentifier(c Context) (*token.Token, error) {
 		return nil, ErrNoMatch
 	}
 
-	start := c.Position().Position
-	pos := start
-
-	for pos < len(c.Input()) {
-		r := rune(c.Input()[pos])
-		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
-			break
-		}
-		pos++
-	}
-
-	substr, err := c.Slice(start, pos)
-	if err != nil {
-		return nil, err
-	}
-
-	if !token.IsIdentifier(substr) {
+	substr := word(c)
+	if !c.Tables().IsIdentifier(substr) {
 		return nil, ErrNoMatch
 	}
 
-	if err := c.Advance(pos - start); err != nil {
+	if err := c.Advance(utf8.RuneCountInString(substr)); err != nil {
 		return nil, err
 	}
 
@@ -362,61 +254,19 @@ func TokenizeTypes(c Context) (*token.Token, error) {
 		return nil, ErrNoMatch
 	}
 
-	start := c.Position().Position
-	for {
-		err := c.Advance(1)
-		if err != nil {
-			return nil, err
-		}
-
-		if c.Eof() {
-			break
-		}
-
-		r, _ := c.Current()
-		if !unicode.IsLetter(r) {
-			break
+	substr := word(c)
+	if !c.Tables().Types.Is(substr) {
+		// i33 or u7 look like numeric types, so they aren't left to other tokenizers.
+		if len(substr) > 1 && strings.ContainsRune("iuf", rune(substr[0])) && isDigits(substr[1:]) {
+			return nil, fmt.Errorf("wrong numeric type: %s", substr)
 		}
+		return nil, ErrNoMatch
 	}
 
-	substr, err := c.Slice(start, c.Position().Position)
-	if err != nil {
+	if err := c.Advance(utf8.RuneCountInString(substr)); err != nil {
 		return nil, err
 	}
-
-	switch substr {
-	case "str", "void", "bool":
-		return c.New(substr, token.Type), nil
-
-	case "i", "u", "f":
-		for {
-			c.Advance(1)
-			if c.Eof() {
-				break
-			}
-			r, _ = c.Current()
-			if !unicode.IsNumber(r) {
-				break
-			}
-		}
-
-		substr, err = c.Slice(start, c.Position().Position)
-		if err != nil {
-			return nil, err
-		}
-
-		if !token.TypesMap.Is(substr) {
-			return nil, fmt.Errorf("wrong numeric type: %s", substr)
-		}
-
-		return c.New(substr, token.Type), nil
-
-	case "[]":
-		return c.New(substr, token.Type), nil
-	}
-
-	c.Position().Position = start
-	return nil, ErrNoMatch
+	return c.New(substr, token.Type), nil
 }
 
 // TokenizeTypes tokenizes bool constants.
@@ -426,43 +276,7 @@ func TokenizeTypes(c Context) (*token.Token, error) {
 // If it doesn't match, the function returns ErrNoMatch
 // and advances to the initial position.
 func TokenizeBoolConstant(c Context) (*token.Token, error) {
-	if c.Eof() {
-		return nil, ErrEof
-	}
-
-	r, _ := c.Current()
-	if !unicode.IsLetter(r) {
-		return nil, ErrNoMatch
-	}
-
-	start := c.Position().Position
-	for {
-		err := c.Advance(1)
-		if err != nil {
-			return nil, err
-		}
-
-		if c.Eof() {
-			break
-		}
-
-		r, _ := c.Current()
-		if !unicode.IsLetter(r) {
-			break
-		}
-	}
-
-	substr, err := c.Slice(start, c.Position().Position)
-	if err != nil {
-		return nil, err
-	}
-
-	if !token.BoolConstantsMap.Is(substr) {
-		c.Position().Position = start
-		return nil, ErrNoMatch
-	}
-
-	return c.New(substr, token.BoolConstant), nil
+	return tokenizeWord(c, c.Tables().BoolConstants, token.BoolConstant)
 }
 
 // TokenizeTypes tokenizes comments that start with hash (#).
@@ -497,3 +311,51 @@ func TokenizeComment(c Context) (*token.Token, error) {
 	literal, _ := c.Slice(start, end)
 	return c.New(literal, token.Comment), nil
 }
+
+// tokenizeWord tokenizes the word at the current position
+// if it's in m, giving the token kind.
+//
+// The whole word is matched, so `add1` isn't split into `add` and `1`.
+func tokenizeWord(c Context, m token.Map, kind token.Kind) (*token.Token, error) {
+	if c.Eof() {
+		return nil, ErrEof
+	}
+	if r, _ := c.Current(); !unicode.IsLetter(r) {
+		return nil, ErrNoMatch
+	}
+
+	substr := word(c)
+	if !m.Is(substr) {
+		return nil, ErrNoMatch
+	}
+
+	if err := c.Advance(utf8.RuneCountInString(substr)); err != nil {
+		return nil, err
+	}
+	return c.New(substr, kind), nil
+}
+
+// word returns the run of letters, digits and underscores
+// at the current position without advancing.
+func word(c Context) string {
+	rest := c.Rest()
+	end := 0
+	for end < len(rest) {
+		r, size := utf8.DecodeRuneInString(rest[end:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		end += size
+	}
+	return rest[:end]
+}
+
+// isDigits reports whether s consists only of decimal digits.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,63 @@
+package sema
+
+import "github.com/dywoq/dywoqlang/ast"
+
+// SymbolKind represents the kind of symbol.
+type SymbolKind string
+
+const (
+	ModuleSymbol    SymbolKind = "module"
+	FunctionSymbol  SymbolKind = "function"
+	VariableSymbol  SymbolKind = "variable"
+	ParameterSymbol SymbolKind = "parameter"
+	LocalSymbol     SymbolKind = "local"
+	LabelSymbol     SymbolKind = "label"
+)
+
+// Symbol is a named entity found in the program.
+type Symbol struct {
+	Name   string     `json:"name"`
+	Kind   SymbolKind `json:"kind"`
+	Module string     `json:"module"`
+	Node   ast.Node   `json:"-"`
+}
+
+// Scope is a table of symbols.
+// Lookups that fail in the scope continue in the parent scope.
+type Scope struct {
+	Parent  *Scope             `json:"-"`
+	Symbols map[string]*Symbol `json:"symbols"`
+}
+
+// NewScope returns a new pointer to Scope with the given parent.
+func NewScope(parent *Scope) *Scope {
+	return &Scope{Parent: parent, Symbols: map[string]*Symbol{}}
+}
+
+// Insert inserts sym into the scope.
+//
+// If the scope already has a symbol with the same name,
+// Insert doesn't replace it and returns the existing symbol.
+func (s *Scope) Insert(sym *Symbol) *Symbol {
+	if existing, ok := s.Symbols[sym.Name]; ok {
+		return existing
+	}
+	s.Symbols[sym.Name] = sym
+	return nil
+}
+
+// LookupLocal looks up name only in the scope itself.
+func (s *Scope) LookupLocal(name string) *Symbol {
+	return s.Symbols[name]
+}
+
+// Lookup looks up name in the scope and its parents.
+// Returns nil if the name isn't found.
+func (s *Scope) Lookup(name string) *Symbol {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if sym, ok := scope.Symbols[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package sema
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// Analyzer performs the semantic analysis of parsed modules,
+// building scoped symbol tables and resolving names.
+type Analyzer struct {
+	info     *Info
+	errors   diag.Diagnostics
+	warnings diag.Diagnostics
+
+	debug bool
+}
+
+// Info holds the symbol tables built by the analysis.
+//
+// Universe holds modules, Modules holds declarations of each module,
+// and Functions holds parameters and locals of each function,
+// with its module scope as the parent.
+// Locals of nested blocks are in the scopes of the blocks,
+// which aren't stored.
+type Info struct {
+	Universe  *Scope                      `json:"universe"`
+	Modules   map[string]*Scope           `json:"modules"`
+	Functions map[*ast.Declaration]*Scope `json:"-"`
+	Warnings  diag.Diagnostics            `json:"warnings"`
+}
+
+type env struct {
+	module   string
+	function string
+	scope    *Scope
+
+	// labels holds labels of the current block and enclosing ones,
+	// and loop is set inside loop bodies.
+	labels *Scope
+	loop   bool
+}
+
+// New returns a new pointer to Analyzer.
+func New(debug bool) *Analyzer {
+	return &Analyzer{debug: debug}
+}
+
+// Analyze analyzes nodes returned by parser.Parser.Parse.
+//
+// It reports undefined and duplicate names, including labels of jumps,
+// break or continue outside loops, and copied arguments of parameters marked with copy(false) as errors,
+// and parameters and locals shadowing outer locals, parameters or module declarations
+// as warnings stored in Info.Warnings.
+//
+// If there are errors, Analyze returns the info along with diag.Diagnostics.
+func (a *Analyzer) Analyze(nodes []ast.Node) (*Info, error) {
+	a.reset()
+	var modules []ast.ModuleDeclaration
+	for _, n := range nodes {
+		if _, ok := n.(ast.ImportDeclaration); ok {
+			continue
+		}
+		md, ok := n.(ast.ModuleDeclaration)
+		if !ok {
+			return nil, fmt.Errorf("unexpected top-level node %T", n)
+		}
+		modules = append(modules, a.declareModule(md)...)
+	}
+
+	for _, md := range modules {
+		scope := a.info.Modules[md.Name]
+		for _, n := range md.Body {
+			d, ok := n.(*ast.Declaration)
+			if !ok {
+				continue
+			}
+			a.declaration(env{module: md.Name, scope: scope}, d)
+		}
+	}
+
+	a.info.Warnings = a.warnings
+	if len(a.errors) != 0 {
+		return a.info, a.errors
+	}
+	return a.info, nil
+}
+
+// declareModule inserts md and its nested modules into the universe,
+// returning all of them.
+func (a *Analyzer) declareModule(md ast.ModuleDeclaration) []ast.ModuleDeclaration {
+	e := env{module: md.Name, scope: a.info.Universe}
+	if existing := a.info.Universe.Insert(&Symbol{Name: md.Name, Kind: ModuleSymbol, Module: md.Name, Node: md}); existing != nil {
+		a.errorf(e, md, diag.CodeDuplicate, "module %q is declared more than once", md.Name)
+		return nil
+	}
+
+	scope := NewScope(nil)
+	a.info.Modules[md.Name] = scope
+	modules := []ast.ModuleDeclaration{md}
+	for _, n := range md.Body {
+		switch n := n.(type) {
+		case *ast.Declaration:
+			kind := VariableSymbol
+			if _, ok := n.Value.(ast.FunctionValue); ok {
+				kind = FunctionSymbol
+			}
+			if existing := scope.Insert(&Symbol{Name: n.Name, Kind: kind, Module: md.Name, Node: n}); existing != nil {
+				a.errorf(e, n, diag.CodeDuplicate, "%s is declared more than once", n.Name).Label(existing.Node, existing.Module, "first declared here")
+			}
+		case ast.ModuleDeclaration:
+			modules = append(modules, a.declareModule(n)...)
+		}
+	}
+	return modules
+}
+
+func (a *Analyzer) declaration(e env, d *ast.Declaration) {
+	fn, ok := d.Value.(ast.FunctionValue)
+	if !ok {
+		a.value(e, d.Value)
+		return
+	}
+
+	a.outputf("analyzing %s.%s\n", e.module, d.Name)
+	e.function = d.Name
+	e.scope = NewScope(e.scope)
+	a.info.Functions[d] = e.scope
+	for _, p := range fn.Parameters {
+		if shadowed := e.scope.Parent.LookupLocal(p.Identifier); shadowed != nil {
+			a.warnf(e, p, "parameter %s shadows the module declaration", p.Identifier).Label(shadowed.Node, shadowed.Module, "declared here")
+		}
+		if existing := e.scope.Insert(&Symbol{Name: p.Identifier, Kind: ParameterSymbol, Module: e.module, Node: p}); existing != nil {
+			a.errorf(e, p, diag.CodeDuplicate, "parameter %s is declared more than once", p.Identifier).Label(existing.Node, existing.Module, "first declared here")
+		}
+	}
+
+	a.body(e, fn.Body)
+}
+
+// block analyzes the nested block, which declares locals in its own scope.
+func (a *Analyzer) block(e env, body []ast.Node) {
+	e.scope = NewScope(e.scope)
+	a.body(e, body)
+}
+
+// body analyzes statements of the function body or a nested block.
+//
+// Locals are declared in the scope of e, and visible from the next statement
+// until the end of the block, including nested blocks.
+// Labels are visible in the block declaring them and nested blocks,
+// so jumps can leave blocks, but can't enter them.
+func (a *Analyzer) body(e env, body []ast.Node) {
+	e.labels = NewScope(e.labels)
+	for _, stmt := range body {
+		l, ok := stmt.(ast.Label)
+		if !ok {
+			continue
+		}
+		if existing := e.labels.Insert(&Symbol{Name: l.Name, Kind: LabelSymbol, Module: e.module, Node: l}); existing != nil {
+			a.errorf(e, l, diag.CodeDuplicate, "label %s is declared more than once", l.Name).Label(existing.Node, existing.Module, "first declared here")
+		}
+	}
+
+	for _, stmt := range body {
+		switch stmt := stmt.(type) {
+		case ast.InstructionCall:
+			a.instruction(e, stmt)
+		case ast.Jump:
+			if e.labels.Lookup(stmt.Label) == nil {
+				a.errorf(e, stmt, diag.CodeUndefined, "undefined label %s", stmt.Label)
+			}
+		case ast.LocalDeclaration:
+			a.value(e, stmt.Value)
+			if existing := e.scope.Insert(&Symbol{Name: stmt.Name, Kind: LocalSymbol, Module: e.module, Node: stmt}); existing != nil {
+				a.errorf(e, stmt, diag.CodeDuplicate, "%s is declared more than once", stmt.Name).Label(existing.Node, existing.Module, "first declared here")
+			} else if shadowed := e.scope.Parent.Lookup(stmt.Name); shadowed != nil {
+				a.warnf(e, stmt, "local %s shadows the %s", stmt.Name, describe(shadowed)).Label(shadowed.Node, shadowed.Module, "declared here")
+			}
+		case ast.IfStatement:
+			a.value(e, stmt.Condition)
+			a.block(e, stmt.Body)
+			a.block(e, stmt.Else)
+		case ast.LoopStatement:
+			a.value(e, stmt.Condition)
+			inner := e
+			inner.loop = true
+			a.block(inner, stmt.Body)
+		case ast.BreakStatement:
+			if !e.loop {
+				a.errorf(e, stmt, diag.CodeMisplaced, "break is not in a loop")
+			}
+		case ast.ContinueStatement:
+			if !e.loop {
+				a.errorf(e, stmt, diag.CodeMisplaced, "continue is not in a loop")
+			}
+		}
+	}
+}
+
+func (a *Analyzer) instruction(e env, ic ast.InstructionCall) {
+	if ic.IsUser {
+		args := make([]ast.Node, len(ic.Arguments))
+		for i, arg := range ic.Arguments {
+			args[i] = arg.Value
+		}
+		a.function(e, ic, ic.Name, args)
+	}
+
+	for _, arg := range ic.Arguments {
+		a.value(e, arg.Value)
+	}
+}
+
+// function resolves the called function name in the module scope,
+// and checks that copied arguments of the call n are passed to parameters allowing copying.
+func (a *Analyzer) function(e env, n ast.Node, name string, args []ast.Node) {
+	sym := a.info.Modules[e.module].LookupLocal(name)
+	switch {
+	case sym == nil:
+		a.errorf(e, n, diag.CodeUndefined, "undefined function %s", name)
+		return
+	case sym.Kind != FunctionSymbol:
+		a.errorf(e, n, diag.CodeNotFunction, "%s is not a function", name)
+		return
+	}
+
+	// declarations marked with declare or link have the same parameters as their definitions,
+	// which is checked by the linker.
+	fn := sym.Node.(*ast.Declaration).Value.(ast.FunctionValue)
+	for i, arg := range args {
+		v, ok := arg.(ast.Value)
+		if !ok || !v.Copied || i >= len(fn.Parameters) {
+			continue
+		}
+		if p := fn.Parameters[i]; !p.CopyAllowed {
+			a.errorf(e, arg, diag.CodeCopyNotAllowed, "parameter %s of %s doesn't allow copying", p.Identifier, name).
+				Label(p, sym.Module, "marked with copy(false)")
+		}
+	}
+}
+
+func (a *Analyzer) value(e env, n ast.Node) {
+	switch n := n.(type) {
+	case ast.Value:
+		if n.ValueNode != nil {
+			a.value(e, n.ValueNode)
+			return
+		}
+		if n.Kind == token.Identifier && e.scope.Lookup(n.Value) == nil {
+			a.errorf(e, n, diag.CodeUndefined, "undefined identifier %s", n.Value)
+		}
+	case ast.ArrayValue:
+		for _, el := range n.Elements {
+			a.value(e, el.Value)
+		}
+	case ast.CallExpression:
+		a.function(e, n, n.Name, n.Arguments)
+		for _, arg := range n.Arguments {
+			a.value(e, arg)
+		}
+	case ast.BinaryExpression:
+		for _, child := range n.Children {
+			a.value(e, child)
+		}
+	}
+}
+
+// errorf reports the error pointing to n, returning its diagnostic,
+// so the caller can add labels to it.
+func (a *Analyzer) errorf(e env, n ast.Node, code diag.Code, format string, v ...any) *diag.Diagnostic {
+	d := diag.Errorf(code, format, v...).Span(n).In(e.module, e.function)
+	a.errors = append(a.errors, d)
+	return d
+}
+
+// warnf reports the shadowing warning pointing to n, returning its diagnostic,
+// so the caller can add labels to it.
+func (a *Analyzer) warnf(e env, n ast.Node, format string, v ...any) *diag.Diagnostic {
+	d := diag.New(diag.Warning, diag.CodeShadowed, fmt.Sprintf(format, v...)).Span(n).In(e.module, e.function)
+	a.warnings = append(a.warnings, d)
+	return d
+}
+
+// describe returns what the shadowed symbol is, such as "parameter".
+func describe(sym *Symbol) string {
+	switch sym.Kind {
+	case LocalSymbol:
+		return "outer local"
+	case ParameterSymbol:
+		return "parameter"
+	}
+	return "module declaration"
+}
+
+func (a *Analyzer) outputf(format string, v ...any) {
+	if a.debug {
+		log.Printf(format, v...)
+	}
+}
+
+func (a *Analyzer) reset() {
+	a.info = &Info{
+		Universe:  NewScope(nil),
+		Modules:   map[string]*Scope{},
+		Functions: map[*ast.Declaration]*Scope{},
+	}
+	a.errors = nil
+	a.warnings = nil
+}
@@ -0,0 +1,214 @@
+package sema_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/internal/parsetest"
+	"github.com/dywoq/dywoqlang/sema"
+)
+
+func codes(list diag.Diagnostics) []diag.Code {
+	var result []diag.Code
+	for _, d := range list {
+		result = append(result, d.Code)
+	}
+	return result
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		errors   []diag.Code
+		warnings []diag.Code
+	}{
+		{
+			name: "valid",
+			src:  `"main": { x i32 1 f i32 (a i32) { b i32 a + x; ret b; } main void () { [f](x); } }`,
+		},
+		{
+			name:   "undefined identifier",
+			src:    `"main": { main void () { stdout y; } }`,
+			errors: []diag.Code{diag.CodeUndefined},
+		},
+		{
+			name:   "undefined function",
+			src:    `"main": { main void () { [g]; } }`,
+			errors: []diag.Code{diag.CodeUndefined},
+		},
+		{
+			name:   "undefined label",
+			src:    `"main": { main void () { jmp nowhere; } }`,
+			errors: []diag.Code{diag.CodeUndefined},
+		},
+		{
+			name:   "label of nested block",
+			src:    `"main": { main void () { jmp inner; if 1 < 2 { inner: } } }`,
+			errors: []diag.Code{diag.CodeUndefined},
+		},
+		{
+			name:   "local used before declaration",
+			src:    `"main": { main void () { stdout a; a i32 1; } }`,
+			errors: []diag.Code{diag.CodeUndefined},
+		},
+		{
+			name:   "local of nested block",
+			src:    `"main": { main void () { if 1 < 2 { a i32 1; } stdout a; } }`,
+			errors: []diag.Code{diag.CodeUndefined},
+		},
+		{
+			name:   "duplicate module",
+			src:    `"main": { } "main": { }`,
+			errors: []diag.Code{diag.CodeDuplicate},
+		},
+		{
+			name:   "duplicate declaration",
+			src:    `"main": { x i32 1 x i32 2 }`,
+			errors: []diag.Code{diag.CodeDuplicate},
+		},
+		{
+			name:   "duplicate parameter",
+			src:    `"main": { f void (a i32, a i32) { } }`,
+			errors: []diag.Code{diag.CodeDuplicate},
+		},
+		{
+			name:   "duplicate local",
+			src:    `"main": { main void () { a i32 1; a i32 2; } }`,
+			errors: []diag.Code{diag.CodeDuplicate},
+		},
+		{
+			name:   "duplicate label",
+			src:    `"main": { main void () { a: a: } }`,
+			errors: []diag.Code{diag.CodeDuplicate},
+		},
+		{
+			name:   "call of variable",
+			src:    `"main": { x i32 1 main void () { [x]; } }`,
+			errors: []diag.Code{diag.CodeNotFunction},
+		},
+		{
+			name:   "break outside loop",
+			src:    `"main": { main void () { break; } }`,
+			errors: []diag.Code{diag.CodeMisplaced},
+		},
+		{
+			name:   "continue outside loop",
+			src:    `"main": { main void () { if 1 < 2 { continue; } } }`,
+			errors: []diag.Code{diag.CodeMisplaced},
+		},
+		{
+			name:   "copied argument of copy(false) parameter",
+			src:    `"main": { f void (a i32 copy(false)) { } main void () { x i32 1; [f] copy(x); } }`,
+			errors: []diag.Code{diag.CodeCopyNotAllowed},
+		},
+		{
+			name:     "shadowed module declaration",
+			src:      `"main": { x i32 1 f void (x i32) { } }`,
+			warnings: []diag.Code{diag.CodeShadowed},
+		},
+		{
+			name:     "local shadowing module declaration",
+			src:      `"main": { x i32 1 f void () { x i32 2; } }`,
+			warnings: []diag.Code{diag.CodeShadowed},
+		},
+		{
+			name:     "nested local shadowing module declaration",
+			src:      `"main": { x i32 1 f void () { loop { x i32 2; break; } } }`,
+			warnings: []diag.Code{diag.CodeShadowed},
+		},
+		{
+			name:     "nested local shadowing parameter",
+			src:      `"main": { f void (a i32) { if a < 1 { a i32 2; } } }`,
+			warnings: []diag.Code{diag.CodeShadowed},
+		},
+		{
+			name:     "nested local shadowing outer local",
+			src:      `"main": { f void () { a i32 1; if a < 1 { } else { a i32 2; } } }`,
+			warnings: []diag.Code{diag.CodeShadowed},
+		},
+		{
+			name: "locals of sibling blocks",
+			src:  `"main": { f void () { if 1 < 2 { a i32 1; } loop { a i32 2; break; } } }`,
+		},
+		{
+			name:   "local redeclaring parameter",
+			src:    `"main": { f void (a i32) { a i32 2; } }`,
+			errors: []diag.Code{diag.CodeDuplicate},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := sema.New(false).Analyze(parsetest.Parse(t, tt.src))
+			var list diag.Diagnostics
+			list.Add(err)
+			if got := codes(list); !slices.Equal(got, tt.errors) {
+				t.Errorf("got errors %v, want %v", got, tt.errors)
+			}
+			if got := codes(info.Warnings); !slices.Equal(got, tt.warnings) {
+				t.Errorf("got warnings %v, want %v", got, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestDuplicateDeclarationLabel(t *testing.T) {
+	src := "\"main\": {\n\tx i32 1\n\tx i32 2\n}"
+	_, err := sema.New(false).Analyze(parsetest.Parse(t, src))
+	var list diag.Diagnostics
+	list.Add(err)
+	if len(list) != 1 {
+		t.Fatalf("got %d diagnostics, want 1", len(list))
+	}
+	d := list[0]
+	if d.Start.Line != 3 || d.Module != "main" {
+		t.Errorf("got diagnostic at line %d of module %q, want line 3 of module \"main\"", d.Start.Line, d.Module)
+	}
+	if len(d.Labels) != 1 || d.Labels[0].Start.Line != 2 {
+		t.Errorf("got labels %+v, want the one at line 2", d.Labels)
+	}
+}
+
+func TestShadowedLabel(t *testing.T) {
+	src := "\"main\": {\n\tf void (a i32) {\n\t\tloop {\n\t\t\ta i32 1;\n\t\t\tbreak;\n\t\t}\n\t}\n}"
+	info, err := sema.New(false).Analyze(parsetest.Parse(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Warnings) != 1 {
+		t.Fatalf("got %d warnings, want 1", len(info.Warnings))
+	}
+	w := info.Warnings[0]
+	if w.Start.Line != 4 || w.Message != "local a shadows the parameter" {
+		t.Errorf("got %q at line %d, want \"local a shadows the parameter\" at line 4", w.Message, w.Start.Line)
+	}
+	if len(w.Labels) != 1 || w.Labels[0].Start.Line != 2 {
+		t.Errorf("got labels %+v, want the one at line 2", w.Labels)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	nodes := parsetest.Parse(t, `"main": { x i32 1 f void (a i32) { b i32 a; } }`)
+	info, err := sema.New(false).Analyze(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := info.Modules["main"]
+	if sym := module.LookupLocal("f"); sym == nil || sym.Kind != sema.FunctionSymbol {
+		t.Errorf("f is %+v, want function", sym)
+	}
+	if sym := module.LookupLocal("x"); sym == nil || sym.Kind != sema.VariableSymbol {
+		t.Errorf("x is %+v, want variable", sym)
+	}
+
+	f := nodes[0].(ast.ModuleDeclaration).Body[1].(*ast.Declaration)
+	scope := info.Functions[f]
+	if sym := scope.Lookup("a"); sym == nil || sym.Kind != sema.ParameterSymbol {
+		t.Errorf("a is %+v, want parameter", sym)
+	}
+	if sym := scope.Lookup("x"); sym == nil || sym.Kind != sema.VariableSymbol {
+		t.Errorf("x is %+v, want variable of the module", sym)
+	}
+}
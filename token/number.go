@@ -0,0 +1,105 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ParseNumber parses the number literal, returning its normalized value
+// and kind, which is Integer or Float.
+//
+// Integers may be decimal, or have the prefix 0x for hexadecimal, 0b for binary
+// and 0o for octal ones, and are normalized to decimal: 0x1F is 31.
+// Floats are decimal, with the fractional part, the exponent or both, like 1.5e-3,
+// and are normalized to the shortest representation: 1.50 is 1.5.
+// Digits of both may be separated by single underscores: 1_000_000.
+func ParseNumber(literal string) (string, Kind, error) {
+	digits, base := literal, 10
+	if len(literal) > 2 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			digits, base = literal[2:], 16
+		case 'b', 'B':
+			digits, base = literal[2:], 2
+		case 'o', 'O':
+			digits, base = literal[2:], 8
+		}
+	}
+	if err := checkSeparators(digits, base); err != nil {
+		return "", Illegal, fmt.Errorf("malformed number %s: %w", literal, err)
+	}
+	digits = strings.ReplaceAll(digits, "_", "")
+
+	if base == 10 && strings.ContainsAny(digits, ".eE") {
+		v, err := strconv.ParseFloat(digits, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return "", Illegal, fmt.Errorf("float %s is out of range", literal)
+		}
+		if err != nil {
+			return "", Illegal, fmt.Errorf("malformed number %s", literal)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), Float, nil
+	}
+
+	v, ok := new(big.Int).SetString(digits, base)
+	if !ok || v.Sign() < 0 {
+		return "", Illegal, fmt.Errorf("malformed number %s", literal)
+	}
+	return v.String(), Integer, nil
+}
+
+// checkSeparators reports an error if an underscore doesn't separate two digits,
+// except the one right after the base prefix.
+func checkSeparators(digits string, base int) error {
+	for i := range len(digits) {
+		if digits[i] != '_' {
+			continue
+		}
+		prefixed := i == 0 && base != 10
+		if !prefixed && (i == 0 || !isDigit(digits[i-1], base)) || i == len(digits)-1 || !isDigit(digits[i+1], base) {
+			return errors.New("'_' must separate digits")
+		}
+	}
+	return nil
+}
+
+func isDigit(c byte, base int) bool {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c-'0') < base
+	case 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		return base == 16
+	}
+	return false
+}
+
+// CheckRange reports an error if the normalized value of the number,
+// returned by ParseNumber, doesn't fit into the numeric type typ of TypesMap.
+func CheckRange(value, typ string) error {
+	if !TypesMap.Is(typ) || len(typ) < 2 || !strings.ContainsRune("iuf", rune(typ[0])) {
+		return fmt.Errorf("%s is not a numeric type", typ)
+	}
+	bits, err := strconv.Atoi(typ[1:])
+	if err != nil {
+		return fmt.Errorf("%s is not a numeric type", typ)
+	}
+
+	switch typ[0] {
+	case 'i':
+		_, err = strconv.ParseInt(value, 10, bits)
+	case 'u':
+		_, err = strconv.ParseUint(value, 10, bits)
+	case 'f':
+		_, err = strconv.ParseFloat(value, bits)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return fmt.Errorf("malformed float %s", value)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s overflows %s", value, typ)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package token_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/token"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		literal string
+		value   string
+		kind    token.Kind
+	}{
+		{"0", "0", token.Integer},
+		{"42", "42", token.Integer},
+		{"007", "7", token.Integer},
+		{"1_000_000", "1000000", token.Integer},
+		{"0x1F", "31", token.Integer},
+		{"0XfF", "255", token.Integer},
+		{"0x_1F", "31", token.Integer},
+		{"0xFFFF_FFFF", "4294967295", token.Integer},
+		{"0b1010", "10", token.Integer},
+		{"0B1_0", "2", token.Integer},
+		{"0o17", "15", token.Integer},
+		{"18446744073709551616", "18446744073709551616", token.Integer},
+		{"1.5", "1.5", token.Float},
+		{"1.50", "1.5", token.Float},
+		{"1_000.000_1", "1000.0001", token.Float},
+		{"1.5e3", "1500", token.Float},
+		{"1E-3", "0.001", token.Float},
+		{"2e+2", "200", token.Float},
+		{"1e21", "1e+21", token.Float},
+	}
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			value, kind, err := token.ParseNumber(tt.literal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.value || kind != tt.kind {
+				t.Errorf("got %s %s, want %s %s", kind, value, tt.kind, tt.value)
+			}
+		})
+	}
+}
+
+func TestParseNumberErrors(t *testing.T) {
+	tests := []struct {
+		literal string
+		message string
+	}{
+		{"1__0", "'_' must separate digits"},
+		{"1_", "'_' must separate digits"},
+		{"1_.5", "'_' must separate digits"},
+		{"1._5", "'_' must separate digits"},
+		{"0x1__F", "'_' must separate digits"},
+		{"0x", "malformed number 0x"},
+		{"0b102", "malformed number 0b102"},
+		{"0o8", "malformed number 0o8"},
+		{"0x1.5", "malformed number 0x1.5"},
+		{"1.2.3", "malformed number 1.2.3"},
+		{"1e", "malformed number 1e"},
+		{"1e999", "float 1e999 is out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			_, kind, err := token.ParseNumber(tt.literal)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("got error %v, want %q", err, tt.message)
+			}
+			if kind != token.Illegal {
+				t.Errorf("got kind %s, want %s", kind, token.Illegal)
+			}
+		})
+	}
+}
+
+func TestCheckRange(t *testing.T) {
+	tests := []struct {
+		value string
+		typ   string
+		err   string
+	}{
+		{"127", "i8", ""},
+		{"128", "i8", "128 overflows i8"},
+		{"-128", "i8", ""},
+		{"-129", "i8", "-129 overflows i8"},
+		{"255", "u8", ""},
+		{"256", "u8", "256 overflows u8"},
+		{"-1", "u32", "-1 overflows u32"},
+		{"18446744073709551615", "u64", ""},
+		{"18446744073709551616", "u64", "18446744073709551616 overflows u64"},
+		{"-9223372036854775808", "i64", ""},
+		{"1e+38", "f32", ""},
+		{"1e+39", "f32", "1e+39 overflows f32"},
+		{"1e+300", "f64", ""},
+		{"1.5", "i32", "1.5 overflows i32"},
+		{"1", "str", "str is not a numeric type"},
+		{"1", "bool", "bool is not a numeric type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value+" "+tt.typ, func(t *testing.T) {
+			err := token.CheckRange(tt.value, tt.typ)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("got error %v, want none", err)
+			case tt.err != "" && (err == nil || err.Error() != tt.err):
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
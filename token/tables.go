@@ -0,0 +1,58 @@
+package token
+
+import "unicode"
+
+// Tables holds the token maps the scanner matches words and symbols against.
+//
+// Scanners of dialects may use their own tables
+// instead of the package-level maps, which are shared by everyone.
+type Tables struct {
+	Keywords        Map
+	Types           Map
+	Instructions    Map
+	Specials        Map
+	BoolConstants   Map
+	Separators      Map
+	BinaryOperators Map
+}
+
+// DefaultTables returns the tables of the language,
+// which refer to the package-level maps, so they must not be modified.
+func DefaultTables() *Tables {
+	return &Tables{
+		Keywords:        KeywordsMap,
+		Types:           TypesMap,
+		Instructions:    BaseInstructionsMap,
+		Specials:        SpecialMap,
+		BoolConstants:   BoolConstantsMap,
+		Separators:      SeparatorsMap,
+		BinaryOperators: BinaryOperatorsMap,
+	}
+}
+
+// IsIdentifier reports whether value is a valid identifier under the tables,
+// meaning value can't be keyword, separator, type,
+// contain hash, left and right paren, slash or start with the digit.
+func (t *Tables) IsIdentifier(value string) bool {
+	switch {
+	case t.Keywords.Is(value), t.Separators.Is(value), t.Types.Is(value):
+		return false
+	}
+	for i, r := range value {
+		// immediately check if first rune is digit
+		if i == 0 && unicode.IsDigit(r) {
+			return false
+		}
+		switch r {
+		case '#':
+			return false
+		case '/':
+			return false
+		case '(':
+			return false
+		case ')':
+			return false
+		}
+	}
+	return true
+}
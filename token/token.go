@@ -1,7 +1,5 @@
 package token
 
-import "unicode"
-
 // Kind represents the token kind.
 type Kind string
 
@@ -9,16 +7,28 @@ type Kind string
 type Map map[string]Kind
 
 // Position is a token position.
+//
+// Line and Column are 1-based, Column is counted in runes
+// (or UTF-16 code units, if the scanner is set so),
+// and Position is the byte offset in the input.
 type Position struct {
 	Line     int `json:"line"`
 	Column   int `json:"column"`
 	Position int `json:"position"`
 }
 
+// Token is a scanned token.
+//
+// Position points to the first character of the token,
+// and End points right after the last one.
+//
+// Value is the normalized value of Integer and Float tokens, see ParseNumber.
 type Token struct {
 	Literal  string    `json:"literal"`
 	Kind     Kind      `json:"kind"`
+	Value    string    `json:"value,omitempty"`
 	Position *Position `json:"position"`
+	End      *Position `json:"end,omitempty"`
 }
 
 const (
@@ -48,6 +58,12 @@ var (
 		"copy":      Keyword,
 		"meta":      Keyword,
 		"array":     Keyword,
+		"if":        Keyword,
+		"else":      Keyword,
+		"loop":      Keyword,
+		"while":     Keyword,
+		"break":     Keyword,
+		"continue":  Keyword,
 	}
 
 	SpecialMap = Map{
@@ -91,13 +107,39 @@ var (
 		"div":    BaseInstruction,
 		"mul":    BaseInstruction,
 		"sub":    BaseInstruction,
+		"cmp":    BaseInstruction,
+		"jmp":    BaseInstruction,
+		"je":     BaseInstruction,
+		"jne":    BaseInstruction,
+		"jl":     BaseInstruction,
+		"jg":     BaseInstruction,
+		"jle":    BaseInstruction,
+		"jge":    BaseInstruction,
+	}
+
+	// JumpInstructionsMap is the subset of base instructions
+	// that take a label instead of values.
+	JumpInstructionsMap = Map{
+		"jmp": BaseInstruction,
+		"je":  BaseInstruction,
+		"jne": BaseInstruction,
+		"jl":  BaseInstruction,
+		"jg":  BaseInstruction,
+		"jle": BaseInstruction,
+		"jge": BaseInstruction,
 	}
 
 	BinaryOperatorsMap = Map{
-		"+": BinaryOperator,
-		"-": BinaryOperator,
-		"/": BinaryOperator,
-		"*": BinaryOperator,
+		"+":  BinaryOperator,
+		"-":  BinaryOperator,
+		"/":  BinaryOperator,
+		"*":  BinaryOperator,
+		"==": BinaryOperator,
+		"!=": BinaryOperator,
+		"<":  BinaryOperator,
+		">":  BinaryOperator,
+		"<=": BinaryOperator,
+		">=": BinaryOperator,
 	}
 
 	BoolConstantsMap = Map{
@@ -110,27 +152,7 @@ var (
 // meaning value can't be keyword, separator, type,
 // contain hash, left and right paren, slash or start with the digit.
 func IsIdentifier(value string) bool {
-	switch {
-	case KeywordsMap.Is(value), SeparatorsMap.Is(value), TypesMap.Is(value):
-		return false
-	}
-	for i, r := range value {
-		// immediately check if first rune is digit
-		if i == 0 && unicode.IsDigit(r) {
-			return false
-		}
-		switch r {
-		case '#':
-			return false
-		case '/':
-			return false
-		case '(':
-			return false
-		case ')':
-			return false
-		}
-	}
-	return true
+	return DefaultTables().IsIdentifier(value)
 }
 
 // NewPosition returns a pointer to new token position.
@@ -160,3 +182,12 @@ func (m Map) Is(value string) bool {
 	_, ok := m[value]
 	return ok
 }
+
+// Longest returns the length of the longest literal in m, in bytes.
+func (m Map) Longest() int {
+	longest := 0
+	for literal := range m {
+		longest = max(longest, len(literal))
+	}
+	return longest
+}
@@ -0,0 +1,459 @@
+package typecheck
+
+import (
+	"fmt"
+	"log"
+	"maps"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/token"
+	"github.com/dywoq/dywoqlang/value"
+)
+
+// Checker verifies that values match the types declared
+// by declarations and function parameters.
+type Checker struct {
+	modules map[string]map[string]*ast.Declaration
+	errors  diag.Diagnostics
+
+	debug bool
+}
+
+type env struct {
+	module   string
+	function *ast.Declaration
+	locals   map[string]Type
+}
+
+// New returns a new pointer to Checker.
+func New(debug bool) *Checker {
+	return &Checker{debug: debug}
+}
+
+// Check checks types of nodes returned by parser.Parser.Parse.
+//
+// The checker expects names to be resolved by the semantic analysis,
+// so undefined names are ignored.
+//
+// Returns diag.Diagnostics if there are type errors.
+func (c *Checker) Check(nodes []ast.Node) error {
+	c.reset()
+	var modules []ast.ModuleDeclaration
+	for _, n := range nodes {
+		if md, ok := n.(ast.ModuleDeclaration); ok {
+			modules = append(modules, c.collect(md)...)
+		}
+	}
+
+	for _, md := range modules {
+		for _, n := range md.Body {
+			if d, ok := n.(*ast.Declaration); ok {
+				c.declaration(env{module: md.Name}, d)
+			}
+		}
+	}
+
+	if len(c.errors) != 0 {
+		return c.errors
+	}
+	return nil
+}
+
+func (c *Checker) collect(md ast.ModuleDeclaration) []ast.ModuleDeclaration {
+	decls := map[string]*ast.Declaration{}
+	c.modules[md.Name] = decls
+	modules := []ast.ModuleDeclaration{md}
+	for _, n := range md.Body {
+		switch n := n.(type) {
+		case *ast.Declaration:
+			decls[n.Name] = n
+		case ast.ModuleDeclaration:
+			modules = append(modules, c.collect(n)...)
+		}
+	}
+	return modules
+}
+
+func (c *Checker) declaration(e env, d *ast.Declaration) {
+	fn, ok := d.Value.(ast.FunctionValue)
+	if !ok {
+		if Type(d.Kind) == Void {
+			c.errorf(e, d, "variable %s can't have type void", d.Name)
+			return
+		}
+		c.assign(e, d.Value, Type(d.Kind), "declaration "+d.Name)
+		return
+	}
+
+	c.outputf("checking %s.%s\n", e.module, d.Name)
+	e.function = d
+	e.locals = map[string]Type{}
+	for _, p := range fn.Parameters {
+		if Type(p.Kind) == Void {
+			c.errorf(e, p, "parameter %s can't have type void", p.Identifier)
+		}
+		e.locals[p.Identifier] = Type(p.Kind)
+	}
+	c.body(e, fn.Body)
+}
+
+// body checks statements of the function body or a nested block.
+func (c *Checker) body(e env, body []ast.Node) {
+	for _, stmt := range body {
+		switch stmt := stmt.(type) {
+		case ast.InstructionCall:
+			c.instruction(e, stmt)
+		case ast.LocalDeclaration:
+			if Type(stmt.Kind) == Void {
+				c.errorf(e, stmt, "local %s can't have type void", stmt.Name)
+			} else {
+				c.assign(e, stmt.Value, Type(stmt.Kind), "local "+stmt.Name)
+			}
+			e.locals[stmt.Name] = Type(stmt.Kind)
+		case ast.IfStatement:
+			c.assign(e, stmt.Condition, Bool, "condition")
+			c.block(e, stmt.Body)
+			c.block(e, stmt.Else)
+		case ast.LoopStatement:
+			if stmt.Condition != nil {
+				c.assign(e, stmt.Condition, Bool, "condition")
+			}
+			c.block(e, stmt.Body)
+		}
+	}
+}
+
+// block checks the nested block,
+// so its locals aren't visible after it.
+func (c *Checker) block(e env, body []ast.Node) {
+	e.locals = maps.Clone(e.locals)
+	c.body(e, body)
+}
+
+func (c *Checker) instruction(e env, ic ast.InstructionCall) {
+	if ic.IsUser {
+		args := make([]ast.Node, len(ic.Arguments))
+		for i, a := range ic.Arguments {
+			args[i] = a.Value
+		}
+		c.call(e, ic, ic.Name, args)
+		return
+	}
+
+	args := ic.Arguments
+	switch ic.Name {
+	case "mov":
+		if len(args) != 2 {
+			c.errorf(e, ic, "mov expects 2 arguments, got %d", len(args))
+			return
+		}
+		c.store(e, args[0].Value, args[1].Value, c.typeOf(e, args[1].Value))
+
+	case "add", "sub", "mul", "div":
+		var x, y ast.Node
+		switch len(args) {
+		case 2:
+			x, y = args[0].Value, args[1].Value
+		case 3:
+			x, y = args[1].Value, args[2].Value
+		default:
+			c.errorf(e, ic, "%s expects 2 or 3 arguments, got %d", ic.Name, len(args))
+			return
+		}
+		result := c.binary(e, ic, ic.Name, c.typeOf(e, x), c.typeOf(e, y))
+		c.store(e, args[0].Value, nil, result)
+
+		// literal operands take the type of the result,
+		// or the type of the destination if both operands are literals.
+		t := result.Elem()
+		if t.IsUntyped() {
+			t = c.destination(e, args[0].Value).Elem()
+		}
+		c.literals(e, x, t)
+		c.literals(e, y, t)
+
+	case "cmp":
+		if len(args) != 2 {
+			c.errorf(e, ic, "cmp expects 2 arguments, got %d", len(args))
+			return
+		}
+		c.compare(e, ic, c.typeOf(e, args[0].Value), c.typeOf(e, args[1].Value))
+
+	case "ret":
+		kind := Type(e.function.Kind)
+		switch {
+		case len(args) > 1:
+			c.errorf(e, ic, "ret expects at most 1 argument, got %d", len(args))
+		case kind == Void && len(args) == 1:
+			c.errorf(e, ic, "void function %s can't return a value", e.function.Name)
+		case kind != Void && len(args) == 0:
+			c.errorf(e, ic, "function %s must return a value of type %s", e.function.Name, kind)
+		case len(args) == 1:
+			c.assign(e, args[0].Value, kind, "return value")
+		}
+
+	default:
+		for _, a := range args {
+			c.typeOf(e, a.Value)
+		}
+	}
+}
+
+// call checks arguments of the call n of the function name,
+// returning the type of the function.
+func (c *Checker) call(e env, n ast.Node, name string, args []ast.Node) Type {
+	d, ok := c.modules[e.module][name]
+	if !ok {
+		return Invalid
+	}
+	fn, ok := d.Value.(ast.FunctionValue)
+	if !ok {
+		return Invalid
+	}
+	if len(args) != len(fn.Parameters) {
+		c.errorf(e, n, "function %s expects %d arguments, got %d", name, len(fn.Parameters), len(args))
+		return Type(d.Kind)
+	}
+	for i, a := range args {
+		p := fn.Parameters[i]
+		c.assign(e, a, Type(p.Kind), fmt.Sprintf("argument %s of %s", p.Identifier, name))
+	}
+	return Type(d.Kind)
+}
+
+// binary returns the result type of the arithmetic instruction name
+// applied to x and y, reporting an error at n if the operands are incompatible.
+//
+// n is either the instruction call or the binary expression.
+func (c *Checker) binary(e env, n ast.Node, name string, x, y Type) Type {
+	what := name
+	if be, ok := n.(ast.BinaryExpression); ok {
+		what = "operator " + be.Operator
+	}
+	switch {
+	case x == Invalid || y == Invalid:
+		return Invalid
+	case x == Str || y == Str:
+		if name != "add" || x != y {
+			c.errorf(e, n, "%s can't be applied to %s and %s", what, describe(x), describe(y))
+			return Invalid
+		}
+		return Str
+	case x.IsArray() || y.IsArray():
+		if name != "add" || !x.IsArray() || !y.IsArray() {
+			c.errorf(e, n, "%s can't be applied to %s and %s", what, describe(x), describe(y))
+			return Invalid
+		}
+		elem := c.binary(e, n, name, x.Elem(), y.Elem())
+		if elem == Invalid {
+			return Invalid
+		}
+		return ArrayOf(elem)
+	case !x.IsNumeric() || !y.IsNumeric():
+		c.errorf(e, n, "%s can't be applied to %s and %s", what, describe(x), describe(y))
+		return Invalid
+	case x == y:
+		return x
+	case x.IsUntyped() && y.IsUntyped():
+		return UntypedFloat
+	case x.IsUntyped() && Assignable(x, y):
+		return y
+	case y.IsUntyped() && Assignable(y, x):
+		return x
+	}
+	c.errorf(e, n, "%s has mismatched operands %s and %s", what, describe(x), describe(y))
+	return Invalid
+}
+
+// compare checks that the values of types x and y can be compared at n.
+// Numbers are compared with numbers, strings with strings and bools with bools.
+//
+// n is either the cmp instruction call or the binary expression.
+func (c *Checker) compare(e env, n ast.Node, x, y Type) {
+	what := "cmp"
+	if be, ok := n.(ast.BinaryExpression); ok {
+		what = "operator " + be.Operator
+	}
+	switch {
+	case x == Invalid || y == Invalid:
+	case x.IsNumeric() && y.IsNumeric():
+		if !Assignable(x, y) && !Assignable(y, x) {
+			c.errorf(e, n, "%s has mismatched operands %s and %s", what, describe(x), describe(y))
+		}
+	case x != y || (x != Str && x != Bool):
+		c.errorf(e, n, "%s can't compare %s and %s", what, describe(x), describe(y))
+	}
+}
+
+// store checks that the value of type t can be stored in dst.
+//
+// src is the stored node, if any, used to check literal ranges.
+func (c *Checker) store(e env, dst ast.Node, src ast.Node, t Type) {
+	target := c.destination(e, dst)
+	if target == Invalid {
+		return
+	}
+	v := dst.(ast.Value)
+	if src != nil {
+		c.assign(e, src, target, v.Value)
+		return
+	}
+	if !Assignable(t, target) {
+		c.errorf(e, dst, "can't store %s in %s of type %s", describe(t), v.Value, target)
+	}
+}
+
+// destination returns the type of the identifier dst stored by an instruction,
+// or Invalid if dst isn't an identifier or its type is unknown.
+func (c *Checker) destination(e env, dst ast.Node) Type {
+	v, ok := dst.(ast.Value)
+	if !ok || v.Kind != token.Identifier {
+		return Invalid
+	}
+	return c.lookup(e, v.Value)
+}
+
+// assign checks that n can be stored in the destination of type target,
+// including ranges of integer and float literals.
+func (c *Checker) assign(e env, n ast.Node, target Type, what string) {
+	t := c.typeOf(e, n)
+	if target == Void {
+		c.errorf(e, n, "%s can't have type void", what)
+		return
+	}
+	if !Assignable(t, target) {
+		c.errorf(e, n, "can't use %s as %s of type %s", describe(t), what, target)
+		return
+	}
+	c.literals(e, n, target.Elem())
+}
+
+// literals checks that integer and float literals of n fit into t.
+func (c *Checker) literals(e env, n ast.Node, t Type) {
+	switch n := n.(type) {
+	case ast.Value:
+		if n.ValueNode != nil {
+			c.literals(e, n.ValueNode, t)
+			return
+		}
+		if n.Kind != token.Integer && n.Kind != token.Float {
+			return
+		}
+		if err := CheckLiteral(n.Value, t); err != nil {
+			c.errorf(e, n, "%s", err)
+		}
+	case ast.ArrayValue:
+		for _, el := range n.Elements {
+			c.literals(e, el.Value, t)
+		}
+	case ast.BinaryExpression:
+		if value.IsComparison(n.Operator) {
+			// operands of comparisons aren't stored, so they can be of any type.
+			return
+		}
+		for _, child := range n.Children {
+			c.literals(e, child, t)
+		}
+	}
+}
+
+func (c *Checker) typeOf(e env, n ast.Node) Type {
+	switch n := n.(type) {
+	case ast.Value:
+		if n.ValueNode != nil {
+			return c.typeOf(e, n.ValueNode)
+		}
+		switch n.Kind {
+		case token.Integer:
+			return UntypedInt
+		case token.Float:
+			return UntypedFloat
+		case token.String:
+			return Str
+		case token.BoolConstant:
+			return Bool
+		case token.Identifier:
+			return c.lookup(e, n.Value)
+		}
+
+	case ast.BinaryExpression:
+		if len(n.Children) != 2 {
+			return Invalid
+		}
+		x, y := c.typeOf(e, n.Children[0]), c.typeOf(e, n.Children[1])
+		if value.IsComparison(n.Operator) {
+			c.compare(e, n, x, y)
+			return Bool
+		}
+		return c.binary(e, n, value.Instruction(n.Operator), x, y)
+
+	case ast.CallExpression:
+		t := c.call(e, n, n.Name, n.Arguments)
+		if t == Void {
+			c.errorf(e, n, "void function %s can't be used as a value", n.Name)
+			return Invalid
+		}
+		return t
+
+	case ast.ArrayValue:
+		elem := Invalid
+		for i, el := range n.Elements {
+			t := c.typeOf(e, el.Value)
+			switch {
+			case i == 0, elem == Invalid:
+				elem = t
+			case t == elem, t == Invalid:
+			case elem.IsUntyped() && Assignable(elem, t):
+				elem = t
+			case t.IsUntyped() && Assignable(t, elem):
+			default:
+				c.errorf(e, el.Value, "array elements have mismatched types %s and %s", describe(elem), describe(t))
+				return Invalid
+			}
+		}
+		return ArrayOf(elem)
+	}
+	return Invalid
+}
+
+func (c *Checker) lookup(e env, name string) Type {
+	if t, ok := e.locals[name]; ok {
+		return t
+	}
+	if d, ok := c.modules[e.module][name]; ok {
+		if _, ok := d.Value.(ast.FunctionValue); !ok {
+			return Type(d.Kind)
+		}
+	}
+	return Invalid
+}
+
+func describe(t Type) string {
+	switch t {
+	case UntypedInt:
+		return "integer literal"
+	case UntypedFloat:
+		return "float literal"
+	}
+	return string(t)
+}
+
+func (c *Checker) errorf(e env, n ast.Node, format string, v ...any) {
+	var function string
+	if e.function != nil {
+		function = e.function.Name
+	}
+	c.errors = append(c.errors, diag.Errorf(diag.CodeType, format, v...).Span(n).In(e.module, function))
+}
+
+func (c *Checker) outputf(format string, v ...any) {
+	if c.debug {
+		log.Printf(format, v...)
+	}
+}
+
+func (c *Checker) reset() {
+	c.modules = map[string]map[string]*ast.Declaration{}
+	c.errors = nil
+}
@@ -0,0 +1,211 @@
+package typecheck_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/diag"
+	"github.com/dywoq/dywoqlang/internal/parsetest"
+	"github.com/dywoq/dywoqlang/typecheck"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		messages []string
+	}{
+		{
+			name: "valid",
+			src: `"main": {
+				a i8 -128
+				b u64 18446744073709551615
+				c f32 1.5e3
+				d i32 0x7fff_ffff
+				s str "s"
+				f f64 (x i32, y f64) {
+					z f64 y * 2.0;
+					ret z + 1;
+				}
+				main void () {
+					stdout [f](d, 2.5), a - 1, s + "t", array(1, 2) + array(3);
+					if c < 2.0 {
+						stdout s;
+					}
+				}
+			}`,
+		},
+		{
+			name:     "signed overflow",
+			src:      `"main": { x i8 128 }`,
+			messages: []string{"128 overflows i8"},
+		},
+		{
+			name:     "signed underflow",
+			src:      `"main": { x i8 -129 }`,
+			messages: []string{"-129 overflows i8"},
+		},
+		{
+			name:     "negative unsigned",
+			src:      `"main": { x u8 -1 }`,
+			messages: []string{"-1 overflows u8"},
+		},
+		{
+			name:     "hexadecimal overflow",
+			src:      `"main": { x u8 0x1FF }`,
+			messages: []string{"511 overflows u8"},
+		},
+		{
+			name:     "overflow of local",
+			src:      `"main": { main void () { x i16 70000; } }`,
+			messages: []string{"70000 overflows i16"},
+		},
+		{
+			name:     "overflow in add",
+			src:      `"main": { main void () { x i8 0; add x, x, 300; } }`,
+			messages: []string{"300 overflows i8"},
+		},
+		{
+			name:     "overflow in two-operand add",
+			src:      `"main": { main void () { x u8 0; add x, 256; } }`,
+			messages: []string{"256 overflows u8"},
+		},
+		{
+			name:     "overflow in sub of literals",
+			src:      `"main": { main void () { x u8 0; sub x, 1, -1; } }`,
+			messages: []string{"-1 overflows u8"},
+		},
+		{
+			name: "literals in range in add and sub",
+			src:  `"main": { main void () { x i8 0; add x, x, 127; sub x, -128, 1; } }`,
+		},
+		{
+			name:     "float as integer",
+			src:      `"main": { x i32 1.5 }`,
+			messages: []string{"can't use float literal as declaration x of type i32"},
+		},
+		{
+			name:     "string as integer",
+			src:      `"main": { x i32 "s" }`,
+			messages: []string{"can't use str as declaration x of type i32"},
+		},
+		{
+			name: "arrays of the element type",
+			src: `"main": {
+				xs i32 array(1, 2, 3)
+				f i32 () {
+					a i32 0;
+					add a, array(1), array(2);
+					ret a;
+				}
+			}`,
+		},
+		{
+			name:     "array of other element type",
+			src:      `"main": { x i32 array("a", "b") }`,
+			messages: []string{"can't use []str as declaration x of type i32"},
+		},
+		{
+			name:     "array element overflow",
+			src:      `"main": { x u8 array(1, 256) }`,
+			messages: []string{"256 overflows u8"},
+		},
+		{
+			name:     "mismatched array elements",
+			src:      `"main": { main void () { stdout array(1, "a"); } }`,
+			messages: []string{"array elements have mismatched types integer literal and str"},
+		},
+		{
+			name:     "mismatched operands",
+			src:      `"main": { a i32 1 b i64 2 main void () { stdout a + b; } }`,
+			messages: []string{"operator + has mismatched operands i32 and i64"},
+		},
+		{
+			name:     "string arithmetic",
+			src:      `"main": { main void () { stdout "s" * 2; } }`,
+			messages: []string{"operator * can't be applied to str and integer literal"},
+		},
+		{
+			name:     "comparison of string and number",
+			src:      `"main": { main void () { cmp "a", 1; } }`,
+			messages: []string{"cmp can't compare str and integer literal"},
+		},
+		{
+			name:     "non-bool condition",
+			src:      `"main": { main void () { if 1 + 2 { } } }`,
+			messages: []string{"can't use integer literal as condition of type bool"},
+		},
+		{
+			name:     "void variable",
+			src:      `"main": { x void 1 }`,
+			messages: []string{"variable x can't have type void"},
+		},
+		{
+			name:     "void function as value",
+			src:      `"main": { f void () { } main void () { stdout [f](); } }`,
+			messages: []string{"void function f can't be used as a value"},
+		},
+		{
+			name:     "missing return value",
+			src:      `"main": { f i32 () { ret; } }`,
+			messages: []string{"function f must return a value of type i32"},
+		},
+		{
+			name:     "return value of void function",
+			src:      `"main": { f void () { ret 1; } }`,
+			messages: []string{"void function f can't return a value"},
+		},
+		{
+			name:     "argument count",
+			src:      `"main": { f void (x i32) { } main void () { [f] 1, 2; } }`,
+			messages: []string{"function f expects 1 arguments, got 2"},
+		},
+		{
+			name:     "argument type",
+			src:      `"main": { f void (x i32) { } main void () { [f] "s"; } }`,
+			messages: []string{"can't use str as argument x of f of type i32"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list diag.Diagnostics
+			list.Add(typecheck.New(false).Check(parsetest.Parse(t, tt.src)))
+			var messages []string
+			for _, d := range list {
+				if d.Code != diag.CodeType {
+					t.Errorf("got code %s, want %s", d.Code, diag.CodeType)
+				}
+				messages = append(messages, d.Message)
+			}
+			if !slices.Equal(messages, tt.messages) {
+				t.Errorf("got errors %q, want %q", messages, tt.messages)
+			}
+		})
+	}
+}
+
+func TestAssignable(t *testing.T) {
+	tests := []struct {
+		src, dst typecheck.Type
+		want     bool
+	}{
+		{typecheck.I32, typecheck.I32, true},
+		{typecheck.UntypedInt, "u8", true},
+		{typecheck.UntypedInt, typecheck.F64, true},
+		{typecheck.UntypedFloat, typecheck.F64, true},
+		{typecheck.UntypedFloat, typecheck.I32, false},
+		{typecheck.I32, "i64", false},
+		{typecheck.Str, typecheck.Bool, false},
+		{typecheck.ArrayOf(typecheck.UntypedInt), typecheck.ArrayOf(typecheck.I32), true},
+		{typecheck.ArrayOf(typecheck.I32), typecheck.ArrayOf(typecheck.Str), false},
+		{typecheck.ArrayOf(typecheck.I32), typecheck.I32, true},
+		{typecheck.ArrayOf(typecheck.Str), typecheck.I32, false},
+		{typecheck.I32, typecheck.ArrayOf(typecheck.I32), false},
+		{typecheck.Invalid, typecheck.Str, true},
+	}
+	for _, tt := range tests {
+		if got := typecheck.Assignable(tt.src, tt.dst); got != tt.want {
+			t.Errorf("Assignable(%q, %q) = %v, want %v", tt.src, tt.dst, got, tt.want)
+		}
+	}
+}
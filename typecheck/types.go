@@ -0,0 +1,124 @@
+package typecheck
+
+import (
+	"strings"
+
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// Type is a type name from token.TypesMap.
+// Array types are prefixed with "[]", like "[]i32".
+type Type string
+
+const (
+	// Invalid is a type of the expression which type can't be determined.
+	// It's compatible with any type to avoid cascading errors.
+	Invalid Type = ""
+
+	// UntypedInt is a type of integer literals
+	// before they're assigned to the typed destination.
+	UntypedInt Type = "untyped int"
+
+	// UntypedFloat is a type of float literals
+	// before they're assigned to the typed destination.
+	UntypedFloat Type = "untyped float"
+
+	Str  Type = "str"
+	Bool Type = "bool"
+	Void Type = "void"
+	I32  Type = "i32"
+	F64  Type = "f64"
+)
+
+var integerBits = map[Type]int{
+	"i8": 8, "i16": 16, "i32": 32, "i64": 64,
+	"u8": 8, "u16": 16, "u32": 32, "u64": 64,
+}
+
+var floatBits = map[Type]int{
+	"f32": 32, "f64": 64,
+}
+
+// ArrayOf returns the array type with elements of t.
+func ArrayOf(t Type) Type {
+	return "[]" + t
+}
+
+// IsArray reports whether t is an array type.
+func (t Type) IsArray() bool {
+	return strings.HasPrefix(string(t), "[]")
+}
+
+// Elem returns the element type of the array type t.
+// If t is not an array, it returns t.
+func (t Type) Elem() Type {
+	return Type(strings.TrimPrefix(string(t), "[]"))
+}
+
+// IsInteger reports whether t is a signed or unsigned integer type, or UntypedInt.
+func (t Type) IsInteger() bool {
+	_, ok := integerBits[t]
+	return ok || t == UntypedInt
+}
+
+// IsFloat reports whether t is a float type, or UntypedFloat.
+func (t Type) IsFloat() bool {
+	_, ok := floatBits[t]
+	return ok || t == UntypedFloat
+}
+
+// IsNumeric reports whether t is an integer or float type.
+func (t Type) IsNumeric() bool {
+	return t.IsInteger() || t.IsFloat()
+}
+
+// IsUntyped reports whether t is UntypedInt or UntypedFloat.
+func (t Type) IsUntyped() bool {
+	return t == UntypedInt || t == UntypedFloat
+}
+
+// Default returns the type given to untyped values
+// when there's no typed destination: i32 for integers and f64 for floats.
+func (t Type) Default() Type {
+	switch t {
+	case UntypedInt:
+		return I32
+	case UntypedFloat:
+		return F64
+	}
+	if t.IsArray() {
+		return ArrayOf(t.Elem().Default())
+	}
+	return t
+}
+
+// Assignable reports whether the value of type src can be stored in dst.
+//
+// Since the language has no separate syntax for array types,
+// an array of T is also assignable to T. This is intended:
+// declarations and locals of type T hold arrays of T,
+// like the result of adding two arrays with add.
+func Assignable(src, dst Type) bool {
+	switch {
+	case src == Invalid, dst == Invalid, src == dst:
+		return true
+	case src == UntypedInt:
+		return dst.IsInteger() || dst.IsFloat()
+	case src == UntypedFloat:
+		return dst.IsFloat()
+	case src.IsArray() && dst.IsArray():
+		return Assignable(src.Elem(), dst.Elem())
+	case src.IsArray():
+		return Assignable(src.Elem(), dst)
+	}
+	return false
+}
+
+// CheckLiteral reports an error if the literal of the integer or float kind
+// doesn't fit into the type t.
+func CheckLiteral(literal string, t Type) error {
+	if !t.IsInteger() && !t.IsFloat() {
+		return nil
+	}
+	return token.CheckRange(literal, string(t))
+}
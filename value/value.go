@@ -0,0 +1,328 @@
+package value
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/token"
+)
+
+// Kind represents the kind of runtime value.
+type Kind string
+
+const (
+	Nil     Kind = "nil"
+	Integer Kind = "integer"
+	Float   Kind = "float"
+	String  Kind = "string"
+	Bool    Kind = "bool"
+	Array   Kind = "array"
+)
+
+// Value is a runtime value produced by evaluating the program.
+//
+// Only the field that corresponds to Kind is meaningful.
+type Value struct {
+	Kind     Kind    `json:"kind"`
+	Int      int64   `json:"int,omitempty"`
+	Float    float64 `json:"float,omitempty"`
+	Str      string  `json:"str,omitempty"`
+	Bool     bool    `json:"bool,omitempty"`
+	Elements []Value `json:"elements,omitempty"`
+}
+
+// ErrDivisionByZero is returned by Div if the divisor is integer zero.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// NewInt returns a new integer value.
+func NewInt(v int64) Value {
+	return Value{Kind: Integer, Int: v}
+}
+
+// NewFloat returns a new float value.
+func NewFloat(v float64) Value {
+	return Value{Kind: Float, Float: v}
+}
+
+// NewString returns a new string value.
+func NewString(v string) Value {
+	return Value{Kind: String, Str: v}
+}
+
+// NewBool returns a new bool value.
+func NewBool(v bool) Value {
+	return Value{Kind: Bool, Bool: v}
+}
+
+// NewArray returns a new array value holding elements.
+func NewArray(elements []Value) Value {
+	return Value{Kind: Array, Elements: elements}
+}
+
+// FromLiteral converts the literal of the given token kind into the value.
+//
+// Returns an error if the kind can't be represented as a value,
+// or the literal is malformed.
+func FromLiteral(kind token.Kind, literal string) (Value, error) {
+	switch kind {
+	case token.Integer:
+		v, err := strconv.ParseInt(literal, 10, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("malformed integer %q: %w", literal, err)
+		}
+		return NewInt(v), nil
+	case token.Float:
+		v, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("malformed float %q: %w", literal, err)
+		}
+		return NewFloat(v), nil
+	case token.String:
+		return NewString(literal), nil
+	case token.BoolConstant:
+		v, err := strconv.ParseBool(literal)
+		if err != nil {
+			return Value{}, fmt.Errorf("malformed bool %q: %w", literal, err)
+		}
+		return NewBool(v), nil
+	case token.Special:
+		if literal == "nil" {
+			return Value{Kind: Nil}, nil
+		}
+	}
+	return Value{}, fmt.Errorf("can't convert %s %q into the value", kind, literal)
+}
+
+// Copy returns a deep copy of v.
+func (v Value) Copy() Value {
+	if v.Kind != Array {
+		return v
+	}
+	elements := make([]Value, len(v.Elements))
+	for i, e := range v.Elements {
+		elements[i] = e.Copy()
+	}
+	return NewArray(elements)
+}
+
+// IsNumeric reports whether v is an integer or a float.
+func (v Value) IsNumeric() bool {
+	return v.Kind == Integer || v.Kind == Float
+}
+
+// String returns the human-readable presentation of v,
+// used by stdout and stderr instructions.
+func (v Value) String() string {
+	switch v.Kind {
+	case Integer:
+		return strconv.FormatInt(v.Int, 10)
+	case Float:
+		return strconv.FormatFloat(v.Float, 'g', -1, 64)
+	case String:
+		return v.Str
+	case Bool:
+		return strconv.FormatBool(v.Bool)
+	case Array:
+		parts := make([]string, len(v.Elements))
+		for i, e := range v.Elements {
+			parts[i] = e.String()
+		}
+		return "array(" + strings.Join(parts, ", ") + ")"
+	}
+	return "nil"
+}
+
+// Add returns x+y.
+//
+// Strings and arrays are concatenated,
+// integers and floats are summed.
+func Add(x, y Value) (Value, error) {
+	switch {
+	case x.Kind == String && y.Kind == String:
+		return NewString(x.Str + y.Str), nil
+	case x.Kind == Array && y.Kind == Array:
+		elements := make([]Value, 0, len(x.Elements)+len(y.Elements))
+		elements = append(elements, x.Copy().Elements...)
+		elements = append(elements, y.Copy().Elements...)
+		return NewArray(elements), nil
+	}
+	return arithmetic("add", x, y,
+		func(a, b int64) (int64, error) { return a + b, nil },
+		func(a, b float64) float64 { return a + b },
+	)
+}
+
+// Sub returns x-y.
+func Sub(x, y Value) (Value, error) {
+	return arithmetic("sub", x, y,
+		func(a, b int64) (int64, error) { return a - b, nil },
+		func(a, b float64) float64 { return a - b },
+	)
+}
+
+// Mul returns x*y.
+func Mul(x, y Value) (Value, error) {
+	return arithmetic("mul", x, y,
+		func(a, b int64) (int64, error) { return a * b, nil },
+		func(a, b float64) float64 { return a * b },
+	)
+}
+
+// Div returns x/y.
+//
+// Returns ErrDivisionByZero if both values are integers and y is zero.
+func Div(x, y Value) (Value, error) {
+	return arithmetic("div", x, y,
+		func(a, b int64) (int64, error) {
+			if b == 0 {
+				return 0, ErrDivisionByZero
+			}
+			return a / b, nil
+		},
+		func(a, b float64) float64 { return a / b },
+	)
+}
+
+// Apply applies the arithmetic instruction name (add, sub, mul or div) to x and y.
+func Apply(name string, x, y Value) (Value, error) {
+	switch name {
+	case "add":
+		return Add(x, y)
+	case "sub":
+		return Sub(x, y)
+	case "mul":
+		return Mul(x, y)
+	case "div":
+		return Div(x, y)
+	}
+	return Value{}, fmt.Errorf("unknown arithmetic instruction: %s", name)
+}
+
+// Instruction returns the arithmetic instruction name for the binary operator,
+// like add for +.
+// Returns an empty string if the operator is unknown.
+func Instruction(operator string) string {
+	switch operator {
+	case "+":
+		return "add"
+	case "-":
+		return "sub"
+	case "*":
+		return "mul"
+	case "/":
+		return "div"
+	}
+	return ""
+}
+
+// Binary applies the binary operator to x and y.
+//
+// Arithmetic operators (+, -, * and /) are applied like their instructions,
+// and comparison operators (==, !=, <, >, <= and >=) return bools,
+// comparing values like Compare.
+func Binary(operator string, x, y Value) (Value, error) {
+	if name := Instruction(operator); name != "" {
+		return Apply(name, x, y)
+	}
+	jump, ok := comparisons[operator]
+	if !ok {
+		return Value{}, fmt.Errorf("unknown binary operator: %s", operator)
+	}
+	result, err := Compare(x, y)
+	if err != nil {
+		return Value{}, err
+	}
+	holds, err := Jumps(jump, result)
+	if err != nil {
+		return Value{}, err
+	}
+	return NewBool(holds), nil
+}
+
+// IsComparison reports whether operator is a comparison operator, such as ==.
+func IsComparison(operator string) bool {
+	_, ok := comparisons[operator]
+	return ok
+}
+
+// comparisons maps comparison operators
+// to the jump instructions taken when they hold.
+var comparisons = map[string]string{
+	"==": "je",
+	"!=": "jne",
+	"<":  "jl",
+	">":  "jg",
+	"<=": "jle",
+	">=": "jge",
+}
+
+// Compare compares x and y and returns -1 if x is less than y,
+// 0 if they're equal and +1 if x is greater than y.
+//
+// Integers and floats compare by value, strings lexicographically,
+// and false is less than true. Other kinds can't be compared.
+func Compare(x, y Value) (int, error) {
+	switch {
+	case x.Kind == Integer && y.Kind == Integer:
+		return cmp.Compare(x.Int, y.Int), nil
+	case x.IsNumeric() && y.IsNumeric():
+		return cmp.Compare(x.toFloat(), y.toFloat()), nil
+	case x.Kind == String && y.Kind == String:
+		return strings.Compare(x.Str, y.Str), nil
+	case x.Kind == Bool && y.Kind == Bool:
+		switch {
+		case x.Bool == y.Bool:
+			return 0, nil
+		case y.Bool:
+			return -1, nil
+		}
+		return 1, nil
+	}
+	return 0, fmt.Errorf("can't compare %s and %s", x.Kind, y.Kind)
+}
+
+// Jumps reports whether the jump instruction name (jmp, je, jne, jl, jg, jle or jge)
+// jumps after the comparison that returned result.
+func Jumps(name string, result int) (bool, error) {
+	switch name {
+	case "jmp":
+		return true, nil
+	case "je":
+		return result == 0, nil
+	case "jne":
+		return result != 0, nil
+	case "jl":
+		return result < 0, nil
+	case "jg":
+		return result > 0, nil
+	case "jle":
+		return result <= 0, nil
+	case "jge":
+		return result >= 0, nil
+	}
+	return false, fmt.Errorf("unknown jump instruction: %s", name)
+}
+
+func arithmetic(name string, x, y Value, ints func(a, b int64) (int64, error), floats func(a, b float64) float64) (Value, error) {
+	switch {
+	case x.Kind == Integer && y.Kind == Integer:
+		v, err := ints(x.Int, y.Int)
+		if err != nil {
+			return Value{}, err
+		}
+		return NewInt(v), nil
+	case x.IsNumeric() && y.IsNumeric():
+		return NewFloat(floats(x.toFloat(), y.toFloat())), nil
+	}
+	return Value{}, fmt.Errorf("%s: unsupported operands %s and %s", name, x.Kind, y.Kind)
+}
+
+func (v Value) toFloat() float64 {
+	if v.Kind == Integer {
+		return float64(v.Int)
+	}
+	return v.Float
+}
@@ -0,0 +1,15 @@
+package vm
+
+import "errors"
+
+var (
+	// ErrStackOverflow is returned by the virtual machine if there are too many nested calls.
+	ErrStackOverflow = errors.New("stack overflow")
+
+	// ErrStackUnderflow is returned by the virtual machine if the bytecode pops from the empty stack.
+	ErrStackUnderflow = errors.New("stack underflow")
+
+	// ErrNoComparison is returned by the virtual machine if the conditional jump
+	// is executed before any comparison in the function.
+	ErrNoComparison = errors.New("conditional jump without cmp")
+)
@@ -0,0 +1,393 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dywoq/dywoqlang/compiler"
+	"github.com/dywoq/dywoqlang/value"
+)
+
+// MaxFrames is the maximum depth of nested calls.
+const MaxFrames = 1024
+
+// VM is a stack-based virtual machine executing compiled programs.
+type VM struct {
+	program *compiler.Program
+
+	stack  []value.Value
+	frames []*frame
+
+	globals     []value.Value
+	initialized []bool
+
+	stdout io.Writer
+	stderr io.Writer
+
+	debug bool
+}
+
+type frame struct {
+	function *compiler.Function
+	ip       int
+	base     int
+
+	// compared reports whether OpCmp was executed,
+	// and result holds the result of the last comparison.
+	compared bool
+	result   int
+}
+
+// New returns a new pointer to VM,
+// which writes to os.Stdout and os.Stderr.
+func New(debug bool) *VM {
+	return &VM{
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		debug:  debug,
+	}
+}
+
+// SetOutput sets the writers used by stdout and stderr instructions.
+func (vm *VM) SetOutput(stdout, stderr io.Writer) {
+	vm.stdout = stdout
+	vm.stderr = stderr
+}
+
+// Run executes the entry function of p,
+// returning the value it returned.
+//
+// p is validated with compiler.Program.Validate first,
+// so malformed bytecode is reported instead of crashing the machine.
+func (vm *VM) Run(p *compiler.Program) (value.Value, error) {
+	if err := p.Validate(); err != nil {
+		return value.Value{}, err
+	}
+	vm.reset(p)
+	return vm.call(p.Entry, nil)
+}
+
+// call executes the function fn with args until it returns.
+func (vm *VM) call(fn int, args []value.Value) (value.Value, error) {
+	vm.stack = append(vm.stack, args...)
+	depth := len(vm.frames)
+	if err := vm.enter(fn, len(args)); err != nil {
+		return value.Value{}, err
+	}
+	if err := vm.execute(depth); err != nil {
+		return value.Value{}, err
+	}
+	return vm.pop()
+}
+
+func (vm *VM) enter(fn int, argc int) error {
+	if len(vm.frames) >= MaxFrames {
+		return ErrStackOverflow
+	}
+	if fn < 0 || fn >= len(vm.program.Functions) {
+		return fmt.Errorf("undefined function %d", fn)
+	}
+	f := vm.program.Functions[fn]
+	if f.Arity != argc {
+		return fmt.Errorf("function %s expects %d arguments, got %d", f.Name, f.Arity, argc)
+	}
+	base := len(vm.stack) - argc
+	for range f.NumLocals - argc {
+		vm.stack = append(vm.stack, value.Value{Kind: value.Nil})
+	}
+	vm.outputf("entering %s.%s\n", f.Module, f.Name)
+	vm.frames = append(vm.frames, &frame{function: f, base: base})
+	return nil
+}
+
+// execute runs the frames until there are only depth frames left.
+func (vm *VM) execute(depth int) error {
+	for len(vm.frames) > depth {
+		f := vm.frames[len(vm.frames)-1]
+		code := f.function.Code
+		if f.ip >= len(code) {
+			return fmt.Errorf("function %s ended without return", f.function.Name)
+		}
+		op := compiler.Opcode(code[f.ip])
+		f.ip++
+
+		if err := vm.step(f, op, code); err != nil {
+			return fmt.Errorf("in function %s: %w", f.function.Name, err)
+		}
+	}
+	return nil
+}
+
+func (vm *VM) step(f *frame, op compiler.Opcode, code []byte) error {
+	switch op {
+	case compiler.OpConst:
+		idx := vm.operand16(f, code)
+		vm.push(vm.program.Constants[idx].Copy())
+
+	case compiler.OpLoadLocal:
+		vm.push(vm.stack[f.base+vm.operand16(f, code)])
+
+	case compiler.OpStoreLocal:
+		slot := vm.operand16(f, code)
+		v, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.stack[f.base+slot] = v
+
+	case compiler.OpLoadGlobal:
+		v, err := vm.global(vm.operand16(f, code))
+		if err != nil {
+			return err
+		}
+		vm.push(v)
+
+	case compiler.OpStoreGlobal:
+		idx := vm.operand16(f, code)
+		v, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.globals[idx] = v
+		vm.initialized[idx] = true
+
+	case compiler.OpAdd, compiler.OpSub, compiler.OpMul, compiler.OpDiv:
+		y, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		x, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		result, err := arithmetic(op, x, y)
+		if err != nil {
+			return err
+		}
+		vm.push(result)
+
+	case compiler.OpEqual, compiler.OpNotEqual, compiler.OpLess,
+		compiler.OpGreater, compiler.OpLessEqual, compiler.OpGreaterEqual:
+		y, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		x, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		result, err := value.Binary(operators[op], x, y)
+		if err != nil {
+			return err
+		}
+		vm.push(result)
+
+	case compiler.OpArray:
+		n := vm.operand16(f, code)
+		if n > vm.available() {
+			return ErrStackUnderflow
+		}
+		elements := make([]value.Value, n)
+		copy(elements, vm.stack[len(vm.stack)-n:])
+		vm.stack = vm.stack[:len(vm.stack)-n]
+		vm.push(value.NewArray(elements))
+
+	case compiler.OpCopy:
+		v, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.push(v.Copy())
+
+	case compiler.OpCall:
+		fn := vm.operand16(f, code)
+		argc := int(code[f.ip])
+		f.ip++
+		if argc > vm.available() {
+			return ErrStackUnderflow
+		}
+		return vm.enter(fn, argc)
+
+	case compiler.OpReturn, compiler.OpReturnNil:
+		result := value.Value{Kind: value.Nil}
+		if op == compiler.OpReturn {
+			v, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			result = v
+		}
+		vm.stack = vm.stack[:f.base]
+		vm.frames = vm.frames[:len(vm.frames)-1]
+		vm.push(result)
+
+	case compiler.OpPop:
+		_, err := vm.pop()
+		return err
+
+	case compiler.OpStdout, compiler.OpStderr:
+		n := int(code[f.ip])
+		f.ip++
+		if n > vm.available() {
+			return ErrStackUnderflow
+		}
+		parts := make([]string, n)
+		for i, v := range vm.stack[len(vm.stack)-n:] {
+			parts[i] = v.String()
+		}
+		vm.stack = vm.stack[:len(vm.stack)-n]
+		w := vm.stdout
+		if op == compiler.OpStderr {
+			w = vm.stderr
+		}
+		_, err := fmt.Fprintln(w, strings.Join(parts, " "))
+		return err
+
+	case compiler.OpCmp:
+		y, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		x, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		result, err := value.Compare(x, y)
+		if err != nil {
+			return err
+		}
+		f.compared, f.result = true, result
+
+	case compiler.OpJump:
+		f.ip = vm.operand16(f, code)
+
+	case compiler.OpJumpEqual, compiler.OpJumpNotEqual, compiler.OpJumpLess,
+		compiler.OpJumpGreater, compiler.OpJumpLessEqual, compiler.OpJumpGreaterEqual:
+		target := vm.operand16(f, code)
+		if !f.compared {
+			return ErrNoComparison
+		}
+		if jumps(op, f.result) {
+			f.ip = target
+		}
+
+	case compiler.OpJumpFalse:
+		target := vm.operand16(f, code)
+		v, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		if v.Kind != value.Bool {
+			return fmt.Errorf("condition must be bool, got %s", v.Kind)
+		}
+		if !v.Bool {
+			f.ip = target
+		}
+
+	default:
+		return fmt.Errorf("unknown opcode %d", op)
+	}
+	return nil
+}
+
+func arithmetic(op compiler.Opcode, x, y value.Value) (value.Value, error) {
+	switch op {
+	case compiler.OpAdd:
+		return value.Add(x, y)
+	case compiler.OpSub:
+		return value.Sub(x, y)
+	case compiler.OpMul:
+		return value.Mul(x, y)
+	}
+	return value.Div(x, y)
+}
+
+// operators maps comparison opcodes to their operators.
+var operators = map[compiler.Opcode]string{
+	compiler.OpEqual:        "==",
+	compiler.OpNotEqual:     "!=",
+	compiler.OpLess:         "<",
+	compiler.OpGreater:      ">",
+	compiler.OpLessEqual:    "<=",
+	compiler.OpGreaterEqual: ">=",
+}
+
+// jumps reports whether the conditional jump op is taken
+// after the comparison that returned result.
+func jumps(op compiler.Opcode, result int) bool {
+	switch op {
+	case compiler.OpJumpEqual:
+		return result == 0
+	case compiler.OpJumpNotEqual:
+		return result != 0
+	case compiler.OpJumpLess:
+		return result < 0
+	case compiler.OpJumpGreater:
+		return result > 0
+	case compiler.OpJumpLessEqual:
+		return result <= 0
+	}
+	return result >= 0
+}
+
+// global returns the global variable idx,
+// running its initializer on first use.
+func (vm *VM) global(idx int) (value.Value, error) {
+	if vm.initialized[idx] {
+		return vm.globals[idx], nil
+	}
+	g := vm.program.Globals[idx]
+	v, err := vm.call(g.Init, nil)
+	if err != nil {
+		return value.Value{}, fmt.Errorf("initializing %s.%s: %w", g.Module, g.Name, err)
+	}
+	vm.globals[idx] = v
+	vm.initialized[idx] = true
+	return v, nil
+}
+
+func (vm *VM) operand16(f *frame, code []byte) int {
+	v := compiler.ReadUint16(code[f.ip:])
+	f.ip += 2
+	return v
+}
+
+func (vm *VM) push(v value.Value) {
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *VM) pop() (value.Value, error) {
+	if vm.available() == 0 {
+		return value.Value{}, ErrStackUnderflow
+	}
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v, nil
+}
+
+// available returns the number of values the current frame can pop,
+// which excludes its locals and the values of outer frames.
+func (vm *VM) available() int {
+	if len(vm.frames) == 0 {
+		return len(vm.stack)
+	}
+	f := vm.frames[len(vm.frames)-1]
+	return max(0, len(vm.stack)-f.base-f.function.NumLocals)
+}
+
+func (vm *VM) outputf(format string, v ...any) {
+	if vm.debug {
+		log.Printf(format, v...)
+	}
+}
+
+func (vm *VM) reset(p *compiler.Program) {
+	vm.program = p
+	vm.stack = nil
+	vm.frames = nil
+	vm.globals = make([]value.Value, len(p.Globals))
+	vm.initialized = make([]bool, len(p.Globals))
+}
@@ -0,0 +1,220 @@
+package vm_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dywoq/dywoqlang/ast"
+	"github.com/dywoq/dywoqlang/compiler"
+	"github.com/dywoq/dywoqlang/consteval"
+	"github.com/dywoq/dywoqlang/interp"
+	"github.com/dywoq/dywoqlang/linker"
+	"github.com/dywoq/dywoqlang/parser"
+	"github.com/dywoq/dywoqlang/scanner"
+	"github.com/dywoq/dywoqlang/sema"
+	"github.com/dywoq/dywoqlang/typecheck"
+	"github.com/dywoq/dywoqlang/value"
+	"github.com/dywoq/dywoqlang/vm"
+)
+
+// analyze runs every stage before the backends on src,
+// failing the test if any of them reports an error.
+func analyze(t *testing.T, src string) ([]ast.Node, linker.Links) {
+	t.Helper()
+	tokens, err := scanner.New(false).Scan(src)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	nodes, err := parser.New(false).Parse(tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := sema.New(false).Analyze(nodes); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	links, err := linker.New(false).Link(nodes)
+	if err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	if err := consteval.New(false).Evaluate(nodes, links); err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if err := typecheck.New(false).Check(nodes); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	return nodes, links
+}
+
+// result is what the program did when run by one of the backends.
+type result struct {
+	value  value.Value
+	stdout string
+	stderr string
+}
+
+func runInterp(t *testing.T, src string) result {
+	t.Helper()
+	nodes, links := analyze(t, src)
+	var stdout, stderr bytes.Buffer
+	i := interp.New(false)
+	i.SetOutput(&stdout, &stderr)
+	v, err := i.Run(nodes, links)
+	if err != nil {
+		t.Fatalf("interp: %v", err)
+	}
+	return result{value: v, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+// runVM compiles src and runs it after encoding and decoding it,
+// like the program written by `dywoq build`.
+func runVM(t *testing.T, src string) result {
+	t.Helper()
+	nodes, links := analyze(t, src)
+	p, err := compiler.New(false).Compile(nodes, links)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := compiler.Encode(&buf, p); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	p, err = compiler.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	m := vm.New(false)
+	m.SetOutput(&stdout, &stderr)
+	v, err := m.Run(p)
+	if err != nil {
+		t.Fatalf("vm: %v", err)
+	}
+	return result{value: v, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+// TestBackends runs each program of testdata with the interpreter and the virtual machine,
+// which must agree with each other and print the output stored in the .out file.
+func TestBackends(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.dl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no programs in testdata")
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".dl")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(path, ".dl") + ".out")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			i, v := runInterp(t, string(src)), runVM(t, string(src))
+			if i.value.Kind != v.value.Kind || i.value.String() != v.value.String() {
+				t.Errorf("interp returned %s %s, vm returned %s %s", i.value.Kind, i.value, v.value.Kind, v.value)
+			}
+			if i.stdout != v.stdout {
+				t.Errorf("interp printed\n%s\nvm printed\n%s", i.stdout, v.stdout)
+			}
+			if i.stderr != v.stderr {
+				t.Errorf("interp printed to stderr\n%s\nvm printed to stderr\n%s", i.stderr, v.stderr)
+			}
+			if v.stdout != string(want) {
+				t.Errorf("printed\n%s\nwant\n%s", v.stdout, want)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	function := func(numLocals int, code ...[]byte) *compiler.Function {
+		return &compiler.Function{Name: "main", Module: "main", NumLocals: numLocals, Code: bytes.Join(code, nil)}
+	}
+	tests := []struct {
+		name    string
+		program *compiler.Program
+		err     error
+	}{
+		{
+			name:    "no functions",
+			program: &compiler.Program{},
+			err:     compiler.ErrInvalidProgram,
+		},
+		{
+			name: "constant out of range",
+			program: &compiler.Program{Functions: []*compiler.Function{
+				function(0, compiler.Make(compiler.OpConst, 0), compiler.Make(compiler.OpReturn)),
+			}},
+			err: compiler.ErrInvalidProgram,
+		},
+		{
+			name: "local out of range",
+			program: &compiler.Program{Functions: []*compiler.Function{
+				function(1, compiler.Make(compiler.OpLoadLocal, 1), compiler.Make(compiler.OpReturn)),
+			}},
+			err: compiler.ErrInvalidProgram,
+		},
+		{
+			name: "truncated operand",
+			program: &compiler.Program{Functions: []*compiler.Function{
+				function(0, compiler.Make(compiler.OpConst, 0)[:2]),
+			}},
+			err: compiler.ErrInvalidProgram,
+		},
+		{
+			name: "jump into operand",
+			program: &compiler.Program{Functions: []*compiler.Function{
+				function(0, compiler.Make(compiler.OpJump, 1), compiler.Make(compiler.OpReturnNil)),
+			}},
+			err: compiler.ErrInvalidProgram,
+		},
+		{
+			name: "unknown opcode",
+			program: &compiler.Program{Functions: []*compiler.Function{
+				function(0, []byte{0xff}),
+			}},
+			err: compiler.ErrInvalidProgram,
+		},
+		{
+			name: "pop of locals",
+			program: &compiler.Program{Functions: []*compiler.Function{
+				function(1, compiler.Make(compiler.OpPop), compiler.Make(compiler.OpReturnNil)),
+			}},
+			err: vm.ErrStackUnderflow,
+		},
+		{
+			name: "conditional jump without cmp",
+			program: &compiler.Program{Functions: []*compiler.Function{
+				function(0, compiler.Make(compiler.OpJumpEqual, 3), compiler.Make(compiler.OpReturnNil)),
+			}},
+			err: vm.ErrNoComparison,
+		},
+		{
+			name: "infinite recursion",
+			program: &compiler.Program{Functions: []*compiler.Function{
+				function(0, compiler.Make(compiler.OpCall, 0, 0), compiler.Make(compiler.OpReturn)),
+			}},
+			err: vm.ErrStackOverflow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := vm.New(false)
+			m.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+			_, err := m.Run(tt.program)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}